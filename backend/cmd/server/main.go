@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"napscan-be/internal/handler"
 	"napscan-be/internal/middleware"
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
 	"napscan-be/internal/routes"
 	"napscan-be/internal/service"
 
@@ -61,31 +64,86 @@ func main() {
 
 	api := app.Group("/api")
 
+	// Storage
+	repo, err := repository.OpenSQLite(repository.DefaultDBPath())
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer repo.Close()
+
 	// Services
-	nmapService := service.NewNmapService()
+	nmapProfileService := service.NewNmapProfileService(repo)
+	nmapService := service.NewNmapService(nmapProfileService, service.NmapConfigFromEnv())
+	nmapService.DetectPrivileges(context.Background())
 	nucleiService := service.NewNucleiService()
 	zapService := service.NewZapService()
 	ffufService := service.NewFfufService()
 	openvasService := service.NewOpenVASService()
 	sslyzeService := service.NewSslyzeService()
 
+	// Background jobs
+	jobService := service.NewJobService(repo, service.PoolConfigFromEnv())
+	jobService.Register(models.ToolNmap, nmapService.RunJob)
+	jobService.RegisterOptions(models.ToolNmap, nmapProfileService.ValidateOptions)
+	jobService.Register(models.ToolNuclei, nucleiService.RunJob)
+	jobService.RegisterResumable(models.ToolZap, zapService.RunJob)
+	jobService.Register(models.ToolFfuf, ffufService.RunJob)
+	jobService.RegisterResumable(models.ToolOpenVAS, openvasService.RunJob)
+	jobService.Register(models.ToolSslyze, sslyzeService.RunJob)
+
+	// Multi-tool scans and recurring scans
+	riskScorer := service.NewRiskScorer(service.RiskConfigFromEnv())
+	enrichmentService := service.NewEnrichmentService(service.EnrichmentDirFromEnv())
+	if err := enrichmentService.Load(); err != nil {
+		log.Printf("Failed to load vulnerability feeds: %v", err)
+	}
+	suppressionService := service.NewSuppressionService(repo, jobService)
+	if err := suppressionService.Load(); err != nil {
+		log.Printf("Failed to load suppression rules: %v", err)
+	}
+	triageService := service.NewTriageService(repo, jobService, enrichmentService)
+	scanService := service.NewScanService(repo, jobService, riskScorer, enrichmentService, triageService, suppressionService)
+	scheduleService := service.NewScheduleService(repo, jobService, scanService)
+	batchService := service.NewBatchService(repo, jobService, riskScorer, enrichmentService, suppressionService, service.BatchConfigFromEnv())
+
+	// Resume after every OnFinish hook is registered, so parent scans see interrupted jobs
+	if err := jobService.Resume(); err != nil {
+		log.Printf("Failed to resume jobs: %v", err)
+	}
+	scheduleService.Start(context.Background())
+	batchService.Start(context.Background())
+	triageService.Start(context.Background())
+
 	// Handlers
 	healthHandler := handler.NewHealthHandler()
-	nmapHandler := handler.NewNmapHandler(nmapService)
-	nucleiHandler := handler.NewNucleiHandler(nucleiService)
-	zapHandler := handler.NewZapHandler(zapService)
-	ffufHandler := handler.NewFfufHandler(ffufService)
-	openvasHandler := handler.NewOpenVASHandler(openvasService)
-	sslyzeHandler := handler.NewSslyzeHandler(sslyzeService)
-	
+	jobHandler := handler.NewJobHandler(jobService)
+	scanHandler := handler.NewScanHandler(scanService)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService)
+	triageHandler := handler.NewTriageHandler(triageService)
+	suppressionHandler := handler.NewSuppressionHandler(suppressionService)
+	nmapHandler := handler.NewNmapHandler(jobService, nmapProfileService)
+	nucleiHandler := handler.NewNucleiHandler(jobService)
+	zapHandler := handler.NewZapHandler(jobService)
+	ffufHandler := handler.NewFfufHandler(jobService)
+	openvasHandler := handler.NewOpenVASHandler(openvasService, jobService)
+	sslyzeHandler := handler.NewSslyzeHandler(jobService)
+
 	// Auth & Batch Handlers
-	authHandler := handler.NewAuthHandler()
+	authHandler := handler.NewAuthHandler(service.NewAuthService(repo))
+	batchHandler := handler.NewBatchHandler(batchService)
+	enrichmentHandler := handler.NewEnrichmentHandler(enrichmentService)
 
 	// Health Check Route
 	app.Get("/health", healthHandler.Check)
 	api.Get("/health", healthHandler.Check)
 
 	// Routes
+	routes.JobRoutes(api, jobHandler)
+	routes.ScanRoutes(api, scanHandler)
+	routes.ImportRoutes(api, scanHandler)
+	routes.ScheduleRoutes(api, scheduleHandler)
+	routes.TriageRoutes(api, triageHandler)
+	routes.SuppressionRoutes(api, suppressionHandler)
 	routes.MobSFRoutes(api)
 	routes.NmapRoutes(api, nmapHandler)
 	routes.NucleiRoutes(api, nucleiHandler)
@@ -96,6 +154,8 @@ func main() {
 
 	// Auth & Batch Routes
 	routes.AuthRoutes(app, authHandler)
+	routes.BatchRoutes(api, batchHandler)
+	routes.EnrichmentRoutes(api, enrichmentHandler)
 
 	port := os.Getenv("PORT")
 	if port == "" {
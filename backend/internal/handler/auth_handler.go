@@ -11,9 +11,9 @@ type AuthHandler struct {
 	authService *service.AuthService
 }
 
-func NewAuthHandler() *AuthHandler {
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 	return &AuthHandler{
-		authService: service.NewAuthService(),
+		authService: authService,
 	}
 }
 
@@ -42,6 +42,10 @@ func (h *AuthHandler) GoogleLogin(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid Google token: " + err.Error()})
 	}
 
+	if err := h.authService.RecordLogin(c.Context(), user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save user"})
+	}
+
 	// Generate JWT
 	token, err := h.authService.GenerateJWT(user)
 	if err != nil {
@@ -84,6 +88,10 @@ func (h *AuthHandler) GoogleCallback(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to handle callback: " + err.Error()})
 	}
 
+	if err := h.authService.RecordLogin(c.Context(), user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save user"})
+	}
+
 	token, err := h.authService.GenerateJWT(user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate session"})
@@ -92,7 +100,7 @@ func (h *AuthHandler) GoogleCallback(c *fiber.Ctx) error {
 	// In a real app, you might redirect to a frontend with the token in URL or set a cookie.
 	// For testing/JSON API purposes, we return JSON.
 	// return c.Redirect("http://localhost:3000/auth/success?token=" + token)
-	
+
 	return c.JSON(models.AuthResponse{
 		AccessToken: token,
 		User:        *user,
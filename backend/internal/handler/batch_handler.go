@@ -1,10 +1,11 @@
 package handler
 
 import (
-	"time"
+	"errors"
 
 	"napscan-be/internal/models"
 	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,152 +14,121 @@ type BatchHandler struct {
 	batchService *service.BatchService
 }
 
-func NewBatchHandler() *BatchHandler {
+func NewBatchHandler(batchService *service.BatchService) *BatchHandler {
 	return &BatchHandler{
-		batchService: service.NewBatchService(),
+		batchService: batchService,
 	}
 }
 
-// HandleScanPartA receives input for part A of the scan
-// @Summary Receive Scan Part A
-// @Description Receives partial scan result for Part A
+// CreateBatch opens a fan-in batch
+// @Summary Create Batch
+// @Description Open a batch that waits for one result per source. Sources are tool names (nmap, nuclei, zap, ffuf, sslyze, openvas). The analysis runs once every source has reported. Each user can have a limited number of batches waiting for results; batches that stop receiving results expire.
 // @Tags Batch
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param X-Batch-ID header string true "Batch ID"
-// @Param request body models.BatchRequest false "Scan Data"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
-// @Router /api/a [post]
-func (h *BatchHandler) HandleScanPartA(c *fiber.Ctx) error {
-	return h.handlePart(c, "api_a")
-}
+// @Param request body models.BatchCreateRequest true "Expected sources and optional batch ID"
+// @Success 201 {object} response.Response{data=models.Batch}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Router /batches [post]
+func (h *BatchHandler) CreateBatch(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
 
-// HandleScanPartB receives input for part B of the scan
-// @Summary Receive Scan Part B
-// @Description Receives partial scan result for Part B
-// @Tags Batch
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param X-Batch-ID header string true "Batch ID"
-// @Success 200 {object} map[string]string
-// @Router /api/b [post]
-func (h *BatchHandler) HandleScanPartB(c *fiber.Ctx) error {
-	return h.handlePart(c, "api_b")
-}
+	var req models.BatchCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
 
-// HandleScanPartC receives input for part C of the scan
-// @Summary Receive Scan Part C
-// @Description Receives partial scan result for Part C
-// @Tags Batch
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param X-Batch-ID header string true "Batch ID"
-// @Success 200 {object} map[string]string
-// @Router /api/c [post]
-func (h *BatchHandler) HandleScanPartC(c *fiber.Ctx) error {
-	return h.handlePart(c, "api_c")
-}
+	batch, err := h.batchService.Create(userID, req)
+	if err != nil {
+		return batchError(c, err)
+	}
 
-// HandleScanPartD receives input for part D of the scan
-// @Summary Receive Scan Part D
-// @Description Receives partial scan result for Part D
-// @Tags Batch
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param X-Batch-ID header string true "Batch ID"
-// @Success 200 {object} map[string]string
-// @Router /api/d [post]
-func (h *BatchHandler) HandleScanPartD(c *fiber.Ctx) error {
-	return h.handlePart(c, "api_d")
+	return response.Created(c, "Batch created", batch)
 }
 
-// HandleScanPartE receives input for part E of the scan
-// @Summary Receive Scan Part E
-// @Description Receives partial scan result for Part E
+// AddBatchResult contributes the result of one source
+// @Summary Add Batch Result
+// @Description Contribute the result of a source, either from a completed job of that tool (job_id) or as the raw tool result (result). A source that already reported is ignored. Results over the size limit are rejected.
 // @Tags Batch
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param X-Batch-ID header string true "Batch ID"
-// @Success 200 {object} map[string]string
-// @Router /api/e [post]
-func (h *BatchHandler) HandleScanPartE(c *fiber.Ctx) error {
-	return h.handlePart(c, "api_e")
-}
-
-// handlePart is the shared logic for the fan-in endpoints
-func (h *BatchHandler) handlePart(c *fiber.Ctx, sourceName string) error {
-	// 1. Get UserID from context (set by AuthMiddleware)
-	userID, ok := c.Locals("user_id").(string)
-	if !ok || userID == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User ID not found in session"})
-	}
-
-	// 2. Get X-Batch-ID header
-	batchID := c.Get("X-Batch-ID")
-	if batchID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "X-Batch-ID header is required"})
+// @Param batch_id path string true "Batch ID"
+// @Param source path string true "Source (tool name)"
+// @Param request body models.BatchResultRequest true "Job ID or raw result"
+// @Success 200 {object} response.Response{data=models.Batch}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 410 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Router /batches/{batch_id}/results/{source} [post]
+func (h *BatchHandler) AddBatchResult(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
 	}
 
-	// 3. Process Payload (mock)
-	mockResult := map[string]string{
-		"source":    sourceName,
-		"processed": "true",
-		"timestamp": time.Now().String(),
+	var req models.BatchResultRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
 	}
 
-	// 4. Send to Batch Manager
-	err := h.batchService.AddResult(userID, batchID, sourceName, mockResult)
+	batch, err := h.batchService.AddResult(userID, c.Params("batch_id"), c.Params("source"), req)
 	if err != nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		return batchError(c, err)
 	}
 
-	return c.JSON(fiber.Map{
-		"status":   "received",
-		"source":   sourceName,
-		"batch_id": batchID,
-	})
+	return response.Success(c, "Result received", batch)
 }
 
 // GetBatchResult retrieves the aggregated analysis
-// @Summary Get Batch Analysis Result
-// @Description Returns the aggregated results and status of the batch
+// @Summary Get Batch
+// @Description Returns the status of the batch, the results received so far and the analysis once it is complete. Expired batches keep their status but no results.
 // @Tags Batch
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param batch_id path string true "Batch ID"
-// @Success 200 {object} models.BatchResponse
-// @Failure 404 {object} map[string]string
-// @Router /api/analysis/{batch_id} [get]
+// @Success 200 {object} response.Response{data=models.Batch}
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /batches/{batch_id} [get]
 func (h *BatchHandler) GetBatchResult(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
+	userID, ok := currentUserID(c)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User ID not found in session"})
-	}
-
-	batchID := c.Params("batch_id")
-	if batchID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Batch ID required"})
+		return response.Unauthorized(c, "User ID not found in session")
 	}
 
-	batch, err := h.batchService.GetBatch(userID, batchID)
+	batch, err := h.batchService.GetBatch(userID, c.Params("batch_id"))
 	if err != nil {
-		if err.Error() == "not found" {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Batch not found"})
-		}
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		return batchError(c, err)
 	}
 
-	return c.JSON(models.BatchResponse{
-		Status:         batch.Status,
-		AnalysisResult: batch.AnalysisResult,
-		BatchID:        batch.BatchID,
-	})
+	return response.Success(c, "Batch retrieved", batch)
+}
+
+func batchError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrBatchNotFound):
+		return response.NotFound(c, "Batch not found")
+	case errors.Is(err, service.ErrBatchExists):
+		return response.Error(c, fiber.StatusConflict, err.Error(), nil)
+	case errors.Is(err, service.ErrBatchExpired):
+		return response.Error(c, fiber.StatusGone, err.Error(), nil)
+	case errors.Is(err, service.ErrBatchLimit):
+		return response.Error(c, fiber.StatusTooManyRequests, err.Error(), nil)
+	case errors.Is(err, service.ErrResultTooLarge):
+		return response.Error(c, fiber.StatusRequestEntityTooLarge, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidBatch):
+		return response.BadRequest(c, err.Error(), err)
+	}
+	return response.InternalServerError(c, "Failed to process batch", err)
 }
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+
+	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type EnrichmentHandler struct {
+	enrichment *service.EnrichmentService
+}
+
+func NewEnrichmentHandler(enrichment *service.EnrichmentService) *EnrichmentHandler {
+	return &EnrichmentHandler{enrichment: enrichment}
+}
+
+// GetFeeds returns the state of the local vulnerability feeds
+// @Summary Get Enrichment Feeds
+// @Description Entries, files and version of the NVD, KEV and EPSS feeds findings are enriched from
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.FeedStatus}
+// @Failure 403 {object} response.Response
+// @Router /admin/enrichment [get]
+func (h *EnrichmentHandler) GetFeeds(c *fiber.Ctx) error {
+	return response.Success(c, "Feeds retrieved", h.enrichment.Status())
+}
+
+// UpdateFeed replaces a vulnerability feed with an uploaded file
+// @Summary Update Enrichment Feed
+// @Description Upload a feed file, plain or gzipped: an NVD CVE API 2.0 JSON file (nvd, stored under its file name so yearly files add up), the CISA KEV catalog JSON (kev) or the EPSS scores CSV (epss). The file is checked before it replaces the stored one.
+// @Tags Admin
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param feed path string true "Feed" Enums(nvd, kev, epss)
+// @Param file formData file true "Feed file"
+// @Success 200 {object} response.Response{data=models.FeedStatus}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /admin/enrichment/{feed} [post]
+func (h *EnrichmentHandler) UpdateFeed(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return response.BadRequest(c, "Feed file is required", err)
+	}
+	f, err := file.Open()
+	if err != nil {
+		return response.BadRequest(c, "Failed to read feed file", err)
+	}
+	defer f.Close()
+
+	status, err := h.enrichment.Update(c.Params("feed"), file.Filename, f)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownFeed) {
+			return response.NotFound(c, "Unknown feed")
+		}
+		if errors.Is(err, service.ErrInvalidFeed) {
+			return response.BadRequest(c, err.Error(), err)
+		}
+		return response.InternalServerError(c, "Failed to update feed", err)
+	}
+
+	return response.Success(c, "Feed updated", status)
+}
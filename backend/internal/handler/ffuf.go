@@ -1,9 +1,7 @@
 package handler
 
 import (
-	"context"
-	"time"
-
+	"napscan-be/internal/models"
 	"napscan-be/internal/service"
 	"napscan-be/pkg/response"
 
@@ -11,44 +9,41 @@ import (
 )
 
 type FfufHandler struct {
-service *service.FfufService
+	jobs *service.JobService
 }
 
-func NewFfufHandler(s *service.FfufService) *FfufHandler {
-return &FfufHandler{service: s}
+func NewFfufHandler(jobs *service.JobService) *FfufHandler {
+	return &FfufHandler{jobs: jobs}
 }
 
-// StartScan initiates a FFUF scan
+// StartScan queues a FFUF scan
 // @Summary Start FFUF Scan
-// @Description Run directory fuzzing using FFUF
+// @Description Queue directory fuzzing using FFUF. Poll /jobs/{id} for the result.
 // @Tags FFUF
 // @Accept json
 // @Produce json
 // @Param target body object{target=string} true "Target URL"
-// @Success 200 {object} response.Response
+// @Success 202 {object} response.Response{data=models.Job}
 // @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /ffuf/scan [post]
 func (h *FfufHandler) StartScan(c *fiber.Ctx) error {
-var req struct {
-Target string `json:"target"`
-}
-
-if err := c.BodyParser(&req); err != nil {
-return response.BadRequest(c, "Invalid request payload", err)
-}
+	var req struct {
+		Target string `json:"target"`
+	}
 
-if req.Target == "" {
-return response.BadRequest(c, "Target is required", nil)
-}
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
 
-ctx, cancel := context.WithTimeout(c.Context(), 120*time.Second)
-defer cancel()
+	if req.Target == "" {
+		return response.BadRequest(c, "Target is required", nil)
+	}
 
-result, err := h.service.ExecuteScan(ctx, req.Target)
-if err != nil {
-return response.InternalServerError(c, "FFUF scan failed", err)
-}
+	job, err := h.jobs.Submit(models.JobRequest{Tool: models.ToolFfuf, Target: req.Target})
+	if err != nil {
+		return submitError(c, "Failed to queue FFUF scan", err)
+	}
 
-return response.Success(c, "Scan completed", result)
+	return response.Accepted(c, "Scan queued", job)
 }
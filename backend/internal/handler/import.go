@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"errors"
+	"io"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportScan stores tool outputs produced elsewhere as a completed scan
+// @Summary Import Scan
+// @Description Upload native outputs of the tools run elsewhere: nmap XML, OpenVAS XML reports, nuclei JSONL or JSON export, ZAP alerts JSON or Traditional JSON Report, sslyze JSON and ffuf JSON. The format of each file is detected and it becomes a completed job of a new scan, whose findings are read like those of a scan run here. target is required when the files are not all about the same host.
+// @Tags Scans
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Tool output, repeat for several files"
+// @Param name formData string false "Scan name"
+// @Param target formData string false "Host or network the outputs are about"
+// @Param asset_criticality formData string false "Asset criticality" Enums(low, medium, high, critical)
+// @Success 201 {object} response.Response{data=models.Scan}
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /imports [post]
+func (h *ScanHandler) ImportScan(c *fiber.Ctx) error {
+	var req models.ScanImport
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return response.BadRequest(c, "Tool output files are required", err)
+	}
+
+	var files []service.ImportFile
+	for _, header := range form.File["file"] {
+		f, err := header.Open()
+		if err != nil {
+			return response.BadRequest(c, "Failed to read "+header.Filename, err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return response.BadRequest(c, "Failed to read "+header.Filename, err)
+		}
+		files = append(files, service.ImportFile{Name: header.Filename, Content: content})
+	}
+
+	scan, err := h.scans.Import(req, files)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScan) {
+			return response.BadRequest(c, err.Error(), err)
+		}
+		return response.InternalServerError(c, "Failed to import scan", err)
+	}
+
+	return response.Created(c, "Scan imported", scan)
+}
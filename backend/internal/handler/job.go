@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type JobHandler struct {
+	jobs *service.JobService
+}
+
+func NewJobHandler(jobs *service.JobService) *JobHandler {
+	return &JobHandler{jobs: jobs}
+}
+
+// ListJobs returns the stored scan jobs
+// @Summary List Jobs
+// @Description List scan jobs, newest first
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param tool query string false "Filter by tool"
+// @Param status query string false "Filter by status"
+// @Param limit query int false "Maximum number of jobs" default(100)
+// @Success 200 {object} response.Response{data=[]models.Job}
+// @Failure 500 {object} response.Response
+// @Router /jobs [get]
+func (h *JobHandler) ListJobs(c *fiber.Ctx) error {
+	jobs, err := h.jobs.List(repository.JobFilter{
+		Tool:   c.Query("tool"),
+		Status: models.JobStatus(c.Query("status")),
+		Limit:  c.QueryInt("limit", 100),
+	})
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list jobs", err)
+	}
+
+	return response.Success(c, "Jobs retrieved", jobs)
+}
+
+// GetJob returns the state of a background scan job
+// @Summary Get Job
+// @Description Get status, timestamps, progress and result of a scan job
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} response.Response{data=models.Job}
+// @Failure 404 {object} response.Response
+// @Router /jobs/{id} [get]
+func (h *JobHandler) GetJob(c *fiber.Ctx) error {
+	job, err := h.jobs.Get(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrJobNotFound) {
+			return response.NotFound(c, "Job not found")
+		}
+		return response.InternalServerError(c, "Failed to get job", err)
+	}
+
+	return response.Success(c, "Job retrieved", job)
+}
+
+// StreamEvents pushes job updates to the client as Server-Sent Events
+// @Summary Stream Job Events
+// @Description Server-Sent Events stream of a job. A "status" event is sent when the job changes state and a "progress" event when only the percent complete or message changes. Each event carries the full job. The stream ends when the job finishes.
+// @Tags Jobs
+// @Produce text/event-stream
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.Job
+// @Failure 404 {object} response.Response
+// @Router /jobs/{id}/events [get]
+func (h *JobHandler) StreamEvents(c *fiber.Ctx) error {
+	events, unsubscribe, err := h.jobs.Subscribe(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrJobNotFound) {
+			return response.NotFound(c, "Job not found")
+		}
+		return response.InternalServerError(c, "Failed to get job", err)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		var lastStatus models.JobStatus
+		for {
+			select {
+			case job, ok := <-events:
+				if !ok {
+					return
+				}
+				event := "progress"
+				if job.Status != lastStatus {
+					event = "status"
+					lastStatus = job.Status
+				}
+				data, err := json.Marshal(job)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+			case <-heartbeat.C:
+				// Comment line, keeps proxies from closing an idle stream
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			// Flush fails once the client has gone away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// ListOutputs returns the native tool outputs stored for a job
+// @Summary List Job Outputs
+// @Description List raw tool output files (nmap XML, nuclei JSONL, ...) stored for a job
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} response.Response{data=[]models.ToolOutput}
+// @Failure 404 {object} response.Response
+// @Router /jobs/{id}/outputs [get]
+func (h *JobHandler) ListOutputs(c *fiber.Ctx) error {
+	outputs, err := h.jobs.Outputs(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrJobNotFound) {
+			return response.NotFound(c, "Job not found")
+		}
+		return response.InternalServerError(c, "Failed to list outputs", err)
+	}
+
+	return response.Success(c, "Outputs retrieved", outputs)
+}
+
+// GetOutput downloads a native tool output of a job
+// @Summary Download Job Output
+// @Description Download a raw tool output file as produced by the tool
+// @Tags Jobs
+// @Produce octet-stream
+// @Param id path string true "Job ID"
+// @Param name path string true "Output name"
+// @Success 200 {file} file
+// @Failure 404 {object} response.Response
+// @Router /jobs/{id}/outputs/{name} [get]
+func (h *JobHandler) GetOutput(c *fiber.Ctx) error {
+	out, err := h.jobs.Output(c.Params("id"), c.Params("name"))
+	if err != nil {
+		if errors.Is(err, service.ErrJobNotFound) {
+			return response.NotFound(c, "Output not found")
+		}
+		return response.InternalServerError(c, "Failed to get output", err)
+	}
+
+	c.Attachment(out.Name)
+	c.Set("Content-Type", out.ContentType)
+	return c.Send(out.Content)
+}
+
+// CancelJob stops a queued or running job
+// @Summary Cancel Job
+// @Description Cancel a job: kills the tool process group, or stops the scan inside ZAP/OpenVAS
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} response.Response{data=models.Job}
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /jobs/{id} [delete]
+// @Router /jobs/{id}/cancel [post]
+func (h *JobHandler) CancelJob(c *fiber.Ctx) error {
+	job, err := h.jobs.Cancel(c.Params("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJobNotFound):
+			return response.NotFound(c, "Job not found")
+		case errors.Is(err, service.ErrJobFinished):
+			return response.Error(c, fiber.StatusConflict, "Job already finished", err.Error())
+		}
+		return response.InternalServerError(c, "Failed to cancel job", err)
+	}
+
+	return response.Success(c, "Job cancellation requested", job)
+}
+
+// GetQueue shows the worker pool
+// @Summary Get Job Queue
+// @Description List running jobs and queued jobs with their position, plus the concurrency limits
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=models.QueueStatus}
+// @Router /queue [get]
+func (h *JobHandler) GetQueue(c *fiber.Ctx) error {
+	return response.Success(c, "Queue retrieved", h.jobs.Queue())
+}
+
+// RequeueJob runs an interrupted job again
+// @Summary Re-queue Job
+// @Description Re-queue a job whose local tool process was interrupted by a backend restart. The job runs again from the start under the same ID.
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} response.Response{data=models.Job}
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /jobs/{id}/requeue [post]
+func (h *JobHandler) RequeueJob(c *fiber.Ctx) error {
+	job, err := h.jobs.Requeue(c.Params("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJobNotFound):
+			return response.NotFound(c, "Job not found")
+		case errors.Is(err, service.ErrJobNotInterrupted):
+			return response.Error(c, fiber.StatusConflict, "Job cannot be re-queued", err.Error())
+		}
+		return response.InternalServerError(c, "Failed to re-queue job", err)
+	}
+
+	return response.Accepted(c, "Job re-queued", job)
+}
+
+// submitError maps the errors of JobService.Submit: bad targets and options are the
+// caller's fault, anything else is ours
+func submitError(c *fiber.Ctx, message string, err error) error {
+	if errors.Is(err, service.ErrInvalidTarget) || errors.Is(err, service.ErrInvalidOptions) {
+		return response.BadRequest(c, err.Error(), err)
+	}
+	return response.InternalServerError(c, message, err)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+	"napscan-be/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is one event of a Server-Sent Events stream
+type sseEvent struct {
+	name string
+	job  models.Job
+}
+
+func readEvents(t *testing.T, body io.Reader) []sseEvent {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(string(data)), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				event.name = name
+			} else if payload, ok := strings.CutPrefix(line, "data: "); ok {
+				require.NoError(t, json.Unmarshal([]byte(payload), &event.job))
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestStreamEvents(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	jobs := service.NewJobService(repo, service.DefaultPoolConfig())
+	release := make(chan struct{})
+	jobs.Register(models.ToolNmap, func(ctx context.Context, run *service.JobRun) (interface{}, error) {
+		run.SetProgress(50, "half way")
+		<-release
+		return nil, nil
+	})
+
+	app := fiber.New()
+	h := NewJobHandler(jobs)
+	app.Get("/jobs/:id/events", h.StreamEvents)
+
+	job, err := jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		running, err := jobs.Get(job.ID)
+		return err == nil && running.Progress == 50
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// The job finishes while the stream is open
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+	resp, err := app.Test(httptest.NewRequest("GET", "/jobs/"+job.ID+"/events", nil), 5000)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := readEvents(t, resp.Body)
+	require.GreaterOrEqual(t, len(events), 2)
+	assert.Equal(t, "status", events[0].name)
+	assert.Equal(t, models.JobStatusRunning, events[0].job.Status)
+	assert.Equal(t, 50, events[0].job.Progress)
+	last := events[len(events)-1]
+	assert.Equal(t, "status", last.name)
+	assert.Equal(t, models.JobStatusCompleted, last.job.Status)
+	for _, event := range events[1 : len(events)-1] {
+		assert.Equal(t, "progress", event.name, "only the last event changes the status")
+	}
+
+	// A finished job streams its final state and ends
+	resp, err = app.Test(httptest.NewRequest("GET", "/jobs/"+job.ID+"/events", nil), 5000)
+	require.NoError(t, err)
+	events = readEvents(t, resp.Body)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.JobStatusCompleted, events[0].job.Status)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/jobs/missing/events", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"errors"
+
+	"napscan-be/internal/models"
 	"napscan-be/internal/service"
 	"napscan-be/pkg/response"
 
@@ -8,27 +11,29 @@ import (
 )
 
 type NmapHandler struct {
-	service *service.NmapService
+	jobs     *service.JobService
+	profiles *service.NmapProfileService
 }
 
-func NewNmapHandler(s *service.NmapService) *NmapHandler {
-	return &NmapHandler{service: s}
+func NewNmapHandler(jobs *service.JobService, profiles *service.NmapProfileService) *NmapHandler {
+	return &NmapHandler{jobs: jobs, profiles: profiles}
 }
 
-// StartFullScan initiates a full Nmap scan (TCP + UDP)
+// StartFullScan queues a full Nmap scan (TCP + UDP)
 // @Summary Start Nmap Full Scan
-// @Description Run parallel TCP and UDP Nmap scans on a target
+// @Description Queue parallel TCP and UDP Nmap scans on a target with the settings of a profile, "default" when none is given. Profiles that skip UDP leave udp null. A CIDR network is swept with a ping scan first and the live hosts are scanned in shards, whose progress is listed under shards; the result merges the shards. When nmap has no raw socket access (root or CAP_NET_RAW) the scan falls back to a TCP connect scan without UDP and OS detection, and warnings says so. Poll /jobs/{id} for the result.
 // @Tags Nmap
 // @Accept json
 // @Produce json
-// @Param target body object{target=string} true "Target IP or Hostname"
-// @Success 200 {object} service.CombinedScanResponse
+// @Param target body object{target=string,profile=string} true "Target IP or Hostname and the name of a profile"
+// @Success 202 {object} response.Response{data=models.Job}
 // @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /nmap/scan [post]
 func (h *NmapHandler) StartFullScan(c *fiber.Ctx) error {
 	var req struct {
-		Target string `json:"target"`
+		Target  string `json:"target"`
+		Profile string `json:"profile"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -39,10 +44,138 @@ func (h *NmapHandler) StartFullScan(c *fiber.Ctx) error {
 		return response.BadRequest(c, "Target is required", nil)
 	}
 
-	result, err := h.service.RunParallelScan(req.Target)
+	var options map[string]string
+	if req.Profile != "" {
+		options = map[string]string{"profile": req.Profile}
+	}
+	job, err := h.jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: req.Target, Options: options})
+	if err != nil {
+		return submitError(c, "Failed to queue scan", err)
+	}
+
+	return response.Accepted(c, "Scan queued", job)
+}
+
+// ListProfiles returns the nmap profiles
+// @Summary List Nmap Profiles
+// @Description The built-in profiles (default, quick, full) followed by the ones admins defined. Scans select one with the "profile" option of nmap.
+// @Tags Nmap
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]models.NmapProfile}
+// @Router /nmap/profiles [get]
+func (h *NmapHandler) ListProfiles(c *fiber.Ctx) error {
+	profiles, err := h.profiles.List()
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list nmap profiles", err)
+	}
+	return response.Success(c, "Nmap profiles retrieved", profiles)
+}
+
+// GetProfile returns an nmap profile
+// @Summary Get Nmap Profile
+// @Tags Nmap
+// @Accept json
+// @Produce json
+// @Param name path string true "Profile name"
+// @Success 200 {object} response.Response{data=models.NmapProfile}
+// @Failure 404 {object} response.Response
+// @Router /nmap/profiles/{name} [get]
+func (h *NmapHandler) GetProfile(c *fiber.Ctx) error {
+	profile, err := h.profiles.Get(c.Params("name"))
 	if err != nil {
-		return response.InternalServerError(c, "Scan failed", err)
+		return nmapProfileError(c, err)
 	}
+	return response.Success(c, "Nmap profile retrieved", profile)
+}
 
-	return c.Status(fiber.StatusOK).JSON(result)
+// CreateProfile adds an nmap profile
+// @Summary Create Nmap Profile
+// @Description Define a named set of nmap settings: a TCP port list or top-ports count, the TCP scan technique, UDP and its ports, the timing template (0-5), service detection and its intensity (0-9), OS detection and NSE script categories. Raw flags are not accepted.
+// @Tags Nmap
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.NmapProfileRequest true "Nmap profile"
+// @Success 201 {object} response.Response{data=models.NmapProfile}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /nmap/profiles [post]
+func (h *NmapHandler) CreateProfile(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	var req models.NmapProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	profile, err := h.profiles.Create(userID, req)
+	if err != nil {
+		return nmapProfileError(c, err)
+	}
+	return response.Created(c, "Nmap profile created", profile)
+}
+
+// UpdateProfile replaces the settings of an nmap profile
+// @Summary Update Nmap Profile
+// @Description Built-in profiles cannot be changed
+// @Tags Nmap
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Profile name"
+// @Param request body models.NmapProfileRequest true "Nmap profile"
+// @Success 200 {object} response.Response{data=models.NmapProfile}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /nmap/profiles/{name} [put]
+func (h *NmapHandler) UpdateProfile(c *fiber.Ctx) error {
+	var req models.NmapProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	profile, err := h.profiles.Update(c.Params("name"), req)
+	if err != nil {
+		return nmapProfileError(c, err)
+	}
+	return response.Success(c, "Nmap profile updated", profile)
+}
+
+// DeleteProfile removes an nmap profile
+// @Summary Delete Nmap Profile
+// @Description Built-in profiles cannot be deleted. Scans and schedules selecting the profile fail to queue nmap afterwards.
+// @Tags Nmap
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Profile name"
+// @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /nmap/profiles/{name} [delete]
+func (h *NmapHandler) DeleteProfile(c *fiber.Ctx) error {
+	if err := h.profiles.Delete(c.Params("name")); err != nil {
+		return nmapProfileError(c, err)
+	}
+	return response.Success(c, "Nmap profile deleted", nil)
+}
+
+func nmapProfileError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNmapProfileNotFound):
+		return response.NotFound(c, "Nmap profile not found")
+	case errors.Is(err, service.ErrInvalidNmapProfile):
+		return response.BadRequest(c, err.Error(), err)
+	case errors.Is(err, service.ErrNmapProfileConflict):
+		return response.Error(c, fiber.StatusConflict, "Nmap profile conflict", err.Error())
+	}
+	return response.InternalServerError(c, "Failed to process nmap profile", err)
 }
@@ -1,10 +1,9 @@
 package handler
 
 import (
-	"context"
 	"strings"
-	"time"
 
+	"napscan-be/internal/models"
 	"napscan-be/internal/service"
 	"napscan-be/pkg/response"
 
@@ -12,21 +11,21 @@ import (
 )
 
 type NucleiHandler struct {
-	service *service.NucleiService
+	jobs *service.JobService
 }
 
-func NewNucleiHandler(s *service.NucleiService) *NucleiHandler {
-	return &NucleiHandler{service: s}
+func NewNucleiHandler(jobs *service.JobService) *NucleiHandler {
+	return &NucleiHandler{jobs: jobs}
 }
 
-// StartScan initiates a Nuclei scan
+// StartScan queues a Nuclei scan
 // @Summary Start Nuclei Scan
-// @Description Run Nuclei scan on a target
+// @Description Queue a Nuclei scan on a target. Poll /jobs/{id} for the result.
 // @Tags Nuclei
 // @Accept json
 // @Produce json
 // @Param target body object{target=string} true "Target URL or Hostname"
-// @Success 200 {object} response.Response
+// @Success 202 {object} response.Response{data=models.Job}
 // @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /nuclei/scan [post]
@@ -44,16 +43,10 @@ func (h *NucleiHandler) StartScan(c *fiber.Ctx) error {
 		return response.BadRequest(c, "Target is required", nil)
 	}
 
-	ctx, cancel := context.WithTimeout(c.Context(), 300*time.Second)
-	defer cancel()
-
-	results, err := h.service.ExecuteScan(ctx, req.Target)
+	job, err := h.jobs.Submit(models.JobRequest{Tool: models.ToolNuclei, Target: req.Target})
 	if err != nil {
-		return response.InternalServerError(c, "Nuclei scan failed", err)
+		return submitError(c, "Failed to queue Nuclei scan", err)
 	}
 
-	return response.Success(c, "Scan completed", fiber.Map{
-		"target":  req.Target,
-		"results": results,
-	})
+	return response.Accepted(c, "Scan queued", job)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"napscan-be/internal/models"
 	"napscan-be/internal/service"
 	"napscan-be/pkg/response"
 
@@ -12,10 +13,11 @@ import (
 
 type OpenVASHandler struct {
 	service *service.OpenVASService
+	jobs    *service.JobService
 }
 
-func NewOpenVASHandler(s *service.OpenVASService) *OpenVASHandler {
-	return &OpenVASHandler{service: s}
+func NewOpenVASHandler(s *service.OpenVASService, jobs *service.JobService) *OpenVASHandler {
+	return &OpenVASHandler{service: s, jobs: jobs}
 }
 
 // GetVersion returns OpenVAS version
@@ -39,14 +41,14 @@ func (h *OpenVASHandler) GetVersion(c *fiber.Ctx) error {
 	return c.SendString(ver)
 }
 
-// StartScan initiates an OpenVAS scan
+// StartScan queues an OpenVAS scan
 // @Summary Start OpenVAS Scan
-// @Description Create target, task, and start scan
+// @Description Queue a job that creates target and task, starts the scan and waits for its report. Poll /jobs/{id} for the result.
 // @Tags OpenVAS
 // @Accept json
 // @Produce json
 // @Param body body object{target=string} true "Scan parameters"
-// @Success 200 {object} response.Response
+// @Success 202 {object} response.Response{data=models.Job}
 // @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /openvas/scan [post]
@@ -63,17 +65,12 @@ func (h *OpenVASHandler) StartScan(c *fiber.Ctx) error {
 		return response.BadRequest(c, "Target is required", nil)
 	}
 
-	// No timeout context for start scan as it might take a bit (but creating task is fast usually)
-	// Ideally we use a reasonably long timeout
-	ctx, cancel := context.WithTimeout(c.Context(), 60*time.Second)
-	defer cancel()
-
-	result, err := h.service.StartScan(ctx, req.Target)
+	job, err := h.jobs.Submit(models.JobRequest{Tool: models.ToolOpenVAS, Target: req.Target})
 	if err != nil {
-		return response.InternalServerError(c, "Failed to start OpenVAS scan", err)
+		return submitError(c, "Failed to queue OpenVAS scan", err)
 	}
 
-	return response.Success(c, "Scan started", result)
+	return response.Accepted(c, "Scan queued", job)
 }
 
 // GetTaskStatus returns task status in JSON
@@ -135,6 +132,6 @@ func (h *OpenVASHandler) GetScanReport(c *fiber.Ctx) error {
 	if err != nil {
 		return response.InternalServerError(c, "Failed to get report", err)
 	}
-	
+
 	return c.JSON(report)
 }
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ScanHandler struct {
+	scans *service.ScanService
+}
+
+func NewScanHandler(scans *service.ScanService) *ScanHandler {
+	return &ScanHandler{scans: scans}
+}
+
+// CreateScan starts a multi-tool scan on the server
+// @Summary Start Scan
+// @Description Queue one job per tool against a target under a parent scan. Options are given per tool. With pipeline=true only nmap is queued, and nuclei, ffuf and zap (web services) or sslyze (TLS services) are queued against what it finds. asset_criticality (low, medium, high or critical) weights the risk score of the scan. Poll /scans/{id} for the combined status and job tree.
+// @Tags Scans
+// @Accept json
+// @Produce json
+// @Param request body models.ScanRequest true "Target, tools and per-tool options"
+// @Success 202 {object} response.Response{data=models.Scan}
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /scans [post]
+func (h *ScanHandler) CreateScan(c *fiber.Ctx) error {
+	var req models.ScanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	scan, err := h.scans.Create(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScan) {
+			return response.BadRequest(c, err.Error(), err)
+		}
+		return response.InternalServerError(c, "Failed to start scan", err)
+	}
+
+	return response.Accepted(c, "Scan queued", scan)
+}
+
+// ListScans returns the scans without their jobs
+// @Summary List Scans
+// @Description List multi-tool scans, newest first
+// @Tags Scans
+// @Accept json
+// @Produce json
+// @Param limit query int false "Maximum number of scans" default(100)
+// @Success 200 {object} response.Response{data=[]models.Scan}
+// @Failure 500 {object} response.Response
+// @Router /scans [get]
+func (h *ScanHandler) ListScans(c *fiber.Ctx) error {
+	scans, err := h.scans.List(c.QueryInt("limit", 100))
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list scans", err)
+	}
+
+	return response.Success(c, "Scans retrieved", scans)
+}
+
+// GetScan returns the combined view of a scan
+// @Summary Get Scan
+// @Description Get the combined status and progress of a scan together with its child jobs and their results
+// @Tags Scans
+// @Accept json
+// @Produce json
+// @Param id path string true "Scan ID"
+// @Success 200 {object} response.Response{data=models.Scan}
+// @Failure 404 {object} response.Response
+// @Router /scans/{id} [get]
+func (h *ScanHandler) GetScan(c *fiber.Ctx) error {
+	scan, err := h.scans.Get(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrScanNotFound) {
+			return response.NotFound(c, "Scan not found")
+		}
+		return response.InternalServerError(c, "Failed to get scan", err)
+	}
+
+	return response.Success(c, "Scan retrieved", scan)
+}
+
+// GetScanFindings returns the normalized findings of a scan
+// @Summary Get Scan Findings
+// @Description Findings of every completed job of the scan in one format (tool, rule ID, title, severity, CVSS, CVEs, CWEs, host, port, URL, evidence), most severe first, each with its risk score. Each finding has a fingerprint that stays the same across tools and runs; reports with the same fingerprint are merged into one finding listing its sources unless dedup=false. Findings carry the triage of their fingerprint; false positives and accepted risks score 0. Findings hidden by a suppression rule are left out unless suppressed=true, and name the rule in suppressed_by.
+// @Tags Scans
+// @Accept json
+// @Produce json
+// @Param id path string true "Scan ID"
+// @Param dedup query bool false "Merge duplicate reports (default true)"
+// @Param status query string false "Comma-separated triage statuses to keep, untriaged findings are open"
+// @Param suppressed query bool false "Include the findings hidden by suppression rules (default false)"
+// @Success 200 {object} response.Response{data=[]models.Finding}
+// @Failure 404 {object} response.Response
+// @Router /scans/{id}/findings [get]
+func (h *ScanHandler) GetScanFindings(c *fiber.Ctx) error {
+	var statuses []models.TriageStatus
+	for _, status := range strings.Split(c.Query("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses = append(statuses, models.TriageStatus(status))
+		}
+	}
+
+	findings, err := h.scans.Findings(c.Params("id"), service.FindingsFilter{
+		Dedup:      c.QueryBool("dedup", true),
+		Statuses:   statuses,
+		Suppressed: c.QueryBool("suppressed", false),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrScanNotFound) {
+			return response.NotFound(c, "Scan not found")
+		}
+		return response.InternalServerError(c, "Failed to get findings", err)
+	}
+
+	return response.Success(c, "Findings retrieved", findings)
+}
+
+// GetScanRisk returns the risk score of a scan
+// @Summary Get Scan Risk
+// @Description Score the deduplicated findings of a scan from 0 to 100 and combine them per host and for the scan. Finding scores start from the CVSS base score (or a configured score per severity) and are scaled by the OpenVAS quality of detection, known exploits and the asset criticality of the scan. Every score lists the steps that derived it.
+// @Tags Scans
+// @Accept json
+// @Produce json
+// @Param id path string true "Scan ID"
+// @Success 200 {object} response.Response{data=models.ScanRisk}
+// @Failure 404 {object} response.Response
+// @Router /scans/{id}/risk [get]
+func (h *ScanHandler) GetScanRisk(c *fiber.Ctx) error {
+	risk, err := h.scans.Risk(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrScanNotFound) {
+			return response.NotFound(c, "Scan not found")
+		}
+		return response.InternalServerError(c, "Failed to score scan", err)
+	}
+
+	return response.Success(c, "Scan risk retrieved", risk)
+}
+
+// GetScanDiff compares a scan with an earlier scan
+// @Summary Diff Scans
+// @Description Compare the findings of a scan with an earlier scan on their fingerprint: new, resolved and persisting findings. Findings of the earlier scan whose tools did not complete again are listed as not rescanned instead of resolved. When both scans ran nmap, hosts that came up or went down and ports that opened, closed or changed service are listed too, like ndiff.
+// @Tags Scans
+// @Accept json
+// @Produce json
+// @Param id path string true "Scan ID"
+// @Param against query string true "ID of the earlier scan"
+// @Success 200 {object} response.Response{data=models.ScanDiff}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /scans/{id}/diff [get]
+func (h *ScanHandler) GetScanDiff(c *fiber.Ctx) error {
+	diff, err := h.scans.Diff(c.Params("id"), c.Query("against"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScan) {
+			return response.BadRequest(c, err.Error(), err)
+		}
+		if errors.Is(err, service.ErrScanNotFound) {
+			return response.NotFound(c, "Scan not found")
+		}
+		return response.InternalServerError(c, "Failed to diff scans", err)
+	}
+
+	return response.Success(c, "Scan diff retrieved", diff)
+}
+
+// CancelScan cancels the unfinished jobs of a scan
+// @Summary Cancel Scan
+// @Tags Scans
+// @Accept json
+// @Produce json
+// @Param id path string true "Scan ID"
+// @Success 200 {object} response.Response{data=models.Scan}
+// @Failure 404 {object} response.Response
+// @Router /scans/{id} [delete]
+func (h *ScanHandler) CancelScan(c *fiber.Ctx) error {
+	scan, err := h.scans.Cancel(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrScanNotFound) {
+			return response.NotFound(c, "Scan not found")
+		}
+		return response.InternalServerError(c, "Failed to cancel scan", err)
+	}
+
+	return response.Success(c, "Scan cancellation requested", scan)
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"errors"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ScheduleHandler struct {
+	schedules *service.ScheduleService
+}
+
+func NewScheduleHandler(schedules *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{schedules: schedules}
+}
+
+// currentUserID returns the user ID set by AuthMiddleware
+func currentUserID(c *fiber.Ctx) (string, bool) {
+	userID, ok := c.Locals("user_id").(string)
+	return userID, ok && userID != ""
+}
+
+// ListSchedules returns the schedules of the current user
+// @Summary List Schedules
+// @Description List the recurring scan schedules owned by the current user
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.Schedule}
+// @Failure 401 {object} response.Response
+// @Router /schedules [get]
+func (h *ScheduleHandler) ListSchedules(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	schedules, err := h.schedules.List(userID)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list schedules", err)
+	}
+
+	return response.Success(c, "Schedules retrieved", schedules)
+}
+
+// CreateSchedule adds a recurring scan
+// @Summary Create Schedule
+// @Description Create a schedule that starts a scan with one job per tool whenever the cron expression fires, so runs can be diffed against each other. Standard 5-field expressions and descriptors such as @weekly are accepted.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ScheduleRequest true "Schedule"
+// @Success 201 {object} response.Response{data=models.Schedule}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /schedules [post]
+func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	var req models.ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	schedule, err := h.schedules.Create(userID, req)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return response.Created(c, "Schedule created", schedule)
+}
+
+// GetSchedule returns a schedule of the current user
+// @Summary Get Schedule
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} response.Response{data=models.Schedule}
+// @Failure 404 {object} response.Response
+// @Router /schedules/{id} [get]
+func (h *ScheduleHandler) GetSchedule(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	schedule, err := h.schedules.Get(userID, c.Params("id"))
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return response.Success(c, "Schedule retrieved", schedule)
+}
+
+// UpdateSchedule replaces a schedule of the current user
+// @Summary Update Schedule
+// @Description Replace a schedule. The next run is recomputed from the new cron expression.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Schedule ID"
+// @Param request body models.ScheduleRequest true "Schedule"
+// @Success 200 {object} response.Response{data=models.Schedule}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /schedules/{id} [put]
+func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	var req models.ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	schedule, err := h.schedules.Update(userID, c.Params("id"), req)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return response.Success(c, "Schedule updated", schedule)
+}
+
+// DeleteSchedule removes a schedule of the current user
+// @Summary Delete Schedule
+// @Description Delete a schedule and its run history. Jobs it already queued keep running.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /schedules/{id} [delete]
+func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	if err := h.schedules.Delete(userID, c.Params("id")); err != nil {
+		return scheduleError(c, err)
+	}
+
+	return response.Success(c, "Schedule deleted", nil)
+}
+
+// ListScheduleRuns returns the run history of a schedule
+// @Summary List Schedule Runs
+// @Description List the runs of a schedule, newest first. Skipped runs fired while the previous run was still in progress.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Schedule ID"
+// @Param limit query int false "Maximum number of runs" default(50)
+// @Success 200 {object} response.Response{data=[]models.ScheduleRun}
+// @Failure 404 {object} response.Response
+// @Router /schedules/{id}/runs [get]
+func (h *ScheduleHandler) ListScheduleRuns(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	runs, err := h.schedules.Runs(userID, c.Params("id"), c.QueryInt("limit", 50))
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return response.Success(c, "Schedule runs retrieved", runs)
+}
+
+func scheduleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrScheduleNotFound):
+		return response.NotFound(c, "Schedule not found")
+	case errors.Is(err, service.ErrInvalidSchedule):
+		return response.BadRequest(c, err.Error(), err)
+	}
+	return response.InternalServerError(c, "Failed to process schedule", err)
+}
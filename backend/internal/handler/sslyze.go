@@ -1,9 +1,7 @@
 package handler
 
 import (
-	"context"
-	"time"
-
+	"napscan-be/internal/models"
 	"napscan-be/internal/service"
 	"napscan-be/pkg/response"
 
@@ -11,21 +9,21 @@ import (
 )
 
 type SslyzeHandler struct {
-	service *service.SslyzeService
+	jobs *service.JobService
 }
 
-func NewSslyzeHandler(s *service.SslyzeService) *SslyzeHandler {
-	return &SslyzeHandler{service: s}
+func NewSslyzeHandler(jobs *service.JobService) *SslyzeHandler {
+	return &SslyzeHandler{jobs: jobs}
 }
 
-// StartScan initiates an SSLyze scan
+// StartScan queues an SSLyze scan
 // @Summary Start SSLyze Scan
-// @Description Run SSL/TLS configuration analysis
+// @Description Queue SSL/TLS configuration analysis. Poll /jobs/{id} for the result.
 // @Tags SSLyze
 // @Accept json
 // @Produce json
 // @Param target body object{target=string} true "Target Host:Port"
-// @Success 200 {object} response.Response
+// @Success 202 {object} response.Response{data=models.Job}
 // @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /sslyze/scan [post]
@@ -42,13 +40,10 @@ func (h *SslyzeHandler) StartScan(c *fiber.Ctx) error {
 		return response.BadRequest(c, "Target is required", nil)
 	}
 
-	ctx, cancel := context.WithTimeout(c.Context(), 120*time.Second)
-	defer cancel()
-
-	result, err := h.service.ExecuteScan(ctx, req.Target)
+	job, err := h.jobs.Submit(models.JobRequest{Tool: models.ToolSslyze, Target: req.Target})
 	if err != nil {
-		return response.InternalServerError(c, "SSLyze scan failed", err)
+		return submitError(c, "Failed to queue SSLyze scan", err)
 	}
 
-	return response.Success(c, "Scan completed", result)
+	return response.Accepted(c, "Scan queued", job)
 }
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SuppressionHandler struct {
+	suppressions *service.SuppressionService
+}
+
+func NewSuppressionHandler(suppressions *service.SuppressionService) *SuppressionHandler {
+	return &SuppressionHandler{suppressions: suppressions}
+}
+
+// ListSuppressions returns the suppression rules
+// @Summary List Suppression Rules
+// @Description Rules that hide matching findings from scan findings, risk scores, diffs and batch analyses, oldest first
+// @Tags Suppressions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.SuppressionRule}
+// @Router /suppressions [get]
+func (h *SuppressionHandler) ListSuppressions(c *fiber.Ctx) error {
+	rules, err := h.suppressions.List()
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list suppression rules", err)
+	}
+	return response.Success(c, "Suppression rules retrieved", rules)
+}
+
+// CreateSuppression adds a suppression rule
+// @Summary Create Suppression Rule
+// @Description Hide the findings matching every matcher the rule sets: tool, rule ID (a nuclei template ID also matches its matchers), host glob such as *.staging.example.com, port, a regular expression on the URL path and severity. At least one matcher is required. The rule stops applying at expires_at. Rules apply to the findings of scan jobs as they finish, scans ingested before keep their findings as they were. Hidden findings are kept and name the rule in suppressed_by.
+// @Tags Suppressions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.SuppressionRuleRequest true "Suppression rule"
+// @Success 201 {object} response.Response{data=models.SuppressionRule}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /suppressions [post]
+func (h *SuppressionHandler) CreateSuppression(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	var req models.SuppressionRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	rule, err := h.suppressions.Create(userID, req)
+	if err != nil {
+		return suppressionError(c, err)
+	}
+	return response.Created(c, "Suppression rule created", rule)
+}
+
+// GetSuppression returns a suppression rule
+// @Summary Get Suppression Rule
+// @Tags Suppressions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Suppression rule ID"
+// @Success 200 {object} response.Response{data=models.SuppressionRule}
+// @Failure 404 {object} response.Response
+// @Router /suppressions/{id} [get]
+func (h *SuppressionHandler) GetSuppression(c *fiber.Ctx) error {
+	rule, err := h.suppressions.Get(c.Params("id"))
+	if err != nil {
+		return suppressionError(c, err)
+	}
+	return response.Success(c, "Suppression rule retrieved", rule)
+}
+
+// UpdateSuppression replaces a suppression rule
+// @Summary Update Suppression Rule
+// @Tags Suppressions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Suppression rule ID"
+// @Param request body models.SuppressionRuleRequest true "Suppression rule"
+// @Success 200 {object} response.Response{data=models.SuppressionRule}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /suppressions/{id} [put]
+func (h *SuppressionHandler) UpdateSuppression(c *fiber.Ctx) error {
+	var req models.SuppressionRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	rule, err := h.suppressions.Update(c.Params("id"), req)
+	if err != nil {
+		return suppressionError(c, err)
+	}
+	return response.Success(c, "Suppression rule updated", rule)
+}
+
+// DeleteSuppression removes a suppression rule
+// @Summary Delete Suppression Rule
+// @Description Remove a rule. The findings it hid in scans ingested before stay hidden, it no longer hides those of scans ingested after.
+// @Tags Suppressions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Suppression rule ID"
+// @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /suppressions/{id} [delete]
+func (h *SuppressionHandler) DeleteSuppression(c *fiber.Ctx) error {
+	if err := h.suppressions.Delete(c.Params("id")); err != nil {
+		return suppressionError(c, err)
+	}
+	return response.Success(c, "Suppression rule deleted", nil)
+}
+
+func suppressionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrSuppressionNotFound):
+		return response.NotFound(c, "Suppression rule not found")
+	case errors.Is(err, service.ErrInvalidSuppression):
+		return response.BadRequest(c, err.Error(), err)
+	}
+	return response.InternalServerError(c, "Failed to process suppression rule", err)
+}
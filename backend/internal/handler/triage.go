@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"errors"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+	"napscan-be/internal/service"
+	"napscan-be/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TriageHandler struct {
+	triage *service.TriageService
+}
+
+func NewTriageHandler(triage *service.TriageService) *TriageHandler {
+	return &TriageHandler{triage: triage}
+}
+
+// ListTriage returns the triaged findings
+// @Summary List Finding Triage
+// @Description Triage states of findings, most recently updated first. Filter by status or assignee, e.g. assignee=me for the findings assigned to the current user.
+// @Tags Findings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Triage status" Enums(open, confirmed, false_positive, accepted_risk, fixed, reopened)
+// @Param assignee query string false "Assignee, or me"
+// @Param limit query int false "Maximum number of entries" default(100)
+// @Success 200 {object} response.Response{data=[]models.FindingTriage}
+// @Failure 401 {object} response.Response
+// @Router /findings/triage [get]
+func (h *TriageHandler) ListTriage(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	filter := repository.TriageFilter{
+		Status:   models.TriageStatus(c.Query("status")),
+		Assignee: c.Query("assignee"),
+		Limit:    c.QueryInt("limit", 100),
+	}
+	if filter.Assignee == "me" {
+		filter.Assignee = userID
+	}
+
+	triages, err := h.triage.List(filter)
+	if err != nil {
+		return response.InternalServerError(c, "Failed to list triage", err)
+	}
+	return response.Success(c, "Triage retrieved", triages)
+}
+
+// GetTriage returns the triage of a finding
+// @Summary Get Finding Triage
+// @Description Status, assignee and justification of a finding fingerprint, with its status transitions and threaded comments. Findings nobody triaged yet are open.
+// @Tags Findings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param fingerprint path string true "Finding fingerprint"
+// @Success 200 {object} response.Response{data=models.FindingTriage}
+// @Failure 400 {object} response.Response
+// @Router /findings/{fingerprint}/triage [get]
+func (h *TriageHandler) GetTriage(c *fiber.Ctx) error {
+	triage, err := h.triage.Get(c.Params("fingerprint"))
+	if err != nil {
+		return triageError(c, err)
+	}
+	return response.Success(c, "Triage retrieved", triage)
+}
+
+// UpdateTriage changes the triage of a finding
+// @Summary Update Finding Triage
+// @Description Move a finding to another status, assign it or update its justification. The triage follows the fingerprint into later scans. false_positive and accepted_risk need a justification; accepted_risk also needs an accepted_until date in the future, after which the finding is reopened. Fixed findings are reopened when a later job reports them again.
+// @Tags Findings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param fingerprint path string true "Finding fingerprint"
+// @Param request body models.TriageUpdateRequest true "Triage change"
+// @Success 200 {object} response.Response{data=models.FindingTriage}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /findings/{fingerprint}/triage [put]
+func (h *TriageHandler) UpdateTriage(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	var req models.TriageUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	triage, err := h.triage.Update(userID, c.Params("fingerprint"), req)
+	if err != nil {
+		return triageError(c, err)
+	}
+	return response.Success(c, "Triage updated", triage)
+}
+
+// CreateComment comments on a finding
+// @Summary Comment on Finding
+// @Description Add a comment to a finding fingerprint, or a reply to one of its comments with parent_id
+// @Tags Findings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param fingerprint path string true "Finding fingerprint"
+// @Param request body models.CommentRequest true "Comment"
+// @Success 201 {object} response.Response{data=models.FindingComment}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /findings/{fingerprint}/comments [post]
+func (h *TriageHandler) CreateComment(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return response.Unauthorized(c, "User ID not found in session")
+	}
+
+	var req models.CommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "Invalid request payload", err)
+	}
+
+	comment, err := h.triage.Comment(userID, c.Params("fingerprint"), req)
+	if err != nil {
+		return triageError(c, err)
+	}
+	return response.Created(c, "Comment added", comment)
+}
+
+func triageError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrInvalidTriage) {
+		return response.BadRequest(c, err.Error(), err)
+	}
+	return response.InternalServerError(c, "Failed to process triage", err)
+}
@@ -1,11 +1,9 @@
 package handler
 
 import (
-	"context"
-	"net/url"
 	"strings"
-	"time"
 
+	"napscan-be/internal/models"
 	"napscan-be/internal/service"
 	"napscan-be/pkg/response"
 
@@ -13,21 +11,21 @@ import (
 )
 
 type ZapHandler struct {
-	service *service.ZapService
+	jobs *service.JobService
 }
 
-func NewZapHandler(s *service.ZapService) *ZapHandler {
-	return &ZapHandler{service: s}
+func NewZapHandler(jobs *service.JobService) *ZapHandler {
+	return &ZapHandler{jobs: jobs}
 }
 
-// StartScan initiates a full ZAP scan
+// StartScan queues a full ZAP scan
 // @Summary Start ZAP Scan
-// @Description Run ZAP Spider and Active Scan
+// @Description Queue ZAP Spider and Active Scan. Poll /jobs/{id} for the result.
 // @Tags ZAP
 // @Accept json
 // @Produce json
 // @Param target body object{target=string} true "Target URL"
-// @Success 200 {object} response.Response
+// @Success 202 {object} response.Response{data=models.Job}
 // @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /zap/scan [post]
@@ -44,20 +42,11 @@ func (h *ZapHandler) StartScan(c *fiber.Ctx) error {
 	if target == "" {
 		return response.BadRequest(c, "Target is required", nil)
 	}
-	if !strings.HasPrefix(strings.ToLower(target), "http://") && !strings.HasPrefix(strings.ToLower(target), "https://") {
-		target = "https://" + target
-	}
-	if _, err := url.ParseRequestURI(target); err != nil {
-		return response.BadRequest(c, "Invalid request URL", err)
-	}
-
-	ctx, cancel := context.WithTimeout(c.Context(), 300*time.Second)
-	defer cancel()
 
-	result, err := h.service.ExecuteFullScan(ctx, target)
+	job, err := h.jobs.Submit(models.JobRequest{Tool: models.ToolZap, Target: target})
 	if err != nil {
-		return response.InternalServerError(c, "ZAP scan failed", err)
+		return submitError(c, "Failed to queue ZAP scan", err)
 	}
 
-	return response.Success(c, "ZAP scan completed", result)
+	return response.Accepted(c, "ZAP scan queued", job)
 }
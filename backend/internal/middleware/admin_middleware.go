@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminMiddleware lets through users whose email is listed in NAPSCAN_ADMIN_EMAILS
+// (comma separated). It must run after AuthMiddleware.
+func AdminMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		email, _ := c.Locals("email").(string)
+		if !IsAdmin(email) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Admin access required",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// IsAdmin reports whether email is listed in NAPSCAN_ADMIN_EMAILS
+func IsAdmin(email string) bool {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return false
+	}
+	for _, admin := range strings.Split(os.Getenv("NAPSCAN_ADMIN_EMAILS"), ",") {
+		if strings.EqualFold(strings.TrimSpace(admin), email) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // BatchStatus indicates the progress of the batch
 type BatchStatus string
@@ -8,28 +11,50 @@ type BatchStatus string
 const (
 	BatchStatusProcessing BatchStatus = "processing"
 	BatchStatusComplete   BatchStatus = "complete"
+	// BatchStatusExpired marks a batch whose TTL ran out; its results were dropped
+	BatchStatusExpired BatchStatus = "expired"
 )
 
 // Batch represents the aggregated state of multiple API requests
 type Batch struct {
-	UserID         string                 `json:"user_id"`
-	BatchID        string                 `json:"batch_id"`
+	UserID  string `json:"user_id"`
+	BatchID string `json:"batch_id"`
+	// Sources are the tools whose results the batch waits for, declared at creation
+	Sources        []string               `json:"sources"`
 	ExpectedCount  int                    `json:"expected_count"`
 	ReceivedCount  int                    `json:"received_count"`
 	Results        map[string]interface{} `json:"results"`
 	Status         BatchStatus            `json:"status"`
-	AnalysisResult interface{}            `json:"analysis_result,omitempty"`
+	AnalysisResult *BatchAnalysis         `json:"analysis_result,omitempty"`
 	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// BatchCreateRequest opens a batch that completes once every source has reported
+type BatchCreateRequest struct {
+	// BatchID is optional, a new ID is generated when it is empty
+	BatchID string   `json:"batch_id,omitempty"`
+	Sources []string `json:"sources"`
 }
 
-// BatchRequest represents the input for fan-in endpoints
-type BatchRequest struct {
-	Data string `json:"data"`
+// BatchResultRequest contributes the result of one source, either from a finished
+// job or as the raw result of the tool
+type BatchResultRequest struct {
+	JobID  string          `json:"job_id,omitempty"`
+	Result json.RawMessage `json:"result,omitempty" swaggertype:"object"`
 }
 
-// BatchResponse returns the status of an analysis
-type BatchResponse struct {
-	Status         BatchStatus `json:"status"`
-	AnalysisResult interface{} `json:"result,omitempty"`
-	BatchID        string      `json:"batch_id"`
+// BatchAnalysis is the combined view of the results once every source has reported
+type BatchAnalysis struct {
+	// Findings counts the findings of all sources per severity
+	Findings map[Severity]int `json:"findings"`
+	Total    int              `json:"total"`
+	// Suppressed counts the findings hidden by suppression rules, left out of the rest
+	Suppressed int `json:"suppressed"`
+	// Sources counts the findings per source and severity
+	Sources map[string]map[Severity]int `json:"sources"`
+	// RiskScore goes from 0 (nothing found) to 100, Risk explains it per host
+	RiskScore  float64   `json:"risk_score"`
+	Risk       *ScanRisk `json:"risk,omitempty"`
+	AnalyzedAt time.Time `json:"analyzed_at"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// CVEDetail is what the local NVD, KEV and EPSS feeds know about a CVE
+type CVEDetail struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description,omitempty"`
+	CVSS        float64    `json:"cvss,omitempty"`
+	CVSSVector  string     `json:"cvss_vector,omitempty"`
+	CWEs        []string   `json:"cwes,omitempty"`
+	Published   *time.Time `json:"published,omitempty"`
+	// KEV is set when the CVE is in the CISA Known Exploited Vulnerabilities catalog
+	KEV          bool       `json:"kev"`
+	KEVDateAdded *time.Time `json:"kev_date_added,omitempty"`
+	// EPSS is the probability of exploitation in the next 30 days, from 0 to 1
+	EPSS           float64 `json:"epss,omitempty"`
+	EPSSPercentile float64 `json:"epss_percentile,omitempty"`
+}
+
+// FeedStatus describes one locally stored vulnerability feed
+type FeedStatus struct {
+	Feed    string `json:"feed"`
+	Entries int    `json:"entries"`
+	// Files are the feed files read from the data directory
+	Files []string `json:"files"`
+	// Version is the KEV catalog version or the EPSS score date
+	Version  string     `json:"version,omitempty"`
+	LoadedAt *time.Time `json:"loaded_at,omitempty"`
+}
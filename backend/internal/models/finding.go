@@ -0,0 +1,50 @@
+package models
+
+// Finding is one issue reported by a tool, normalized so results of different tools
+// can be listed, counted and compared together
+type Finding struct {
+	// Fingerprint identifies the issue on its asset. It is the same for every tool
+	// that reports the issue and for every run that finds it again.
+	Fingerprint string `json:"fingerprint"`
+	Tool        string `json:"tool"`
+	JobID       string `json:"job_id,omitempty"`
+	// RuleID identifies the check that fired: nuclei template, ZAP plugin, OpenVAS NVT OID...
+	RuleID      string   `json:"rule_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Severity    Severity `json:"severity"`
+	CVSS        float64  `json:"cvss,omitempty"`
+	CVSSVector  string   `json:"cvss_vector,omitempty"`
+	// QoD is how sure the tool is of the finding in percent, 0 when the tool does not say
+	QoD int `json:"qod,omitempty"`
+	// KnownExploit marks issues with a public or in-the-wild exploit
+	KnownExploit bool     `json:"known_exploit,omitempty"`
+	CVEs         []string `json:"cves,omitempty"`
+	CWEs         []string `json:"cwes,omitempty"`
+	Host         string   `json:"host,omitempty"`
+	Port         string   `json:"port,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	Evidence     string   `json:"evidence,omitempty"`
+	// RawRef locates the finding in the native result of the job, e.g. "results[3]"
+	RawRef string `json:"raw_ref,omitempty"`
+	// CVEDetails holds what the local vulnerability feeds know about each CVE
+	CVEDetails []CVEDetail `json:"cve_details,omitempty"`
+	// Sources lists every report merged into this finding, the finding itself included
+	Sources []FindingSource `json:"sources,omitempty"`
+	// Risk is only filled in when the finding is scored as part of a scan
+	Risk *RiskScore `json:"risk,omitempty"`
+	// Triage is the triage state of the fingerprint, nil while the finding is untriaged
+	Triage *FindingTriage `json:"triage,omitempty"`
+	// SuppressedBy is the ID of the suppression rule that hides the finding
+	SuppressedBy string `json:"suppressed_by,omitempty"`
+}
+
+// FindingSource is one tool report of a deduplicated finding
+type FindingSource struct {
+	Tool   string `json:"tool"`
+	JobID  string `json:"job_id,omitempty"`
+	RuleID string `json:"rule_id"`
+	RawRef string `json:"raw_ref,omitempty"`
+	// SuppressedBy is the ID of the suppression rule that hides this report
+	SuppressedBy string `json:"suppressed_by,omitempty"`
+}
@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// Tool names accepted by the job subsystem
+const (
+	ToolNmap    = "nmap"
+	ToolZap     = "zap"
+	ToolNuclei  = "nuclei"
+	ToolFfuf    = "ffuf"
+	ToolSslyze  = "sslyze"
+	ToolOpenVAS = "openvas"
+)
+
+// JobStatus indicates the lifecycle state of a scan job
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+	// JobStatusInterrupted marks a local tool run that was lost when the backend restarted
+	JobStatusInterrupted JobStatus = "interrupted"
+)
+
+// IsTerminal reports whether the job has stopped and will not change anymore
+func (s JobStatus) IsTerminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled || s == JobStatusInterrupted
+}
+
+// JobRequest describes a scan job to be started in the background
+type JobRequest struct {
+	Tool    string            `json:"tool"`
+	Target  string            `json:"target"`
+	Options map[string]string `json:"options,omitempty"`
+	// ScanID links the job to the parent scan that created it
+	ScanID string `json:"scan_id,omitempty"`
+	// ParentID is the job whose results produced this one (pipeline scans)
+	ParentID string `json:"parent_id,omitempty"`
+}
+
+// Job represents a single tool run executed in the background
+type Job struct {
+	ID      string            `json:"id"`
+	Tool    string            `json:"tool"`
+	Target  string            `json:"target"`
+	Options map[string]string `json:"options,omitempty"`
+	ScanID  string            `json:"scan_id,omitempty"`
+	// ParentID is the job whose results produced this one (pipeline scans)
+	ParentID string    `json:"parent_id,omitempty"`
+	Status   JobStatus `json:"status"`
+	// Progress is the percent complete (0-100)
+	Progress int    `json:"progress"`
+	Message  string `json:"message,omitempty"`
+	// Refs holds identifiers of the scan inside external daemons (OpenVAS task, ZAP scan IDs)
+	Refs map[string]string `json:"refs,omitempty"`
+	// Shards is the progress of the parts a range scan was split into
+	Shards []JobShard `json:"shards,omitempty"`
+	// Suppressed names the suppression rule that hid a finding of the job when the job
+	// finished, keyed by the fingerprint and raw reference of the finding
+	Suppressed map[string]string `json:"suppressed,omitempty"`
+	Result     interface{}       `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// JobShard is one part of a job that scans the live hosts of a network in parallel parts
+type JobShard struct {
+	Index int `json:"index"`
+	// Hosts is the number of hosts in the shard, From and To the first and last of them
+	Hosts    int       `json:"hosts"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Status   JobStatus `json:"status"`
+	Progress int       `json:"progress"`
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// ToolOutput is a native output file produced by a tool (nmap XML, nuclei JSONL, ...)
+type ToolOutput struct {
+	JobID       string    `json:"job_id"`
+	Name        string    `json:"name"`
+	Tool        string    `json:"tool"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	Content     []byte    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// QueueEntry is a job waiting for a free worker; Position 1 starts next
+type QueueEntry struct {
+	Position int  `json:"position"`
+	Job      *Job `json:"job"`
+}
+
+// QueueStatus is a snapshot of the worker pool
+type QueueStatus struct {
+	Running       []*Job         `json:"running"`
+	Queued        []QueueEntry   `json:"queued"`
+	MaxConcurrent int            `json:"max_concurrent"`
+	ToolLimits    map[string]int `json:"tool_limits"`
+	RunningByTool map[string]int `json:"running_by_tool"`
+}
@@ -1,33 +1,233 @@
 package models
 
+// NmapRun is the XML report of one nmap run (-oX)
 type NmapRun struct {
-	Hosts []Host `xml:"host" json:"hosts"`
+	Scanner          string `xml:"scanner,attr" json:"scanner,omitempty"`
+	Args             string `xml:"args,attr" json:"args,omitempty"`
+	Version          string `xml:"version,attr" json:"version,omitempty"`
+	XMLOutputVersion string `xml:"xmloutputversion,attr" json:"xmloutputversion,omitempty"`
+	// Start is when the run started, in Unix seconds
+	Start    int64      `xml:"start,attr" json:"start,omitempty"`
+	StartStr string     `xml:"startstr,attr" json:"startstr,omitempty"`
+	ScanInfo []ScanInfo `xml:"scaninfo" json:"scaninfo,omitempty"`
+	// PreScripts and PostScripts are the NSE scripts that ran before and after the hosts
+	PreScripts  []Script `xml:"prescript>script" json:"prescripts,omitempty"`
+	Hosts       []Host   `xml:"host" json:"hosts"`
+	PostScripts []Script `xml:"postscript>script" json:"postscripts,omitempty"`
+	RunStats    RunStats `xml:"runstats" json:"runstats"`
+}
+
+// ScanInfo describes one scan type of the run, e.g. a syn scan of 1000 tcp ports
+type ScanInfo struct {
+	Type        string `xml:"type,attr" json:"type"`
+	Protocol    string `xml:"protocol,attr" json:"protocol"`
+	NumServices int    `xml:"numservices,attr" json:"numservices"`
+	// Services lists the ports scanned, e.g. "1-1024,8080"
+	Services string `xml:"services,attr" json:"services,omitempty"`
+}
+
+// RunStats is how the run ended and how many hosts were up
+type RunStats struct {
+	Finished Finished  `xml:"finished" json:"finished"`
+	Hosts    HostStats `xml:"hosts" json:"hosts"`
+}
+
+type Finished struct {
+	// Time is when the run finished, in Unix seconds
+	Time    int64  `xml:"time,attr" json:"time,omitempty"`
+	TimeStr string `xml:"timestr,attr" json:"timestr,omitempty"`
+	// Elapsed is the duration of the run in seconds
+	Elapsed float64 `xml:"elapsed,attr" json:"elapsed,omitempty"`
+	Summary string  `xml:"summary,attr" json:"summary,omitempty"`
+	// Exit is "success" or "error", ErrorMsg says why
+	Exit     string `xml:"exit,attr" json:"exit,omitempty"`
+	ErrorMsg string `xml:"errormsg,attr" json:"errormsg,omitempty"`
+}
+
+type HostStats struct {
+	Up    int `xml:"up,attr" json:"up"`
+	Down  int `xml:"down,attr" json:"down"`
+	Total int `xml:"total,attr" json:"total"`
 }
 
 type Host struct {
-	Addresses []Address `xml:"address" json:"addresses"`
-	Ports     Ports     `xml:"ports" json:"ports"`
+	// StartTime and EndTime bound the scan of the host, in Unix seconds
+	StartTime int64      `xml:"starttime,attr" json:"starttime,omitempty"`
+	EndTime   int64      `xml:"endtime,attr" json:"endtime,omitempty"`
+	Status    HostStatus `xml:"status" json:"status"`
+	Addresses []Address  `xml:"address" json:"addresses"`
+	Hostnames []Hostname `xml:"hostnames>hostname" json:"hostnames,omitempty"`
+	Ports     Ports      `xml:"ports" json:"ports"`
+	// OS, Uptime and Distance need -O
+	OS       *OS       `xml:"os" json:"os,omitempty"`
+	Uptime   *Uptime   `xml:"uptime" json:"uptime,omitempty"`
+	Distance *Distance `xml:"distance" json:"distance,omitempty"`
+	// HostScripts are the NSE scripts that ran against the host rather than a port
+	HostScripts []Script `xml:"hostscript>script" json:"hostscripts,omitempty"`
+	// Trace needs --traceroute
+	Trace *Trace `xml:"trace" json:"trace,omitempty"`
+	Times *Times `xml:"times" json:"times,omitempty"`
+}
+
+// HostStatus tells whether the host is up and why nmap thinks so
+type HostStatus struct {
+	State     string `xml:"state,attr" json:"state"`
+	Reason    string `xml:"reason,attr" json:"reason,omitempty"`
+	ReasonTTL int    `xml:"reason_ttl,attr" json:"reason_ttl,omitempty"`
 }
 
 type Address struct {
-	Addr string `xml:"addr,attr" json:"addr"`
+	Addr     string `xml:"addr,attr" json:"addr"`
+	AddrType string `xml:"addrtype,attr" json:"addrtype,omitempty"`
+	// Vendor is the NIC vendor of a MAC address
+	Vendor string `xml:"vendor,attr" json:"vendor,omitempty"`
+}
+
+type Hostname struct {
+	Name string `xml:"name,attr" json:"name"`
+	// Type is "user" for the name given on the command line and "PTR" for reverse DNS
+	Type string `xml:"type,attr" json:"type,omitempty"`
 }
 
 type Ports struct {
-	Ports []Port `xml:"port" json:"ports"`
+	// ExtraPorts summarizes the ports not listed one by one, e.g. 995 closed ports
+	ExtraPorts []ExtraPorts `xml:"extraports" json:"extraports,omitempty"`
+	Ports      []Port       `xml:"port" json:"ports"`
+}
+
+type ExtraPorts struct {
+	State   string         `xml:"state,attr" json:"state"`
+	Count   int            `xml:"count,attr" json:"count"`
+	Reasons []ExtraReasons `xml:"extrareasons" json:"reasons,omitempty"`
+}
+
+type ExtraReasons struct {
+	Reason string `xml:"reason,attr" json:"reason"`
+	Count  int    `xml:"count,attr" json:"count"`
 }
 
 type Port struct {
-	PortID  string  `xml:"portid,attr" json:"port"`
-	Proto   string  `xml:"protocol,attr" json:"protocol"`
-	State   State   `xml:"state"`
-	Service Service `xml:"service"`
+	PortID  string   `xml:"portid,attr" json:"port"`
+	Proto   string   `xml:"protocol,attr" json:"protocol"`
+	State   State    `xml:"state"`
+	Service Service  `xml:"service"`
+	Scripts []Script `xml:"script" json:"scripts,omitempty"`
 }
 
+// State is the state of a port and the response that determined it, e.g. open
+// because of a syn-ack
 type State struct {
-	State string `xml:"state,attr" json:"state"`
+	State     string `xml:"state,attr" json:"state"`
+	Reason    string `xml:"reason,attr" json:"reason,omitempty"`
+	ReasonTTL int    `xml:"reason_ttl,attr" json:"reason_ttl,omitempty"`
+	ReasonIP  string `xml:"reason_ip,attr" json:"reason_ip,omitempty"`
 }
 
+// Service is what nmap found running on a port; product, version and CPEs need -sV
 type Service struct {
+	Name      string `xml:"name,attr" json:"name"`
+	Product   string `xml:"product,attr" json:"product,omitempty"`
+	Version   string `xml:"version,attr" json:"version,omitempty"`
+	ExtraInfo string `xml:"extrainfo,attr" json:"extrainfo,omitempty"`
+	OSType    string `xml:"ostype,attr" json:"ostype,omitempty"`
+	Hostname  string `xml:"hostname,attr" json:"hostname,omitempty"`
+	// DeviceType is the kind of device the service runs on, e.g. "router" or "printer"
+	DeviceType string `xml:"devicetype,attr" json:"devicetype,omitempty"`
+	// Method is "probed" when -sV identified the service and "table" when nmap only
+	// guessed it from the port number; Conf is its confidence from 0 to 10
+	Method string `xml:"method,attr" json:"method,omitempty"`
+	Conf   int    `xml:"conf,attr" json:"conf,omitempty"`
+	// Tunnel is "ssl" for services nmap found behind TLS (e.g. https is name="http" tunnel="ssl")
+	Tunnel string `xml:"tunnel,attr" json:"tunnel,omitempty"`
+	// CPEs identify the product, e.g. "cpe:/a:openbsd:openssh:8.2p1"
+	CPEs []string `xml:"cpe" json:"cpes,omitempty"`
+}
+
+// Script is the output of an NSE script. Output is the text nmap prints; scripts
+// with structured output also fill Elements and Tables.
+type Script struct {
+	ID       string        `xml:"id,attr" json:"id"`
+	Output   string        `xml:"output,attr" json:"output"`
+	Elements []ScriptElem  `xml:"elem" json:"elements,omitempty"`
+	Tables   []ScriptTable `xml:"table" json:"tables,omitempty"`
+}
+
+// ScriptTable is a list (no key) or a map (keyed elements) in structured script output
+type ScriptTable struct {
+	Key      string        `xml:"key,attr" json:"key,omitempty"`
+	Elements []ScriptElem  `xml:"elem" json:"elements,omitempty"`
+	Tables   []ScriptTable `xml:"table" json:"tables,omitempty"`
+}
+
+type ScriptElem struct {
+	Key   string `xml:"key,attr" json:"key,omitempty"`
+	Value string `xml:",chardata" json:"value"`
+}
+
+// OS holds the OS detection guesses, best first
+type OS struct {
+	// PortsUsed are the open and closed ports the fingerprint was taken on
+	PortsUsed    []PortUsed      `xml:"portused" json:"portsused,omitempty"`
+	Matches      []OSMatch       `xml:"osmatch" json:"osmatches,omitempty"`
+	Fingerprints []OSFingerprint `xml:"osfingerprint" json:"osfingerprints,omitempty"`
+}
+
+type PortUsed struct {
+	State  string `xml:"state,attr" json:"state"`
+	Proto  string `xml:"proto,attr" json:"proto"`
+	PortID string `xml:"portid,attr" json:"port"`
+}
+
+type OSMatch struct {
 	Name string `xml:"name,attr" json:"name"`
+	// Accuracy is in percent
+	Accuracy int       `xml:"accuracy,attr" json:"accuracy"`
+	Line     int       `xml:"line,attr" json:"line,omitempty"`
+	Classes  []OSClass `xml:"osclass" json:"osclasses,omitempty"`
+}
+
+type OSClass struct {
+	Type     string   `xml:"type,attr" json:"type,omitempty"`
+	Vendor   string   `xml:"vendor,attr" json:"vendor"`
+	OSFamily string   `xml:"osfamily,attr" json:"osfamily"`
+	OSGen    string   `xml:"osgen,attr" json:"osgen,omitempty"`
+	Accuracy int      `xml:"accuracy,attr" json:"accuracy"`
+	CPEs     []string `xml:"cpe" json:"cpes,omitempty"`
+}
+
+// OSFingerprint is the raw TCP/IP fingerprint nmap could not match exactly
+type OSFingerprint struct {
+	Fingerprint string `xml:"fingerprint,attr" json:"fingerprint"`
+}
+
+type Uptime struct {
+	Seconds  int64  `xml:"seconds,attr" json:"seconds"`
+	LastBoot string `xml:"lastboot,attr" json:"lastboot,omitempty"`
+}
+
+// Distance is the number of network hops to the host
+type Distance struct {
+	Value int `xml:"value,attr" json:"value"`
+}
+
+type Trace struct {
+	Proto string `xml:"proto,attr" json:"proto,omitempty"`
+	Port  string `xml:"port,attr" json:"port,omitempty"`
+	Hops  []Hop  `xml:"hop" json:"hops,omitempty"`
+}
+
+type Hop struct {
+	TTL    int    `xml:"ttl,attr" json:"ttl"`
+	IPAddr string `xml:"ipaddr,attr" json:"ipaddr,omitempty"`
+	// RTT is the round trip time in milliseconds, as nmap writes it
+	RTT  string `xml:"rtt,attr" json:"rtt,omitempty"`
+	Host string `xml:"host,attr" json:"host,omitempty"`
+}
+
+// Times are the timing estimates nmap kept for the host, in microseconds
+type Times struct {
+	SRTT   int `xml:"srtt,attr" json:"srtt"`
+	RTTVar int `xml:"rttvar,attr" json:"rttvar"`
+	// To is the probe timeout nmap settled on
+	To int `xml:"to,attr" json:"to"`
 }
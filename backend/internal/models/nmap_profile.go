@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// NmapProfileDefault is the profile nmap jobs run when none is selected
+const NmapProfileDefault = "default"
+
+// NmapProfileSettings are the nmap features a profile turns on. They are turned
+// into command line flags by the backend; profiles never carry raw flags.
+type NmapProfileSettings struct {
+	// Ports is a TCP port list such as "22,80,443,8000-8100". TopPorts scans the N
+	// most common ports instead. Without either nmap scans its default 1000 ports.
+	Ports    string `json:"ports,omitempty"`
+	TopPorts int    `json:"top_ports,omitempty"`
+	// TCPTechnique is one of syn, connect, ack, window, maimon, fin, null or xmas.
+	// Empty leaves the choice to nmap: syn with raw socket access, connect without.
+	TCPTechnique string `json:"tcp_technique,omitempty"`
+	// UDP runs a UDP scan of UDPPorts next to the TCP scan
+	UDP      bool   `json:"udp"`
+	UDPPorts string `json:"udp_ports,omitempty"`
+	// Timing is the -T template from 0 (paranoid) to 5 (insane), nil for nmap's 3
+	Timing *int `json:"timing,omitempty"`
+	// ServiceDetection probes open ports for product and version (-sV).
+	// VersionIntensity goes from 0 (light) to 9 (all probes), nil for nmap's 7.
+	ServiceDetection bool `json:"service_detection"`
+	VersionIntensity *int `json:"version_intensity,omitempty"`
+	OSDetection      bool `json:"os_detection"`
+	// ScriptCategories are the NSE categories to run, e.g. "default" or "vuln"
+	ScriptCategories []string `json:"script_categories,omitempty"`
+}
+
+// NmapProfile is a named set of nmap settings that scans select by name
+type NmapProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	NmapProfileSettings
+	// Builtin profiles ship with the backend and cannot be changed
+	Builtin bool `json:"builtin"`
+	// CreatedBy is the ID of the admin that created the profile
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NmapProfileRequest creates or replaces a profile. The name is taken from the
+// path on updates.
+type NmapProfileRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	NmapProfileSettings
+}
@@ -0,0 +1,33 @@
+package models
+
+// AssetCriticality is how much the business depends on a scanned asset. It scales
+// the risk of everything found on it.
+type AssetCriticality string
+
+const (
+	AssetCriticalityLow      AssetCriticality = "low"
+	AssetCriticalityMedium   AssetCriticality = "medium"
+	AssetCriticalityHigh     AssetCriticality = "high"
+	AssetCriticalityCritical AssetCriticality = "critical"
+)
+
+// RiskScore is a score from 0 to 100 together with the steps that derived it
+type RiskScore struct {
+	Score       float64  `json:"score"`
+	Explanation []string `json:"explanation"`
+}
+
+// HostRisk combines the risk of the findings on one host
+type HostRisk struct {
+	Host     string    `json:"host"`
+	Findings int       `json:"findings"`
+	Risk     RiskScore `json:"risk"`
+}
+
+// ScanRisk combines the risk of the hosts of a scan
+type ScanRisk struct {
+	ScanID           string           `json:"scan_id,omitempty"`
+	AssetCriticality AssetCriticality `json:"asset_criticality"`
+	Risk             RiskScore        `json:"risk"`
+	Hosts            []HostRisk       `json:"hosts"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import "time"
+
+// ScanStatus is the combined state of the jobs of a scan
+type ScanStatus string
+
+const (
+	ScanStatusQueued    ScanStatus = "queued"
+	ScanStatusRunning   ScanStatus = "running"
+	ScanStatusCompleted ScanStatus = "completed"
+	// ScanStatusPartial means some jobs completed and others failed or were cancelled
+	ScanStatusPartial   ScanStatus = "partial"
+	ScanStatusFailed    ScanStatus = "failed"
+	ScanStatusCancelled ScanStatus = "cancelled"
+)
+
+// IsTerminal reports whether all jobs of the scan have finished
+func (s ScanStatus) IsTerminal() bool {
+	return s != ScanStatusQueued && s != ScanStatusRunning
+}
+
+// ScanRequest fans one target out to several tools
+type ScanRequest struct {
+	Name   string   `json:"name"`
+	Target string   `json:"target"`
+	Tools  []string `json:"tools"`
+	// Options holds the job options per tool
+	Options map[string]map[string]string `json:"options,omitempty"`
+	// Pipeline starts with nmap only and queues Tools against the web and TLS
+	// services it discovers. Empty Tools means all follow-up tools.
+	Pipeline bool `json:"pipeline"`
+	// AssetCriticality weights the risk score of the scan, medium when empty
+	AssetCriticality AssetCriticality `json:"asset_criticality,omitempty"`
+}
+
+// ScanImport describes the scan POST /imports stores uploaded tool outputs under
+type ScanImport struct {
+	Name string `json:"name" form:"name"`
+	// Target is the host or network the outputs are about. When it is empty, it is
+	// taken from the outputs if they are all about the same one.
+	Target string `json:"target" form:"target"`
+	// AssetCriticality weights the risk score of the scan, medium when empty
+	AssetCriticality AssetCriticality `json:"asset_criticality,omitempty" form:"asset_criticality"`
+}
+
+// Scan is a parent of one job per requested tool
+type Scan struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Target string   `json:"target"`
+	Tools  []string `json:"tools"`
+	// Options holds the job options per tool, pipeline follow-ups use them too
+	Options          map[string]map[string]string `json:"options,omitempty"`
+	Pipeline         bool                         `json:"pipeline"`
+	AssetCriticality AssetCriticality             `json:"asset_criticality"`
+	Status           ScanStatus                   `json:"status"`
+	// Progress is the average percent complete of the jobs
+	Progress   int        `json:"progress"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Jobs and Tree are only filled in for the detail view
+	Jobs []*Job `json:"jobs,omitempty"`
+	// Tree shows which job produced which (nmap -> follow-ups in pipeline scans)
+	Tree []*JobNode `json:"tree,omitempty"`
+}
+
+// JobNode is a job summary in the scan tree; the full jobs are in Scan.Jobs
+type JobNode struct {
+	ID       string     `json:"id"`
+	Tool     string     `json:"tool"`
+	Target   string     `json:"target"`
+	Status   JobStatus  `json:"status"`
+	Progress int        `json:"progress"`
+	Children []*JobNode `json:"children,omitempty"`
+}
+
+// ScanDiff compares a scan with an earlier scan of the same target
+type ScanDiff struct {
+	ScanID    string `json:"scan_id"`
+	AgainstID string `json:"against_id"`
+	// New findings are only in the scan, Resolved ones only in the earlier scan and
+	// Persisting ones in both. Findings are matched on their fingerprint.
+	New        []Finding `json:"new"`
+	Resolved   []Finding `json:"resolved"`
+	Persisting []Finding `json:"persisting"`
+	// NotRescanned holds findings of the earlier scan from tools that did not complete
+	// in the scan, so whether they are fixed is unknown
+	NotRescanned []Finding `json:"not_rescanned,omitempty"`
+	// Nmap compares the hosts and ports both scans found, like ndiff
+	Nmap NmapDiff `json:"nmap"`
+}
+
+// NmapDiff lists the changes between the nmap results of two scans. Ports are only
+// compared on hosts that are up in both scans.
+type NmapDiff struct {
+	HostsUp   []string     `json:"hosts_up,omitempty"`
+	HostsDown []string     `json:"hosts_down,omitempty"`
+	Ports     []PortChange `json:"ports,omitempty"`
+}
+
+// PortChangeType says how a port changed between two scans
+type PortChangeType string
+
+const (
+	PortOpened         PortChangeType = "opened"
+	PortClosed         PortChangeType = "closed"
+	PortServiceChanged PortChangeType = "service_changed"
+)
+
+// PortChange is one port whose state or service differs between two scans. The old
+// state is empty for ports the earlier scan did not report, the new one for ports
+// the scan no longer reports.
+type PortChange struct {
+	Host       string         `json:"host"`
+	Port       string         `json:"port"`
+	Protocol   string         `json:"protocol"`
+	Change     PortChangeType `json:"change"`
+	OldState   string         `json:"old_state,omitempty"`
+	NewState   string         `json:"new_state,omitempty"`
+	OldService string         `json:"old_service,omitempty"`
+	NewService string         `json:"new_service,omitempty"`
+}
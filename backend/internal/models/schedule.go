@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Schedule starts the same set of tools against a target on a cron schedule
+type Schedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Cron is a standard 5-field expression or a descriptor such as "@weekly"
+	Cron    string            `json:"cron"`
+	Target  string            `json:"target"`
+	Tools   []string          `json:"tools"`
+	Options map[string]string `json:"options,omitempty"`
+	// Owner is the ID of the user that created the schedule
+	Owner     string     `json:"owner"`
+	Enabled   bool       `json:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ScheduleRequest creates or replaces a schedule
+type ScheduleRequest struct {
+	Name    string            `json:"name"`
+	Cron    string            `json:"cron"`
+	Target  string            `json:"target"`
+	Tools   []string          `json:"tools"`
+	Options map[string]string `json:"options,omitempty"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// ScheduleRunStatus indicates the outcome of one firing of a schedule
+type ScheduleRunStatus string
+
+const (
+	ScheduleRunRunning   ScheduleRunStatus = "running"
+	ScheduleRunCompleted ScheduleRunStatus = "completed"
+	ScheduleRunFailed    ScheduleRunStatus = "failed"
+	// ScheduleRunSkipped is recorded when the previous run was still in progress
+	ScheduleRunSkipped ScheduleRunStatus = "skipped"
+)
+
+// ScheduleRun is one firing of a schedule and the scan it started
+type ScheduleRun struct {
+	ID         string            `json:"id"`
+	ScheduleID string            `json:"schedule_id"`
+	Status     ScheduleRunStatus `json:"status"`
+	// ScanID is the scan of the run, so runs can be diffed against each other. It is
+	// empty for runs recorded before schedules started scans.
+	ScanID     string     `json:"scan_id,omitempty"`
+	JobIDs     []string   `json:"job_ids"`
+	Message    string     `json:"message,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
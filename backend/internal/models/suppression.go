@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// SuppressionRule hides the findings that match every matcher it sets. Empty
+// matchers match anything, but a rule sets at least one.
+type SuppressionRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Tool string `json:"tool,omitempty"`
+	// RuleID is the check that fired. A nuclei template ID also matches its
+	// per-matcher rule IDs, e.g. "tech-detect" matches "tech-detect:nginx".
+	RuleID string `json:"rule_id,omitempty"`
+	// HostGlob is a shell pattern such as "*.staging.example.com"
+	HostGlob string `json:"host_glob,omitempty"`
+	Port     string `json:"port,omitempty"`
+	// PathRegex is matched against the URL path of the finding
+	PathRegex string   `json:"path_regex,omitempty"`
+	Severity  Severity `json:"severity,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+	// ExpiresAt is when the rule stops applying, nil for never
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// CreatedBy is the ID of the user that created the rule
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SuppressionRuleRequest creates or replaces a suppression rule
+type SuppressionRuleRequest struct {
+	Name      string     `json:"name"`
+	Tool      string     `json:"tool,omitempty"`
+	RuleID    string     `json:"rule_id,omitempty"`
+	HostGlob  string     `json:"host_glob,omitempty"`
+	Port      string     `json:"port,omitempty"`
+	PathRegex string     `json:"path_regex,omitempty"`
+	Severity  Severity   `json:"severity,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
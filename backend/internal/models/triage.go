@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// TriageStatus is where a finding is in its lifecycle
+type TriageStatus string
+
+const (
+	// TriageOpen is the status of every finding nobody has looked at yet
+	TriageOpen          TriageStatus = "open"
+	TriageConfirmed     TriageStatus = "confirmed"
+	TriageFalsePositive TriageStatus = "false_positive"
+	TriageAcceptedRisk  TriageStatus = "accepted_risk"
+	TriageFixed         TriageStatus = "fixed"
+	// TriageReopened is set when a fixed finding is found again or a risk acceptance expires
+	TriageReopened TriageStatus = "reopened"
+)
+
+// FindingTriage is the triage state of a finding. It is keyed by the finding
+// fingerprint, so it applies to every scan that reports the same issue again.
+type FindingTriage struct {
+	Fingerprint string       `json:"fingerprint"`
+	Status      TriageStatus `json:"status"`
+	// Assignee is the ID or email of the user working on the finding
+	Assignee      string `json:"assignee,omitempty"`
+	Justification string `json:"justification,omitempty"`
+	// AcceptedUntil is when an accepted risk is reopened
+	AcceptedUntil *time.Time `json:"accepted_until,omitempty"`
+	// UpdatedBy is the ID of the user that made the last change, "system" for automatic ones
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Transitions and Comments are only filled in when a single triage is requested
+	Transitions []*TriageTransition `json:"transitions,omitempty"`
+	Comments    []*FindingComment   `json:"comments,omitempty"`
+}
+
+// Suppressed reports whether the finding is currently dismissed as a false positive
+// or an accepted risk that has not expired
+func (t *FindingTriage) Suppressed(now time.Time) bool {
+	if t == nil {
+		return false
+	}
+	switch t.Status {
+	case TriageFalsePositive:
+		return true
+	case TriageAcceptedRisk:
+		return t.AcceptedUntil == nil || t.AcceptedUntil.After(now)
+	}
+	return false
+}
+
+// TriageTransition records one status change of a finding
+type TriageTransition struct {
+	ID            string       `json:"id"`
+	Fingerprint   string       `json:"fingerprint"`
+	From          TriageStatus `json:"from"`
+	To            TriageStatus `json:"to"`
+	UserID        string       `json:"user_id"`
+	Justification string       `json:"justification,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// FindingComment is a comment on a finding. Replies are nested under the comment they answer.
+type FindingComment struct {
+	ID          string            `json:"id"`
+	Fingerprint string            `json:"fingerprint"`
+	ParentID    string            `json:"parent_id,omitempty"`
+	UserID      string            `json:"user_id"`
+	Body        string            `json:"body"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Replies     []*FindingComment `json:"replies,omitempty"`
+}
+
+// TriageUpdateRequest changes the triage of a finding. Empty fields keep their value.
+type TriageUpdateRequest struct {
+	Status TriageStatus `json:"status,omitempty"`
+	// Assignee is cleared with ""
+	Assignee *string `json:"assignee,omitempty"`
+	// Justification is required for false_positive and accepted_risk
+	Justification string `json:"justification,omitempty"`
+	// AcceptedUntil is required for accepted_risk and must be in the future
+	AcceptedUntil *time.Time `json:"accepted_until,omitempty"`
+}
+
+// CommentRequest adds a comment to a finding, or a reply when ParentID is set
+type CommentRequest struct {
+	Body     string `json:"body"`
+	ParentID string `json:"parent_id,omitempty"`
+}
@@ -0,0 +1,51 @@
+CREATE TABLE jobs (
+    id          TEXT PRIMARY KEY,
+    tool        TEXT NOT NULL,
+    target      TEXT NOT NULL,
+    options     TEXT,
+    status      TEXT NOT NULL,
+    progress    INTEGER NOT NULL DEFAULT 0,
+    message     TEXT NOT NULL DEFAULT '',
+    refs        TEXT,
+    result      TEXT,
+    error       TEXT NOT NULL DEFAULT '',
+    created_at  TEXT NOT NULL,
+    started_at  TEXT,
+    finished_at TEXT
+);
+
+CREATE INDEX idx_jobs_status ON jobs (status);
+CREATE INDEX idx_jobs_created_at ON jobs (created_at);
+
+CREATE TABLE tool_outputs (
+    job_id       TEXT NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    tool         TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    content      BLOB NOT NULL,
+    created_at   TEXT NOT NULL,
+    PRIMARY KEY (job_id, name)
+);
+
+CREATE TABLE batches (
+    batch_id        TEXT PRIMARY KEY,
+    user_id         TEXT NOT NULL,
+    expected_count  INTEGER NOT NULL,
+    received_count  INTEGER NOT NULL,
+    status          TEXT NOT NULL,
+    results         TEXT,
+    analysis_result TEXT,
+    created_at      TEXT NOT NULL,
+    updated_at      TEXT NOT NULL
+);
+
+CREATE INDEX idx_batches_user_id ON batches (user_id);
+
+CREATE TABLE users (
+    id            TEXT PRIMARY KEY,
+    email         TEXT NOT NULL,
+    name          TEXT NOT NULL,
+    picture       TEXT NOT NULL,
+    created_at    TEXT NOT NULL,
+    last_login_at TEXT NOT NULL
+);
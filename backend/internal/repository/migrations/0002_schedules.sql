@@ -0,0 +1,28 @@
+CREATE TABLE schedules (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    cron        TEXT NOT NULL,
+    target      TEXT NOT NULL,
+    tools       TEXT NOT NULL,
+    options     TEXT,
+    owner       TEXT NOT NULL,
+    enabled     INTEGER NOT NULL,
+    next_run_at TEXT,
+    last_run_at TEXT,
+    created_at  TEXT NOT NULL,
+    updated_at  TEXT NOT NULL
+);
+
+CREATE INDEX idx_schedules_owner ON schedules (owner);
+
+CREATE TABLE schedule_runs (
+    id          TEXT PRIMARY KEY,
+    schedule_id TEXT NOT NULL REFERENCES schedules (id) ON DELETE CASCADE,
+    status      TEXT NOT NULL,
+    job_ids     TEXT,
+    message     TEXT NOT NULL DEFAULT '',
+    started_at  TEXT NOT NULL,
+    finished_at TEXT
+);
+
+CREATE INDEX idx_schedule_runs_schedule ON schedule_runs (schedule_id, started_at);
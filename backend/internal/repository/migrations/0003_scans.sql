@@ -0,0 +1,17 @@
+CREATE TABLE scans (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    target      TEXT NOT NULL,
+    tools       TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    progress    INTEGER NOT NULL DEFAULT 0,
+    created_at  TEXT NOT NULL,
+    updated_at  TEXT NOT NULL,
+    finished_at TEXT
+);
+
+CREATE INDEX idx_scans_created_at ON scans (created_at);
+
+ALTER TABLE jobs ADD COLUMN scan_id TEXT;
+
+CREATE INDEX idx_jobs_scan_id ON jobs (scan_id);
@@ -0,0 +1,4 @@
+ALTER TABLE jobs ADD COLUMN parent_id TEXT;
+
+ALTER TABLE scans ADD COLUMN options TEXT;
+ALTER TABLE scans ADD COLUMN pipeline INTEGER NOT NULL DEFAULT 0;
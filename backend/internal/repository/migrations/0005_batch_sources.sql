@@ -0,0 +1,4 @@
+ALTER TABLE batches ADD COLUMN sources TEXT;
+
+-- Batches created before sources were declared only hold placeholder analyses
+UPDATE batches SET analysis_result = NULL WHERE sources IS NULL;
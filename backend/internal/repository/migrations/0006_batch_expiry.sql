@@ -0,0 +1 @@
+CREATE INDEX idx_batches_status_updated_at ON batches (status, updated_at);
@@ -0,0 +1 @@
+ALTER TABLE scans ADD COLUMN asset_criticality TEXT NOT NULL DEFAULT '';
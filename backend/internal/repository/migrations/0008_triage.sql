@@ -0,0 +1,36 @@
+CREATE TABLE finding_triage (
+    fingerprint    TEXT PRIMARY KEY,
+    status         TEXT NOT NULL,
+    assignee       TEXT NOT NULL DEFAULT '',
+    justification  TEXT NOT NULL DEFAULT '',
+    accepted_until TEXT,
+    updated_by     TEXT NOT NULL,
+    created_at     TEXT NOT NULL,
+    updated_at     TEXT NOT NULL
+);
+
+CREATE INDEX idx_finding_triage_status ON finding_triage (status, accepted_until);
+CREATE INDEX idx_finding_triage_assignee ON finding_triage (assignee);
+
+CREATE TABLE finding_transitions (
+    id            TEXT PRIMARY KEY,
+    fingerprint   TEXT NOT NULL,
+    from_status   TEXT NOT NULL,
+    to_status     TEXT NOT NULL,
+    user_id       TEXT NOT NULL,
+    justification TEXT NOT NULL DEFAULT '',
+    created_at    TEXT NOT NULL
+);
+
+CREATE INDEX idx_finding_transitions_fingerprint ON finding_transitions (fingerprint, created_at);
+
+CREATE TABLE finding_comments (
+    id          TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    parent_id   TEXT REFERENCES finding_comments (id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL,
+    body        TEXT NOT NULL,
+    created_at  TEXT NOT NULL
+);
+
+CREATE INDEX idx_finding_comments_fingerprint ON finding_comments (fingerprint, created_at);
@@ -0,0 +1,18 @@
+CREATE TABLE suppression_rules (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    tool       TEXT NOT NULL DEFAULT '',
+    rule_id    TEXT NOT NULL DEFAULT '',
+    host_glob  TEXT NOT NULL DEFAULT '',
+    port       TEXT NOT NULL DEFAULT '',
+    path_regex TEXT NOT NULL DEFAULT '',
+    severity   TEXT NOT NULL DEFAULT '',
+    reason     TEXT NOT NULL DEFAULT '',
+    expires_at TEXT,
+    created_by TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- The rules that hid findings of a job when it finished, keyed by finding
+ALTER TABLE jobs ADD COLUMN suppressed TEXT;
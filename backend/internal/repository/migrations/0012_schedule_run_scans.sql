@@ -0,0 +1 @@
+ALTER TABLE schedule_runs ADD COLUMN scan_id TEXT;
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"napscan-be/internal/models"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("not found")
+
+// JobRepository persists scan jobs and their results
+type JobRepository interface {
+	SaveJob(ctx context.Context, job *models.Job) error
+	GetJob(ctx context.Context, id string) (*models.Job, error)
+	ListJobs(ctx context.Context, filter JobFilter) ([]*models.Job, error)
+}
+
+// JobFilter narrows down ListJobs. Empty fields are ignored.
+type JobFilter struct {
+	Tool   string
+	Status models.JobStatus
+	ScanID string
+	Limit  int
+}
+
+// OutputRepository persists the native output files produced by the tools
+type OutputRepository interface {
+	SaveOutput(ctx context.Context, out *models.ToolOutput) error
+	GetOutput(ctx context.Context, jobID, name string) (*models.ToolOutput, error)
+	ListOutputs(ctx context.Context, jobID string) ([]*models.ToolOutput, error)
+}
+
+// BatchRepository persists fan-in batches
+type BatchRepository interface {
+	SaveBatch(ctx context.Context, batch *models.Batch) error
+	GetBatch(ctx context.Context, batchID string) (*models.Batch, error)
+	// ListBatchIDs returns the IDs of the matching batches, least recently updated first
+	ListBatchIDs(ctx context.Context, filter BatchFilter) ([]string, error)
+	CountBatches(ctx context.Context, filter BatchFilter) (int, error)
+}
+
+// BatchFilter narrows down ListBatchIDs and CountBatches. Empty fields are ignored.
+type BatchFilter struct {
+	UserID string
+	Status models.BatchStatus
+	// UpdatedBefore matches batches that were not touched since then
+	UpdatedBefore time.Time
+}
+
+// UserRepository persists users that logged in
+type UserRepository interface {
+	SaveUser(ctx context.Context, user *models.User) error
+	GetUser(ctx context.Context, id string) (*models.User, error)
+}
+
+// ScheduleRepository persists recurring scan schedules and their run history
+type ScheduleRepository interface {
+	SaveSchedule(ctx context.Context, schedule *models.Schedule) error
+	GetSchedule(ctx context.Context, id string) (*models.Schedule, error)
+	// ListSchedules returns the schedules of owner, or all schedules when owner is ""
+	ListSchedules(ctx context.Context, owner string) ([]*models.Schedule, error)
+	DeleteSchedule(ctx context.Context, id string) error
+	SaveScheduleRun(ctx context.Context, run *models.ScheduleRun) error
+	// ListScheduleRuns returns the runs of a schedule, newest first
+	ListScheduleRuns(ctx context.Context, scheduleID string, filter ScheduleRunFilter) ([]*models.ScheduleRun, error)
+}
+
+// ScheduleRunFilter narrows down ListScheduleRuns. Empty fields are ignored.
+type ScheduleRunFilter struct {
+	Status models.ScheduleRunStatus
+	Limit  int
+}
+
+// ScanRepository persists multi-tool scans. Their jobs are linked through Job.ScanID.
+type ScanRepository interface {
+	SaveScan(ctx context.Context, scan *models.Scan) error
+	GetScan(ctx context.Context, id string) (*models.Scan, error)
+	// ListScans returns scans newest first
+	ListScans(ctx context.Context, limit int) ([]*models.Scan, error)
+	// DeleteScan removes a scan together with its jobs and their outputs
+	DeleteScan(ctx context.Context, id string) error
+}
+
+// TriageRepository persists the triage of findings, keyed by fingerprint, with its
+// status history and comments
+type TriageRepository interface {
+	SaveTriage(ctx context.Context, triage *models.FindingTriage) error
+	GetTriage(ctx context.Context, fingerprint string) (*models.FindingTriage, error)
+	// GetTriages returns the triage of the fingerprints that have one, by fingerprint
+	GetTriages(ctx context.Context, fingerprints []string) (map[string]*models.FindingTriage, error)
+	// ListTriage returns the matching triages, most recently updated first
+	ListTriage(ctx context.Context, filter TriageFilter) ([]*models.FindingTriage, error)
+	SaveTransition(ctx context.Context, transition *models.TriageTransition) error
+	// ListTransitions returns the status changes of a fingerprint, oldest first
+	ListTransitions(ctx context.Context, fingerprint string) ([]*models.TriageTransition, error)
+	SaveComment(ctx context.Context, comment *models.FindingComment) error
+	// ListComments returns the comments of a fingerprint flat, oldest first
+	ListComments(ctx context.Context, fingerprint string) ([]*models.FindingComment, error)
+}
+
+// TriageFilter narrows down ListTriage. Empty fields are ignored.
+type TriageFilter struct {
+	Status   models.TriageStatus
+	Assignee string
+	// AcceptedBefore matches triages whose risk acceptance ends before then
+	AcceptedBefore time.Time
+	Limit          int
+}
+
+// SuppressionRepository persists the rules that hide findings
+type SuppressionRepository interface {
+	SaveSuppressionRule(ctx context.Context, rule *models.SuppressionRule) error
+	GetSuppressionRule(ctx context.Context, id string) (*models.SuppressionRule, error)
+	// ListSuppressionRules returns every rule, oldest first
+	ListSuppressionRules(ctx context.Context) ([]*models.SuppressionRule, error)
+	DeleteSuppressionRule(ctx context.Context, id string) error
+	// SaveJobSuppressions stores the rules that hid findings of a finished job
+	SaveJobSuppressions(ctx context.Context, jobID string, suppressed map[string]string) error
+}
+
+// NmapProfileRepository persists the nmap profiles defined by admins, keyed by name
+type NmapProfileRepository interface {
+	SaveNmapProfile(ctx context.Context, profile *models.NmapProfile) error
+	GetNmapProfile(ctx context.Context, name string) (*models.NmapProfile, error)
+	// ListNmapProfiles returns every stored profile by name
+	ListNmapProfiles(ctx context.Context) ([]*models.NmapProfile, error)
+	DeleteNmapProfile(ctx context.Context, name string) error
+}
+
+// Repository is the complete storage layer
+type Repository interface {
+	JobRepository
+	OutputRepository
+	BatchRepository
+	UserRepository
+	ScheduleRepository
+	ScanRepository
+	TriageRepository
+	SuppressionRepository
+	NmapProfileRepository
+	Close() error
+}
@@ -0,0 +1,461 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"napscan-be/internal/models"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// SQLiteRepository implements Repository on top of an embedded SQLite database
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = (*SQLiteRepository)(nil)
+
+// DefaultDBPath returns NAPSCAN_DB_PATH or napscan.db in the working directory
+func DefaultDBPath() string {
+	if v := strings.TrimSpace(os.Getenv("NAPSCAN_DB_PATH")); v != "" {
+		return v
+	}
+	return "napscan.db"
+}
+
+// OpenSQLite opens (or creates) the database at path and applies pending migrations.
+// Use ":memory:" for a throwaway database.
+func OpenSQLite(path string) (*SQLiteRepository, error) {
+	if path != ":memory:" {
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create database directory: %w", err)
+			}
+		}
+	}
+
+	dsn := path + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection also keeps ":memory:" databases shared
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteRepository{db: db}, nil
+}
+
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+// --- Jobs ---
+
+func (r *SQLiteRepository) SaveJob(ctx context.Context, job *models.Job) error {
+	options, err := marshalNullable(job.Options)
+	if err != nil {
+		return err
+	}
+	refs, err := marshalNullable(job.Refs)
+	if err != nil {
+		return err
+	}
+	shards, err := marshalNullable(job.Shards)
+	if err != nil {
+		return err
+	}
+	result, err := marshalNullable(job.Result)
+	if err != nil {
+		return fmt.Errorf("failed to encode job result: %w", err)
+	}
+	suppressed, err := marshalNullable(job.Suppressed)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO jobs (`+jobColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status,
+			progress = excluded.progress,
+			message = excluded.message,
+			refs = excluded.refs,
+			shards = excluded.shards,
+			result = excluded.result,
+			error = excluded.error,
+			started_at = excluded.started_at,
+			finished_at = excluded.finished_at`, // suppressed is stamped once, by SaveJobSuppressions
+		job.ID, job.Tool, job.Target, options, string(job.Status), job.Progress, job.Message, refs, result, job.Error,
+		formatTime(job.CreatedAt), formatTimePtr(job.StartedAt), formatTimePtr(job.FinishedAt), nullString(job.ScanID), nullString(job.ParentID), shards, suppressed)
+	return err
+}
+
+const jobColumns = `id, tool, target, options, status, progress, message, refs, result, error, created_at, started_at, finished_at, scan_id, parent_id, shards, suppressed`
+
+func (r *SQLiteRepository) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return job, err
+}
+
+func (r *SQLiteRepository) ListJobs(ctx context.Context, filter JobFilter) ([]*models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE 1 = 1`
+	var args []interface{}
+	if filter.Tool != "" {
+		query += ` AND tool = ?`
+		args = append(args, filter.Tool)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, string(filter.Status))
+	}
+	if filter.ScanID != "" {
+		query += ` AND scan_id = ?`
+		args = append(args, filter.ScanID)
+	}
+	query += ` ORDER BY created_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*models.Job, error) {
+	var (
+		job                   models.Job
+		status                string
+		options, refs, result sql.NullString
+		shards, suppressed    sql.NullString
+		createdAt             string
+		startedAt, finishedAt sql.NullString
+		scanID, parentID      sql.NullString
+	)
+	if err := row.Scan(&job.ID, &job.Tool, &job.Target, &options, &status, &job.Progress, &job.Message,
+		&refs, &result, &job.Error, &createdAt, &startedAt, &finishedAt, &scanID, &parentID, &shards, &suppressed); err != nil {
+		return nil, err
+	}
+
+	job.Status = models.JobStatus(status)
+	if err := unmarshalNullable(options, &job.Options); err != nil {
+		return nil, err
+	}
+	if err := unmarshalNullable(refs, &job.Refs); err != nil {
+		return nil, err
+	}
+	if err := unmarshalNullable(shards, &job.Shards); err != nil {
+		return nil, err
+	}
+	if err := unmarshalNullable(suppressed, &job.Suppressed); err != nil {
+		return nil, err
+	}
+	if result.Valid {
+		job.Result = json.RawMessage(result.String)
+	}
+	job.CreatedAt = parseTime(createdAt)
+	job.StartedAt = parseTimePtr(startedAt)
+	job.FinishedAt = parseTimePtr(finishedAt)
+	job.ScanID = scanID.String
+	job.ParentID = parentID.String
+	return &job, nil
+}
+
+// --- Tool outputs ---
+
+func (r *SQLiteRepository) SaveOutput(ctx context.Context, out *models.ToolOutput) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO tool_outputs (job_id, name, tool, content_type, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (job_id, name) DO UPDATE SET
+			content_type = excluded.content_type,
+			content = excluded.content,
+			created_at = excluded.created_at`,
+		out.JobID, out.Name, out.Tool, out.ContentType, out.Content, formatTime(out.CreatedAt))
+	return err
+}
+
+func (r *SQLiteRepository) GetOutput(ctx context.Context, jobID, name string) (*models.ToolOutput, error) {
+	var (
+		out       models.ToolOutput
+		createdAt string
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT job_id, name, tool, content_type, content, created_at FROM tool_outputs WHERE job_id = ? AND name = ?`,
+		jobID, name).Scan(&out.JobID, &out.Name, &out.Tool, &out.ContentType, &out.Content, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	out.Size = len(out.Content)
+	out.CreatedAt = parseTime(createdAt)
+	return &out, nil
+}
+
+// ListOutputs returns the output metadata of a job without the content
+func (r *SQLiteRepository) ListOutputs(ctx context.Context, jobID string) ([]*models.ToolOutput, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT job_id, name, tool, content_type, length(content), created_at FROM tool_outputs WHERE job_id = ? ORDER BY name`,
+		jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	outs := []*models.ToolOutput{}
+	for rows.Next() {
+		var (
+			out       models.ToolOutput
+			createdAt string
+		)
+		if err := rows.Scan(&out.JobID, &out.Name, &out.Tool, &out.ContentType, &out.Size, &createdAt); err != nil {
+			return nil, err
+		}
+		out.CreatedAt = parseTime(createdAt)
+		outs = append(outs, &out)
+	}
+	return outs, rows.Err()
+}
+
+// --- Batches ---
+
+func (r *SQLiteRepository) SaveBatch(ctx context.Context, b *models.Batch) error {
+	sources, err := marshalNullable(b.Sources)
+	if err != nil {
+		return err
+	}
+	results, err := marshalNullable(b.Results)
+	if err != nil {
+		return err
+	}
+	analysis, err := marshalNullable(b.AnalysisResult)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO batches (batch_id, user_id, sources, expected_count, received_count, status, results, analysis_result, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (batch_id) DO UPDATE SET
+			sources = excluded.sources,
+			expected_count = excluded.expected_count,
+			received_count = excluded.received_count,
+			status = excluded.status,
+			results = excluded.results,
+			analysis_result = excluded.analysis_result,
+			updated_at = excluded.updated_at`,
+		b.BatchID, b.UserID, sources, b.ExpectedCount, b.ReceivedCount, string(b.Status), results, analysis,
+		formatTime(b.CreatedAt), formatTime(b.UpdatedAt))
+	return err
+}
+
+func (r *SQLiteRepository) GetBatch(ctx context.Context, batchID string) (*models.Batch, error) {
+	var (
+		b                          models.Batch
+		status                     string
+		sources, results, analysis sql.NullString
+		createdAt, updateAt        string
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT batch_id, user_id, sources, expected_count, received_count, status, results, analysis_result, created_at, updated_at
+		FROM batches WHERE batch_id = ?`, batchID).
+		Scan(&b.BatchID, &b.UserID, &sources, &b.ExpectedCount, &b.ReceivedCount, &status, &results, &analysis, &createdAt, &updateAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b.Status = models.BatchStatus(status)
+	if err := unmarshalNullable(sources, &b.Sources); err != nil {
+		return nil, err
+	}
+	if err := unmarshalNullable(results, &b.Results); err != nil {
+		return nil, err
+	}
+	if b.Results == nil {
+		b.Results = make(map[string]interface{})
+	}
+	if err := unmarshalNullable(analysis, &b.AnalysisResult); err != nil {
+		return nil, err
+	}
+	b.CreatedAt = parseTime(createdAt)
+	b.UpdatedAt = parseTime(updateAt)
+	return &b, nil
+}
+
+func (r *SQLiteRepository) ListBatchIDs(ctx context.Context, filter BatchFilter) ([]string, error) {
+	where, args := batchFilterClause(filter)
+	rows, err := r.db.QueryContext(ctx, `SELECT batch_id FROM batches`+where+` ORDER BY updated_at`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *SQLiteRepository) CountBatches(ctx context.Context, filter BatchFilter) (int, error) {
+	where, args := batchFilterClause(filter)
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM batches`+where, args...).Scan(&n)
+	return n, err
+}
+
+func batchFilterClause(filter BatchFilter) (string, []interface{}) {
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if filter.UserID != "" {
+		where += ` AND user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		where += ` AND status = ?`
+		args = append(args, string(filter.Status))
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		where += ` AND updated_at < ?`
+		args = append(args, formatTime(filter.UpdatedBefore))
+	}
+	return where, args
+}
+
+// --- Users ---
+
+// SaveUser inserts the user or refreshes its profile and last login time
+func (r *SQLiteRepository) SaveUser(ctx context.Context, u *models.User) error {
+	now := formatTime(time.Now())
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO users (id, email, name, picture, created_at, last_login_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			email = excluded.email,
+			name = excluded.name,
+			picture = excluded.picture,
+			last_login_at = excluded.last_login_at`,
+		u.ID, u.Email, u.Name, u.Picture, now, now)
+	return err
+}
+
+func (r *SQLiteRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	var u models.User
+	err := r.db.QueryRowContext(ctx, `SELECT id, email, name, picture FROM users WHERE id = ?`, id).
+		Scan(&u.ID, &u.Email, &u.Name, &u.Picture)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// --- Helpers ---
+
+// timeLayout keeps every stored time the same width, with all nine fractional digits,
+// so ORDER BY and comparisons on the TEXT columns follow the times. RFC3339Nano drops
+// trailing zeros, which sorts "15:04:05Z" after "15:04:05.1Z".
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func formatTimePtr(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+func parseTimePtr(s sql.NullString) *time.Time {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	t := parseTime(s.String)
+	return &t
+}
+
+// nullString stores "" as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// marshalNullable encodes v as JSON, storing NULL for nil values
+func marshalNullable(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return nil, nil
+	}
+	return string(b), nil
+}
+
+func unmarshalNullable(s sql.NullString, v interface{}) error {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s.String), v)
+}
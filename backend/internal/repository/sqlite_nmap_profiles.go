@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"napscan-be/internal/models"
+)
+
+const nmapProfileColumns = `name, description, settings, created_by, created_at, updated_at`
+
+func (r *SQLiteRepository) SaveNmapProfile(ctx context.Context, profile *models.NmapProfile) error {
+	settings, err := json.Marshal(profile.NmapProfileSettings)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO nmap_profiles (`+nmapProfileColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			description = excluded.description,
+			settings = excluded.settings,
+			updated_at = excluded.updated_at`,
+		profile.Name, profile.Description, string(settings), profile.CreatedBy,
+		formatTime(profile.CreatedAt), formatTime(profile.UpdatedAt))
+	return err
+}
+
+func (r *SQLiteRepository) GetNmapProfile(ctx context.Context, name string) (*models.NmapProfile, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+nmapProfileColumns+` FROM nmap_profiles WHERE name = ?`, name)
+	profile, err := scanNmapProfile(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return profile, err
+}
+
+func (r *SQLiteRepository) ListNmapProfiles(ctx context.Context) ([]*models.NmapProfile, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+nmapProfileColumns+` FROM nmap_profiles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []*models.NmapProfile{}
+	for rows.Next() {
+		profile, err := scanNmapProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+func (r *SQLiteRepository) DeleteNmapProfile(ctx context.Context, name string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM nmap_profiles WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanNmapProfile(row rowScanner) (*models.NmapProfile, error) {
+	var (
+		profile              models.NmapProfile
+		settings             string
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&profile.Name, &profile.Description, &settings, &profile.CreatedBy, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(settings), &profile.NmapProfileSettings); err != nil {
+		return nil, err
+	}
+	profile.CreatedAt = parseTime(createdAt)
+	profile.UpdatedAt = parseTime(updatedAt)
+	return &profile, nil
+}
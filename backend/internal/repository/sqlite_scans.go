@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"napscan-be/internal/models"
+)
+
+// --- Scans ---
+
+func (r *SQLiteRepository) SaveScan(ctx context.Context, s *models.Scan) error {
+	tools, err := marshalNullable(s.Tools)
+	if err != nil {
+		return err
+	}
+	options, err := marshalNullable(s.Options)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO scans (id, name, target, tools, options, pipeline, asset_criticality, status, progress, created_at, updated_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status,
+			progress = excluded.progress,
+			updated_at = excluded.updated_at,
+			finished_at = excluded.finished_at`,
+		s.ID, s.Name, s.Target, tools, options, s.Pipeline, string(s.AssetCriticality), string(s.Status), s.Progress,
+		formatTime(s.CreatedAt), formatTime(s.UpdatedAt), formatTimePtr(s.FinishedAt))
+	return err
+}
+
+func (r *SQLiteRepository) DeleteScan(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The outputs of the jobs go with them
+	if _, err := tx.ExecContext(ctx, `DELETE FROM jobs WHERE scan_id = ?`, id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM scans WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+const scanColumns = `id, name, target, tools, options, pipeline, asset_criticality, status, progress, created_at, updated_at, finished_at`
+
+func (r *SQLiteRepository) GetScan(ctx context.Context, id string) (*models.Scan, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+scanColumns+` FROM scans WHERE id = ?`, id)
+	s, err := scanScan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+func (r *SQLiteRepository) ListScans(ctx context.Context, limit int) ([]*models.Scan, error) {
+	query := `SELECT ` + scanColumns + ` FROM scans ORDER BY created_at DESC`
+	var args []interface{}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scans := []*models.Scan{}
+	for rows.Next() {
+		s, err := scanScan(rows)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, s)
+	}
+	return scans, rows.Err()
+}
+
+func scanScan(row rowScanner) (*models.Scan, error) {
+	var (
+		s                    models.Scan
+		status, criticality  string
+		tools, options       sql.NullString
+		createdAt, updatedAt string
+		finishedAt           sql.NullString
+	)
+	if err := row.Scan(&s.ID, &s.Name, &s.Target, &tools, &options, &s.Pipeline, &criticality, &status, &s.Progress,
+		&createdAt, &updatedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+
+	s.Status = models.ScanStatus(status)
+	s.AssetCriticality = models.AssetCriticality(criticality)
+	if err := unmarshalNullable(tools, &s.Tools); err != nil {
+		return nil, err
+	}
+	if err := unmarshalNullable(options, &s.Options); err != nil {
+		return nil, err
+	}
+	s.CreatedAt = parseTime(createdAt)
+	s.UpdatedAt = parseTime(updatedAt)
+	s.FinishedAt = parseTimePtr(finishedAt)
+	return &s, nil
+}
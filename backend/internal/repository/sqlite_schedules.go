@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"napscan-be/internal/models"
+)
+
+// --- Schedules ---
+
+func (r *SQLiteRepository) SaveSchedule(ctx context.Context, s *models.Schedule) error {
+	tools, err := marshalNullable(s.Tools)
+	if err != nil {
+		return err
+	}
+	options, err := marshalNullable(s.Options)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO schedules (id, name, cron, target, tools, options, owner, enabled, next_run_at, last_run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			cron = excluded.cron,
+			target = excluded.target,
+			tools = excluded.tools,
+			options = excluded.options,
+			enabled = excluded.enabled,
+			next_run_at = excluded.next_run_at,
+			last_run_at = excluded.last_run_at,
+			updated_at = excluded.updated_at`,
+		s.ID, s.Name, s.Cron, s.Target, tools, options, s.Owner, s.Enabled,
+		formatTimePtr(s.NextRunAt), formatTimePtr(s.LastRunAt), formatTime(s.CreatedAt), formatTime(s.UpdatedAt))
+	return err
+}
+
+const scheduleColumns = `id, name, cron, target, tools, options, owner, enabled, next_run_at, last_run_at, created_at, updated_at`
+
+func (r *SQLiteRepository) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = ?`, id)
+	s, err := scanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+func (r *SQLiteRepository) ListSchedules(ctx context.Context, owner string) ([]*models.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules`
+	var args []interface{}
+	if owner != "" {
+		query += ` WHERE owner = ?`
+		args = append(args, owner)
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*models.Schedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *SQLiteRepository) DeleteSchedule(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanSchedule(row rowScanner) (*models.Schedule, error) {
+	var (
+		s                    models.Schedule
+		tools, options       sql.NullString
+		nextRunAt, lastRunAt sql.NullString
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&s.ID, &s.Name, &s.Cron, &s.Target, &tools, &options, &s.Owner, &s.Enabled,
+		&nextRunAt, &lastRunAt, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalNullable(tools, &s.Tools); err != nil {
+		return nil, err
+	}
+	if err := unmarshalNullable(options, &s.Options); err != nil {
+		return nil, err
+	}
+	s.NextRunAt = parseTimePtr(nextRunAt)
+	s.LastRunAt = parseTimePtr(lastRunAt)
+	s.CreatedAt = parseTime(createdAt)
+	s.UpdatedAt = parseTime(updatedAt)
+	return &s, nil
+}
+
+// --- Schedule runs ---
+
+func (r *SQLiteRepository) SaveScheduleRun(ctx context.Context, run *models.ScheduleRun) error {
+	jobIDs, err := marshalNullable(run.JobIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO schedule_runs (id, schedule_id, status, job_ids, message, started_at, finished_at, scan_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status,
+			job_ids = excluded.job_ids,
+			scan_id = excluded.scan_id,
+			message = excluded.message,
+			finished_at = excluded.finished_at`,
+		run.ID, run.ScheduleID, string(run.Status), jobIDs, run.Message,
+		formatTime(run.StartedAt), formatTimePtr(run.FinishedAt), nullString(run.ScanID))
+	return err
+}
+
+func (r *SQLiteRepository) ListScheduleRuns(ctx context.Context, scheduleID string, filter ScheduleRunFilter) ([]*models.ScheduleRun, error) {
+	query := `SELECT id, schedule_id, status, job_ids, message, started_at, finished_at, scan_id
+		FROM schedule_runs WHERE schedule_id = ?`
+	args := []interface{}{scheduleID}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, string(filter.Status))
+	}
+	query += ` ORDER BY started_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*models.ScheduleRun{}
+	for rows.Next() {
+		var (
+			run        models.ScheduleRun
+			status     string
+			jobIDs     sql.NullString
+			startedAt  string
+			finishedAt sql.NullString
+			scanID     sql.NullString
+		)
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &status, &jobIDs, &run.Message, &startedAt, &finishedAt, &scanID); err != nil {
+			return nil, err
+		}
+		run.Status = models.ScheduleRunStatus(status)
+		if err := unmarshalNullable(jobIDs, &run.JobIDs); err != nil {
+			return nil, err
+		}
+		if run.JobIDs == nil {
+			run.JobIDs = []string{}
+		}
+		run.StartedAt = parseTime(startedAt)
+		run.FinishedAt = parseTimePtr(finishedAt)
+		run.ScanID = scanID.String
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"napscan-be/internal/models"
+)
+
+const suppressionColumns = `id, name, tool, rule_id, host_glob, port, path_regex, severity, reason, expires_at, created_by, created_at, updated_at`
+
+func (r *SQLiteRepository) SaveSuppressionRule(ctx context.Context, rule *models.SuppressionRule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO suppression_rules (`+suppressionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			tool = excluded.tool,
+			rule_id = excluded.rule_id,
+			host_glob = excluded.host_glob,
+			port = excluded.port,
+			path_regex = excluded.path_regex,
+			severity = excluded.severity,
+			reason = excluded.reason,
+			expires_at = excluded.expires_at,
+			updated_at = excluded.updated_at`,
+		rule.ID, rule.Name, rule.Tool, rule.RuleID, rule.HostGlob, rule.Port, rule.PathRegex, string(rule.Severity),
+		rule.Reason, formatTimePtr(rule.ExpiresAt), rule.CreatedBy, formatTime(rule.CreatedAt), formatTime(rule.UpdatedAt))
+	return err
+}
+
+func (r *SQLiteRepository) SaveJobSuppressions(ctx context.Context, jobID string, suppressed map[string]string) error {
+	value, err := marshalNullable(suppressed)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE jobs SET suppressed = ? WHERE id = ?`, value, jobID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) GetSuppressionRule(ctx context.Context, id string) (*models.SuppressionRule, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+suppressionColumns+` FROM suppression_rules WHERE id = ?`, id)
+	rule, err := scanSuppressionRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rule, err
+}
+
+func (r *SQLiteRepository) ListSuppressionRules(ctx context.Context) ([]*models.SuppressionRule, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+suppressionColumns+` FROM suppression_rules ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*models.SuppressionRule{}
+	for rows.Next() {
+		rule, err := scanSuppressionRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *SQLiteRepository) DeleteSuppressionRule(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM suppression_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanSuppressionRule(row rowScanner) (*models.SuppressionRule, error) {
+	var (
+		rule                 models.SuppressionRule
+		severity             string
+		expiresAt            sql.NullString
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.Tool, &rule.RuleID, &rule.HostGlob, &rule.Port, &rule.PathRegex,
+		&severity, &rule.Reason, &expiresAt, &rule.CreatedBy, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	rule.Severity = models.Severity(severity)
+	rule.ExpiresAt = parseTimePtr(expiresAt)
+	rule.CreatedAt = parseTime(createdAt)
+	rule.UpdatedAt = parseTime(updatedAt)
+	return &rule, nil
+}
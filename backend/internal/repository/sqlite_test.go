@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobRoundTrip(t *testing.T) {
+	repo, err := OpenSQLite(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	started := time.Now()
+	job := &models.Job{
+		ID:        "job-1",
+		Tool:      models.ToolNuclei,
+		Target:    "example.com",
+		Options:   map[string]string{"severity": "high"},
+		Status:    models.JobStatusRunning,
+		CreatedAt: started,
+		StartedAt: &started,
+	}
+	require.NoError(t, repo.SaveJob(ctx, job))
+
+	job.Status = models.JobStatusCompleted
+	job.Progress = 100
+	job.Result = map[string]interface{}{"results": []string{"a"}}
+	require.NoError(t, repo.SaveJob(ctx, job))
+
+	got, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCompleted, got.Status)
+	assert.Equal(t, 100, got.Progress)
+	assert.Equal(t, "high", got.Options["severity"])
+	assert.True(t, got.StartedAt.Equal(started))
+	assert.Nil(t, got.FinishedAt)
+
+	raw, ok := got.Result.(json.RawMessage)
+	require.True(t, ok)
+	assert.JSONEq(t, `{"results":["a"]}`, string(raw))
+
+	_, err = repo.GetJob(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	jobs, err := repo.ListJobs(ctx, JobFilter{Tool: models.ToolNuclei})
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+}
+
+func TestOutputsAndMigrationsAreIdempotent(t *testing.T) {
+	repo, err := OpenSQLite(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	require.NoError(t, migrate(ctx, repo.db))
+
+	require.NoError(t, repo.SaveJob(ctx, &models.Job{ID: "job-1", Tool: models.ToolNmap, Target: "10.0.0.1", Status: models.JobStatusQueued, CreatedAt: time.Now()}))
+	require.NoError(t, repo.SaveOutput(ctx, &models.ToolOutput{
+		JobID: "job-1", Name: "tcp.xml", Tool: models.ToolNmap, ContentType: "application/xml",
+		Content: []byte("<nmaprun/>"), CreatedAt: time.Now(),
+	}))
+
+	outs, err := repo.ListOutputs(ctx, "job-1")
+	require.NoError(t, err)
+	require.Len(t, outs, 1)
+	assert.Equal(t, 10, outs[0].Size)
+
+	out, err := repo.GetOutput(ctx, "job-1", "tcp.xml")
+	require.NoError(t, err)
+	assert.Equal(t, "<nmaprun/>", string(out.Content))
+}
+
+func TestTimesSortWithinASecond(t *testing.T) {
+	repo, err := OpenSQLite(":memory:")
+	require.NoError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+
+	// RFC3339Nano would store the first as "12:00:00Z", which sorts after "12:00:00.5Z"
+	second := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, created := range []time.Time{second, second.Add(500 * time.Millisecond), second.Add(-time.Millisecond)} {
+		require.NoError(t, repo.SaveJob(ctx, &models.Job{ID: fmt.Sprintf("job-%d", i), Tool: models.ToolNmap, Target: "10.0.0.1", Status: models.JobStatusQueued, CreatedAt: created}))
+	}
+
+	jobs, err := repo.ListJobs(ctx, JobFilter{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+	assert.Equal(t, []string{"job-1", "job-0", "job-2"}, []string{jobs[0].ID, jobs[1].ID, jobs[2].ID})
+	assert.True(t, jobs[1].CreatedAt.Equal(second))
+}
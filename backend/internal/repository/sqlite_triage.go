@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"napscan-be/internal/models"
+)
+
+const triageColumns = `fingerprint, status, assignee, justification, accepted_until, updated_by, created_at, updated_at`
+
+// triageBatchSize keeps GetTriages below the SQLite limit on bound parameters
+const triageBatchSize = 500
+
+func (r *SQLiteRepository) SaveTriage(ctx context.Context, t *models.FindingTriage) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO finding_triage (`+triageColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (fingerprint) DO UPDATE SET
+			status = excluded.status,
+			assignee = excluded.assignee,
+			justification = excluded.justification,
+			accepted_until = excluded.accepted_until,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at`,
+		t.Fingerprint, string(t.Status), t.Assignee, t.Justification, formatTimePtr(t.AcceptedUntil),
+		t.UpdatedBy, formatTime(t.CreatedAt), formatTime(t.UpdatedAt))
+	return err
+}
+
+func (r *SQLiteRepository) GetTriage(ctx context.Context, fingerprint string) (*models.FindingTriage, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+triageColumns+` FROM finding_triage WHERE fingerprint = ?`, fingerprint)
+	t, err := scanTriage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+func (r *SQLiteRepository) GetTriages(ctx context.Context, fingerprints []string) (map[string]*models.FindingTriage, error) {
+	triages := make(map[string]*models.FindingTriage)
+	for start := 0; start < len(fingerprints); start += triageBatchSize {
+		end := start + triageBatchSize
+		if end > len(fingerprints) {
+			end = len(fingerprints)
+		}
+		batch := fingerprints[start:end]
+		args := make([]interface{}, len(batch))
+		for i, fp := range batch {
+			args[i] = fp
+		}
+
+		rows, err := r.db.QueryContext(ctx, `SELECT `+triageColumns+` FROM finding_triage
+			WHERE fingerprint IN (?`+strings.Repeat(", ?", len(batch)-1)+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			t, err := scanTriage(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			triages[t.Fingerprint] = t
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return triages, nil
+}
+
+func (r *SQLiteRepository) ListTriage(ctx context.Context, filter TriageFilter) ([]*models.FindingTriage, error) {
+	query := `SELECT ` + triageColumns + ` FROM finding_triage WHERE 1 = 1`
+	var args []interface{}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, string(filter.Status))
+	}
+	if filter.Assignee != "" {
+		query += ` AND assignee = ?`
+		args = append(args, filter.Assignee)
+	}
+	if !filter.AcceptedBefore.IsZero() {
+		query += ` AND accepted_until IS NOT NULL AND accepted_until < ?`
+		args = append(args, formatTime(filter.AcceptedBefore))
+	}
+	query += ` ORDER BY updated_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triages := []*models.FindingTriage{}
+	for rows.Next() {
+		t, err := scanTriage(rows)
+		if err != nil {
+			return nil, err
+		}
+		triages = append(triages, t)
+	}
+	return triages, rows.Err()
+}
+
+func scanTriage(row rowScanner) (*models.FindingTriage, error) {
+	var (
+		t                    models.FindingTriage
+		status               string
+		acceptedUntil        sql.NullString
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&t.Fingerprint, &status, &t.Assignee, &t.Justification, &acceptedUntil,
+		&t.UpdatedBy, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	t.Status = models.TriageStatus(status)
+	t.AcceptedUntil = parseTimePtr(acceptedUntil)
+	t.CreatedAt = parseTime(createdAt)
+	t.UpdatedAt = parseTime(updatedAt)
+	return &t, nil
+}
+
+// --- Transitions ---
+
+func (r *SQLiteRepository) SaveTransition(ctx context.Context, t *models.TriageTransition) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO finding_transitions (id, fingerprint, from_status, to_status, user_id, justification, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.Fingerprint, string(t.From), string(t.To), t.UserID, t.Justification, formatTime(t.CreatedAt))
+	return err
+}
+
+func (r *SQLiteRepository) ListTransitions(ctx context.Context, fingerprint string) ([]*models.TriageTransition, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, fingerprint, from_status, to_status, user_id, justification, created_at
+		FROM finding_transitions WHERE fingerprint = ? ORDER BY created_at`, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []*models.TriageTransition{}
+	for rows.Next() {
+		var (
+			t         models.TriageTransition
+			from, to  string
+			createdAt string
+		)
+		if err := rows.Scan(&t.ID, &t.Fingerprint, &from, &to, &t.UserID, &t.Justification, &createdAt); err != nil {
+			return nil, err
+		}
+		t.From = models.TriageStatus(from)
+		t.To = models.TriageStatus(to)
+		t.CreatedAt = parseTime(createdAt)
+		transitions = append(transitions, &t)
+	}
+	return transitions, rows.Err()
+}
+
+// --- Comments ---
+
+func (r *SQLiteRepository) SaveComment(ctx context.Context, c *models.FindingComment) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO finding_comments (id, fingerprint, parent_id, user_id, body, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		c.ID, c.Fingerprint, nullString(c.ParentID), c.UserID, c.Body, formatTime(c.CreatedAt))
+	return err
+}
+
+func (r *SQLiteRepository) ListComments(ctx context.Context, fingerprint string) ([]*models.FindingComment, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, fingerprint, parent_id, user_id, body, created_at
+		FROM finding_comments WHERE fingerprint = ? ORDER BY created_at`, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.FindingComment{}
+	for rows.Next() {
+		var (
+			c         models.FindingComment
+			parentID  sql.NullString
+			createdAt string
+		)
+		if err := rows.Scan(&c.ID, &c.Fingerprint, &parentID, &c.UserID, &c.Body, &createdAt); err != nil {
+			return nil, err
+		}
+		c.ParentID = parentID.String
+		c.CreatedAt = parseTime(createdAt)
+		comments = append(comments, &c)
+	}
+	return comments, rows.Err()
+}
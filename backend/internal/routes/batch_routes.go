@@ -8,18 +8,8 @@ import (
 )
 
 func BatchRoutes(router fiber.Router, h *handler.BatchHandler) {
-	// Group is typically /api passed in
-	// We want /api/a, /api/b etc. but protected
-	
-	protected := router.Group("/", middleware.AuthMiddleware()) 
-
-	// Fan-in endpoints
-	protected.Post("/a", h.HandleScanPartA)
-	protected.Post("/b", h.HandleScanPartB)
-	protected.Post("/c", h.HandleScanPartC)
-	protected.Post("/d", h.HandleScanPartD)
-	protected.Post("/e", h.HandleScanPartE)
-
-	// Result Retrieval
-	protected.Get("/analysis/:batch_id", h.GetBatchResult)
+	group := router.Group("/batches", middleware.AuthMiddleware())
+	group.Post("/", h.CreateBatch)
+	group.Get("/:batch_id", h.GetBatchResult)
+	group.Post("/:batch_id/results/:source", h.AddBatchResult)
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"napscan-be/internal/handler"
+	"napscan-be/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func EnrichmentRoutes(router fiber.Router, h *handler.EnrichmentHandler) {
+	group := router.Group("/admin/enrichment", middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	group.Get("/", h.GetFeeds)
+	group.Post("/:feed", h.UpdateFeed)
+}
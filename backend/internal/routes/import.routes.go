@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"napscan-be/internal/handler"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ImportRoutes(router fiber.Router, h *handler.ScanHandler) {
+	router.Post("/imports", h.ImportScan)
+}
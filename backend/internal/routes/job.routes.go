@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"napscan-be/internal/handler"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func JobRoutes(router fiber.Router, h *handler.JobHandler) {
+	router.Get("/queue", h.GetQueue)
+
+	group := router.Group("/jobs")
+	group.Get("/", h.ListJobs)
+	group.Get("/:id", h.GetJob)
+	group.Get("/:id/events", h.StreamEvents)
+	group.Delete("/:id", h.CancelJob)
+	group.Post("/:id/cancel", h.CancelJob)
+	group.Post("/:id/requeue", h.RequeueJob)
+	group.Get("/:id/outputs", h.ListOutputs)
+	group.Get("/:id/outputs/:name", h.GetOutput)
+}
@@ -2,10 +2,16 @@ package routes
 
 import (
 	"napscan-be/internal/handler"
+	"napscan-be/internal/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func NmapRoutes(router fiber.Router, h *handler.NmapHandler) {
 	router.Post("/nmap/scan", h.StartFullScan)
+	router.Get("/nmap/profiles", h.ListProfiles)
+	router.Get("/nmap/profiles/:name", h.GetProfile)
+	router.Post("/nmap/profiles", middleware.AuthMiddleware(), middleware.AdminMiddleware(), h.CreateProfile)
+	router.Put("/nmap/profiles/:name", middleware.AuthMiddleware(), middleware.AdminMiddleware(), h.UpdateProfile)
+	router.Delete("/nmap/profiles/:name", middleware.AuthMiddleware(), middleware.AdminMiddleware(), h.DeleteProfile)
 }
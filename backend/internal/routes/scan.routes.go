@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"napscan-be/internal/handler"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ScanRoutes(router fiber.Router, h *handler.ScanHandler) {
+	group := router.Group("/scans")
+	group.Get("/", h.ListScans)
+	group.Post("/", h.CreateScan)
+	group.Get("/:id", h.GetScan)
+	group.Get("/:id/findings", h.GetScanFindings)
+	group.Get("/:id/risk", h.GetScanRisk)
+	group.Get("/:id/diff", h.GetScanDiff)
+	group.Delete("/:id", h.CancelScan)
+}
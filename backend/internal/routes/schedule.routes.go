@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"napscan-be/internal/handler"
+	"napscan-be/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ScheduleRoutes(router fiber.Router, h *handler.ScheduleHandler) {
+	group := router.Group("/schedules", middleware.AuthMiddleware())
+	group.Get("/", h.ListSchedules)
+	group.Post("/", h.CreateSchedule)
+	group.Get("/:id", h.GetSchedule)
+	group.Put("/:id", h.UpdateSchedule)
+	group.Delete("/:id", h.DeleteSchedule)
+	group.Get("/:id/runs", h.ListScheduleRuns)
+}
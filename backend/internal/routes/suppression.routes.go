@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"napscan-be/internal/handler"
+	"napscan-be/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SuppressionRoutes(router fiber.Router, h *handler.SuppressionHandler) {
+	group := router.Group("/suppressions", middleware.AuthMiddleware())
+	group.Get("/", h.ListSuppressions)
+	group.Post("/", middleware.AdminMiddleware(), h.CreateSuppression)
+	group.Get("/:id", h.GetSuppression)
+	group.Put("/:id", middleware.AdminMiddleware(), h.UpdateSuppression)
+	group.Delete("/:id", middleware.AdminMiddleware(), h.DeleteSuppression)
+}
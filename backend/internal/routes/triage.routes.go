@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"napscan-be/internal/handler"
+	"napscan-be/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TriageRoutes(router fiber.Router, h *handler.TriageHandler) {
+	group := router.Group("/findings", middleware.AuthMiddleware())
+	group.Get("/triage", h.ListTriage)
+	group.Get("/:fingerprint/triage", h.GetTriage)
+	group.Put("/:fingerprint/triage", h.UpdateTriage)
+	group.Post("/:fingerprint/comments", h.CreateComment)
+}
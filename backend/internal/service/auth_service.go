@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
@@ -17,11 +18,12 @@ import (
 	"google.golang.org/api/idtoken"
 )
 
-type AuthService struct{
+type AuthService struct {
 	oauthConfig *oauth2.Config
+	users       repository.UserRepository
 }
 
-func NewAuthService() *AuthService {
+func NewAuthService(users repository.UserRepository) *AuthService {
 	// Initialize OAuth2 config for server-side flow
 	config := &oauth2.Config{
 		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
@@ -36,9 +38,15 @@ func NewAuthService() *AuthService {
 
 	return &AuthService{
 		oauthConfig: config,
+		users:       users,
 	}
 }
 
+// RecordLogin stores the user profile so it survives restarts
+func (s *AuthService) RecordLogin(ctx context.Context, user *models.User) error {
+	return s.users.SaveUser(ctx, user)
+}
+
 // GetGoogleLoginURL returns the URL to redirect the user to for Google Login
 func (s *AuthService) GetGoogleLoginURL() string {
 	// State should be randomized in production to prevent CSRF
@@ -88,11 +96,10 @@ func (s *AuthService) HandleGoogleCallback(ctx context.Context, code string) (*m
 	}, nil
 }
 
-
 // VerifyGoogleToken validates the Google ID token and extracts user info (Client-Side Flow)
 func (s *AuthService) VerifyGoogleToken(ctx context.Context, tokenString string) (*models.User, error) {
 	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	
+
 	payload, err := idtoken.Validate(ctx, tokenString, clientID)
 	if err != nil {
 		return nil, err
@@ -102,7 +109,7 @@ func (s *AuthService) VerifyGoogleToken(ctx context.Context, tokenString string)
 	email, _ := payload.Claims["email"].(string)
 	name, _ := payload.Claims["name"].(string)
 	picture, _ := payload.Claims["picture"].(string)
-	
+
 	return &models.User{
 		ID:      userID,
 		Email:   email,
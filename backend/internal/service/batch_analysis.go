@@ -0,0 +1,65 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"napscan-be/internal/models"
+)
+
+// analyzeBatch counts the findings of every source per severity and scores their risk.
+// The overall counts and the score count an issue reported by several sources once.
+// Findings hidden by a suppression rule are only counted as suppressed.
+func analyzeBatch(results map[string]interface{}, enrichment *EnrichmentService, suppression *SuppressionService, risk *RiskScorer) *models.BatchAnalysis {
+	analysis := &models.BatchAnalysis{
+		Findings:   newSeverityCounts(),
+		Sources:    make(map[string]map[models.Severity]int, len(results)),
+		AnalyzedAt: time.Now(),
+	}
+
+	var all []models.Finding
+	for source, result := range results {
+		counts := newSeverityCounts()
+		findings, err := normalizeResult(source, result)
+		if err != nil {
+			log.Printf("Batch analysis skipped the %s result: %v", source, err)
+		}
+		if source == models.ToolNmap {
+			findings = append(findings, enrichment.matchVersions(result)...)
+		}
+		suppression.Apply(findings)
+		for _, f := range findings {
+			if f.SuppressedBy == "" {
+				counts[f.Severity]++
+			}
+		}
+		analysis.Sources[source] = counts
+		all = append(all, findings...)
+	}
+
+	var merged []models.Finding
+	for _, f := range dedupFindings(all) {
+		if f.SuppressedBy != "" {
+			analysis.Suppressed++
+			continue
+		}
+		merged = append(merged, f)
+		analysis.Findings[f.Severity]++
+		analysis.Total++
+	}
+	enrichment.Enrich(merged)
+
+	// Batches carry no asset criticality, their findings are scored as medium
+	scored := risk.ScoreFindings(merged, "")
+	analysis.RiskScore = scored.Risk.Score
+	analysis.Risk = &scored
+	return analysis
+}
+
+func newSeverityCounts() map[models.Severity]int {
+	counts := make(map[models.Severity]int, len(models.Severities))
+	for _, sev := range models.Severities {
+		counts[sev] = 0
+	}
+	return counts
+}
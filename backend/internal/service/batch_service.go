@@ -1,14 +1,98 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBatchNotFound  = errors.New("batch not found")
+	ErrBatchExists    = errors.New("batch already exists")
+	ErrInvalidBatch   = errors.New("invalid batch")
+	ErrBatchExpired   = errors.New("batch expired")
+	ErrBatchLimit     = errors.New("too many open batches")
+	ErrResultTooLarge = errors.New("result too large")
 )
 
+// BatchConfig bounds what batches can hold and how long they are kept.
+// A limit of 0 means unlimited.
+type BatchConfig struct {
+	// OpenTTL expires batches that have not received a result for that long
+	OpenTTL time.Duration
+	// CompletedTTL expires analyzed batches that long after their analysis
+	CompletedTTL time.Duration
+	// MaxOpenPerUser caps the batches a user can have waiting for results
+	MaxOpenPerUser int
+	// MaxResultBytes caps the JSON size of the result of one source
+	MaxResultBytes int
+	// JanitorInterval is how often expired batches are looked for
+	JanitorInterval time.Duration
+}
+
+// DefaultBatchConfig gives clients an hour to deliver every source and keeps the
+// analysis for a day
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		OpenTTL:         time.Hour,
+		CompletedTTL:    24 * time.Hour,
+		MaxOpenPerUser:  20,
+		MaxResultBytes:  10 * 1024 * 1024,
+		JanitorInterval: time.Minute,
+	}
+}
+
+// BatchConfigFromEnv reads NAPSCAN_BATCH_OPEN_TTL, NAPSCAN_BATCH_COMPLETED_TTL (Go
+// durations such as "90m"), NAPSCAN_MAX_OPEN_BATCHES and NAPSCAN_BATCH_MAX_RESULT_BYTES
+// on top of DefaultBatchConfig
+func BatchConfigFromEnv() BatchConfig {
+	cfg := DefaultBatchConfig()
+
+	durations := map[string]*time.Duration{
+		"NAPSCAN_BATCH_OPEN_TTL":      &cfg.OpenTTL,
+		"NAPSCAN_BATCH_COMPLETED_TTL": &cfg.CompletedTTL,
+	}
+	for name, target := range durations {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				log.Printf("Ignoring invalid %s %q", name, v)
+				continue
+			}
+			*target = d
+		}
+	}
+
+	limits := map[string]*int{
+		"NAPSCAN_MAX_OPEN_BATCHES":       &cfg.MaxOpenPerUser,
+		"NAPSCAN_BATCH_MAX_RESULT_BYTES": &cfg.MaxResultBytes,
+	}
+	for name, target := range limits {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				log.Printf("Ignoring invalid %s %q", name, v)
+				continue
+			}
+			*target = n
+		}
+	}
+
+	return cfg
+}
+
 // SafeBatch wraps the Batch model with a mutex for thread safety
 type SafeBatch struct {
 	mu              sync.Mutex
@@ -16,86 +100,314 @@ type SafeBatch struct {
 	analysisStarted bool
 }
 
+// BatchService fans in the results of several tools and analyzes them together
+// once every source declared at creation has reported. Only batches in use are kept
+// in memory; the janitor started by Start expires stale ones and evicts finished ones.
 type BatchService struct {
 	// batches stores pointers to SafeBatch, key is batchID
-	batches sync.Map
+	batches     sync.Map
+	repo        repository.BatchRepository
+	jobs        *JobService
+	risk        *RiskScorer
+	enrichment  *EnrichmentService
+	suppression *SuppressionService
+	cfg         BatchConfig
+	// createMu makes the open batch count and the insert of a new batch atomic
+	createMu sync.Mutex
 }
 
-var (
-	batchServiceInstance *BatchService
-	batchServiceOnce     sync.Once
-)
+func NewBatchService(repo repository.BatchRepository, jobs *JobService, risk *RiskScorer, enrichment *EnrichmentService, suppression *SuppressionService, cfg BatchConfig) *BatchService {
+	return &BatchService{repo: repo, jobs: jobs, risk: risk, enrichment: enrichment, suppression: suppression, cfg: cfg}
+}
 
-func NewBatchService() *BatchService {
-	batchServiceOnce.Do(func() {
-		batchServiceInstance = &BatchService{}
-	})
-	return batchServiceInstance
-}
-
-// AddResult contributes a result to a batch.
-// If valid and complete, triggers analysis.
-func (s *BatchService) AddResult(userID, batchID string, source string, data interface{}) error {
-	// 1. Get or Create Batch
-	val, _ := s.batches.LoadOrStore(batchID, &SafeBatch{
-		Batch: &models.Batch{
-			UserID:        userID,
-			BatchID:       batchID,
-			ExpectedCount: 5, // Hardcoded requirement
-			ReceivedCount: 0,
-			Results:       make(map[string]interface{}),
-			Status:        models.BatchStatusProcessing,
-			CreatedAt:     time.Now(),
-		},
+// Start runs the janitor until ctx is cancelled
+func (s *BatchService) Start(ctx context.Context) {
+	interval := s.cfg.JanitorInterval
+	if interval <= 0 {
+		interval = DefaultBatchConfig().JanitorInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.sweep(time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.sweep(now)
+			}
+		}
+	}()
+}
+
+// sweep expires the batches whose TTL ran out and evicts the batches that will not
+// change anymore from memory. Evicted batches are reloaded from the repository on access.
+func (s *BatchService) sweep(now time.Time) {
+	s.batches.Range(func(key, val interface{}) bool {
+		sb := val.(*SafeBatch)
+		sb.mu.Lock()
+		s.expireIfStale(sb, now)
+		idle := sb.Batch.Status != models.BatchStatusProcessing
+		sb.mu.Unlock()
+		if idle {
+			s.batches.Delete(key)
+		}
+		return true
 	})
-	
-	safeBatch := val.(*SafeBatch)
-	
-	// Lock the individual batch
+
+	// Batches that are only in the repository, e.g. left open before a restart
+	ctx := context.Background()
+	for status, ttl := range map[models.BatchStatus]time.Duration{
+		models.BatchStatusProcessing: s.cfg.OpenTTL,
+		models.BatchStatusComplete:   s.cfg.CompletedTTL,
+	} {
+		if ttl <= 0 {
+			continue
+		}
+		ids, err := s.repo.ListBatchIDs(ctx, repository.BatchFilter{Status: status, UpdatedBefore: now.Add(-ttl)})
+		if err != nil {
+			log.Printf("Failed to list stale batches: %v", err)
+			continue
+		}
+		for _, id := range ids {
+			sb, ok := s.loadBatch(id)
+			if !ok {
+				continue
+			}
+			sb.mu.Lock()
+			expired := s.expireIfStale(sb, now)
+			sb.mu.Unlock()
+			if expired {
+				s.batches.Delete(id)
+			}
+		}
+	}
+}
+
+// expireIfStale expires the batch once its TTL ran out and reports whether it is
+// expired. Caller must hold sb.mu.
+func (s *BatchService) expireIfStale(sb *SafeBatch, now time.Time) bool {
+	b := sb.Batch
+	switch b.Status {
+	case models.BatchStatusExpired:
+		return true
+	case models.BatchStatusProcessing:
+		// An analysis that is running will complete the batch in a moment
+		if sb.analysisStarted || s.cfg.OpenTTL <= 0 || now.Sub(b.UpdatedAt) < s.cfg.OpenTTL {
+			return false
+		}
+	case models.BatchStatusComplete:
+		if s.cfg.CompletedTTL <= 0 || now.Sub(b.UpdatedAt) < s.cfg.CompletedTTL {
+			return false
+		}
+	}
+
+	b.Status = models.BatchStatusExpired
+	b.Results = make(map[string]interface{})
+	b.AnalysisResult = nil
+	s.persist(sb)
+	return true
+}
+
+// loadBatch returns the live batch, restoring it from the repository after a restart
+func (s *BatchService) loadBatch(batchID string) (*SafeBatch, bool) {
+	if val, ok := s.batches.Load(batchID); ok {
+		return val.(*SafeBatch), true
+	}
+
+	stored, err := s.repo.GetBatch(context.Background(), batchID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("Failed to load batch %s: %v", batchID, err)
+		}
+		return nil, false
+	}
+
+	sb := &SafeBatch{
+		Batch:           stored,
+		analysisStarted: stored.Status == models.BatchStatusComplete,
+	}
+	val, loaded := s.batches.LoadOrStore(batchID, sb)
+	if !loaded && !sb.analysisStarted && stored.ExpectedCount > 0 && stored.ReceivedCount >= stored.ExpectedCount {
+		// The backend stopped between the last result and the end of the analysis
+		sb.analysisStarted = true
+		go s.runAnalysis(sb)
+	}
+	return val.(*SafeBatch), true
+}
+
+// persist writes the batch through to the repository. Caller must hold sb.mu.
+func (s *BatchService) persist(sb *SafeBatch) {
+	sb.Batch.UpdatedAt = time.Now()
+	if err := s.repo.SaveBatch(context.Background(), sb.Batch); err != nil {
+		log.Printf("Failed to persist batch %s: %v", sb.Batch.BatchID, err)
+	}
+}
+
+// Create opens a batch that waits for one result per source. Sources are tool names.
+func (s *BatchService) Create(userID string, req models.BatchCreateRequest) (*models.Batch, error) {
+	if len(req.Sources) == 0 {
+		return nil, fmt.Errorf("%w: at least one source is required", ErrInvalidBatch)
+	}
+	seen := make(map[string]bool, len(req.Sources))
+	sources := make([]string, 0, len(req.Sources))
+	for _, source := range req.Sources {
+		if !s.jobs.HasTool(source) {
+			return nil, fmt.Errorf("%w: unknown source %q", ErrInvalidBatch, source)
+		}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+
+	batchID := strings.TrimSpace(req.BatchID)
+	if batchID == "" {
+		batchID = uuid.NewString()
+	}
+
+	now := time.Now()
+	sb := &SafeBatch{Batch: &models.Batch{
+		UserID:        userID,
+		BatchID:       batchID,
+		Sources:       sources,
+		ExpectedCount: len(sources),
+		Results:       make(map[string]interface{}),
+		Status:        models.BatchStatusProcessing,
+		CreatedAt:     now,
+	}}
+
+	s.createMu.Lock()
+	defer s.createMu.Unlock()
+
+	if s.cfg.MaxOpenPerUser > 0 {
+		open, err := s.repo.CountBatches(context.Background(), repository.BatchFilter{UserID: userID, Status: models.BatchStatusProcessing})
+		if err != nil {
+			return nil, err
+		}
+		if open >= s.cfg.MaxOpenPerUser {
+			return nil, fmt.Errorf("%w: %d batches are still waiting for results", ErrBatchLimit, open)
+		}
+	}
+
+	// A batch ID can only be used once, also across restarts
+	if _, ok := s.loadBatch(batchID); ok {
+		return nil, ErrBatchExists
+	}
+	if _, loaded := s.batches.LoadOrStore(batchID, sb); loaded {
+		return nil, ErrBatchExists
+	}
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	s.persist(sb)
+	return copyBatch(sb.Batch), nil
+}
+
+// AddResult contributes the result of one source to a batch, either taken from a
+// completed job of that tool or given raw. The analysis starts once every source has
+// reported; results of a source that already reported are ignored.
+func (s *BatchService) AddResult(userID, batchID, source string, req models.BatchResultRequest) (*models.Batch, error) {
+	safeBatch, ok := s.loadBatch(batchID)
+	if !ok {
+		return nil, ErrBatchNotFound
+	}
+
+	// Resolve the result before locking, the job lookup hits the repository
+	data, err := s.resolveResult(source, req)
+	if err != nil {
+		return nil, err
+	}
+
 	safeBatch.mu.Lock()
 	defer safeBatch.mu.Unlock()
 
-	// 2. Validate Ownership
 	if safeBatch.Batch.UserID != userID {
-		return errors.New("batch access denied: user_id mismatch")
+		return nil, ErrBatchNotFound
+	}
+	if s.expireIfStale(safeBatch, time.Now()) {
+		return nil, ErrBatchExpired
+	}
+	if !containsString(safeBatch.Batch.Sources, source) {
+		return nil, fmt.Errorf("%w: %s is not a source of this batch", ErrInvalidBatch, source)
 	}
-
-	// 3. Validate State
 	if safeBatch.Batch.Status == models.BatchStatusComplete {
-		// Depending on requirement, we might allow overwriting or error out.
-		// Usually analysis is done once.
-		return nil // Already done, ignore extra calls or return error
+		return copyBatch(safeBatch.Batch), nil
 	}
 
-	// 4. Add Result (Idempotency check for same source)
 	if _, exists := safeBatch.Batch.Results[source]; !exists {
 		safeBatch.Batch.Results[source] = data
 		safeBatch.Batch.ReceivedCount++
+		s.persist(safeBatch)
 	}
 
-	// 5. Check Completion
 	// Trigger strictly when we hit the count and haven't started yet
 	if safeBatch.Batch.ReceivedCount >= safeBatch.Batch.ExpectedCount && !safeBatch.analysisStarted {
 		safeBatch.analysisStarted = true
-		// Launch Analysis in background
 		go s.runAnalysis(safeBatch)
 	}
 
+	return copyBatch(safeBatch.Batch), nil
+}
+
+// resolveResult returns the tool result a request points at
+func (s *BatchService) resolveResult(source string, req models.BatchResultRequest) (interface{}, error) {
+	if req.JobID == "" {
+		if len(req.Result) == 0 {
+			return nil, fmt.Errorf("%w: job_id or result is required", ErrInvalidBatch)
+		}
+		if err := s.checkResultSize(len(req.Result)); err != nil {
+			return nil, err
+		}
+		var data interface{}
+		if err := json.Unmarshal(req.Result, &data); err != nil {
+			return nil, fmt.Errorf("%w: result is not valid JSON", ErrInvalidBatch)
+		}
+		return data, nil
+	}
+
+	job, err := s.jobs.Get(req.JobID)
+	if errors.Is(err, ErrJobNotFound) {
+		return nil, fmt.Errorf("%w: job %s not found", ErrInvalidBatch, req.JobID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if job.Tool != source {
+		return nil, fmt.Errorf("%w: job %s is a %s job, not %s", ErrInvalidBatch, job.ID, job.Tool, source)
+	}
+	if job.Status != models.JobStatusCompleted {
+		return nil, fmt.Errorf("%w: job %s is %s", ErrInvalidBatch, job.ID, job.Status)
+	}
+	if s.cfg.MaxResultBytes > 0 {
+		raw, err := json.Marshal(job.Result)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkResultSize(len(raw)); err != nil {
+			return nil, err
+		}
+	}
+	return job.Result, nil
+}
+
+func (s *BatchService) checkResultSize(n int) error {
+	if s.cfg.MaxResultBytes > 0 && n > s.cfg.MaxResultBytes {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrResultTooLarge, n, s.cfg.MaxResultBytes)
+	}
 	return nil
 }
 
 // runAnalysis is the aggregation logic
 func (s *BatchService) runAnalysis(sb *SafeBatch) {
-	// SB is a pointer to the SafeBatch in the map.
-	// We need to lock to read results, but analysis itself could take time.
-	// We'll read the data first.
-	
 	sb.mu.Lock()
 	if sb.Batch.Status == models.BatchStatusComplete {
 		sb.mu.Unlock()
 		return
 	}
-	
+
 	// Create a copy for analysis to avoid holding lock during heavy computation
 	resultsCopy := make(map[string]interface{})
 	for k, v := range sb.Batch.Results {
@@ -103,54 +415,49 @@ func (s *BatchService) runAnalysis(sb *SafeBatch) {
 	}
 	sb.mu.Unlock()
 
-	// --- ANALYSIS LOGIC ---
-	// "Implement Analyze(results map[string]any) any"
-	// Example: just summarizing keys
-	
-	// Simulate processing time
-	time.Sleep(500 * time.Millisecond) 
-	
-	analysisResult := map[string]interface{}{
-		"summary": fmt.Sprintf("Processed %d sources", len(resultsCopy)),
-		"sources": resultsCopy,
-		"timestamp": time.Now(),
-		"risk_score": 0, // Mock logic
-	}
-
-	// --- SAVE RESULT ---
+	analysis := analyzeBatch(resultsCopy, s.enrichment, s.suppression, s.risk)
+
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
-	sb.Batch.AnalysisResult = analysisResult
+	sb.Batch.AnalysisResult = analysis
 	sb.Batch.Status = models.BatchStatusComplete
-	
-	// TODO: Here we would persist to Redis/DB
+	s.persist(sb)
 }
 
 // GetBatch retrieves the batch status and result
 func (s *BatchService) GetBatch(userID, batchID string) (*models.Batch, error) {
-	val, ok := s.batches.Load(batchID)
+	sb, ok := s.loadBatch(batchID)
 	if !ok {
-		return nil, errors.New("batch not found")
+		return nil, ErrBatchNotFound
 	}
 
-	sb := val.(*SafeBatch)
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 
+	// Batches of other users are reported as missing, like schedules
 	if sb.Batch.UserID != userID {
-		return nil, errors.New("unauthorized batch access")
+		return nil, ErrBatchNotFound
 	}
+	s.expireIfStale(sb, time.Now())
+	return copyBatch(sb.Batch), nil
+}
 
-	// Return a deep copy to avoid race conditions with ongoing updates
-	copyBatch := *sb.Batch
-	// Deep copy map if needed, but for json serialization in controller this shallow copy of struct (with map ref)
-	// might be risky if map is being written to.
-	// But GetBatch is read-only usually. 
-	// To be safe, let's copy the Results map.
-	copyBatch.Results = make(map[string]interface{})
-	for k, v := range sb.Batch.Results {
-		copyBatch.Results[k] = v
+// copyBatch returns a copy whose maps can be serialized while the batch is updated
+func copyBatch(b *models.Batch) *models.Batch {
+	c := *b
+	c.Sources = append([]string(nil), b.Sources...)
+	c.Results = make(map[string]interface{}, len(b.Results))
+	for k, v := range b.Results {
+		c.Results[k] = v
 	}
+	return &c
+}
 
-	return &copyBatch, nil
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchAnalyzesDeclaredSources(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	jobs.Register(models.ToolNuclei, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return map[string]interface{}{"results": []map[string]interface{}{
+			{"template-id": "CVE-2021-44228", "info": map[string]interface{}{"severity": "critical"}},
+			{"template-id": "exposed-panel", "info": map[string]interface{}{"severity": "medium"}},
+		}}, nil
+	})
+	jobs.Register(models.ToolZap, func(ctx context.Context, run *JobRun) (interface{}, error) { return nil, nil })
+	s := NewBatchService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), NewEnrichmentService(t.TempDir()), NewSuppressionService(repo, jobs), DefaultBatchConfig())
+
+	_, err = s.Create("alice", models.BatchCreateRequest{Sources: []string{"api_a"}})
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+
+	batch, err := s.Create("alice", models.BatchCreateRequest{BatchID: "b1", Sources: []string{models.ToolNuclei, models.ToolZap}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, batch.ExpectedCount)
+	_, err = s.Create("alice", models.BatchCreateRequest{BatchID: "b1", Sources: []string{models.ToolNuclei}})
+	assert.ErrorIs(t, err, ErrBatchExists)
+
+	job, err := jobs.Submit(models.JobRequest{Tool: models.ToolNuclei, Target: "https://example.com"})
+	require.NoError(t, err)
+	waitForStatus(t, jobs, job.ID, models.JobStatusCompleted)
+
+	_, err = s.AddResult("bob", "b1", models.ToolNuclei, models.BatchResultRequest{JobID: job.ID})
+	assert.ErrorIs(t, err, ErrBatchNotFound)
+	_, err = s.AddResult("alice", "b1", models.ToolZap, models.BatchResultRequest{JobID: job.ID})
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+
+	batch, err = s.AddResult("alice", "b1", models.ToolNuclei, models.BatchResultRequest{JobID: job.ID})
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchStatusProcessing, batch.Status)
+
+	alerts := json.RawMessage(`{"alertsRaw":{"alerts":[{"pluginId":"40012","risk":"High"},{"pluginId":"10096","risk":"Informational"}]}}`)
+	_, err = s.AddResult("alice", "b1", models.ToolZap, models.BatchResultRequest{Result: alerts})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		batch, err = s.GetBatch("alice", "b1")
+		return err == nil && batch.Status == models.BatchStatusComplete
+	}, 2*time.Second, 10*time.Millisecond)
+
+	analysis := batch.AnalysisResult
+	require.NotNil(t, analysis)
+	assert.Equal(t, 4, analysis.Total)
+	assert.Equal(t, map[models.Severity]int{
+		models.SeverityCritical: 1, models.SeverityHigh: 1, models.SeverityMedium: 1,
+		models.SeverityLow: 0, models.SeverityInfo: 1,
+	}, analysis.Findings)
+	assert.Equal(t, 1, analysis.Sources[models.ToolZap][models.SeverityHigh])
+	// The critical finding scores 95, high and medium add 75 + 50 points on top
+	assert.InDelta(t, 98.6, analysis.RiskScore, 0.01)
+	require.NotNil(t, analysis.Risk)
+	assert.Len(t, analysis.Risk.Hosts, 1)
+
+	// The analysis survives a restart
+	stored, err := NewBatchService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), NewEnrichmentService(t.TempDir()), NewSuppressionService(repo, jobs), DefaultBatchConfig()).GetBatch("alice", "b1")
+	require.NoError(t, err)
+	assert.Equal(t, analysis.Findings, stored.AnalysisResult.Findings)
+}
+
+func TestBatchExpiryAndLimits(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	jobs.Register(models.ToolNuclei, func(ctx context.Context, run *JobRun) (interface{}, error) { return nil, nil })
+	jobs.Register(models.ToolZap, func(ctx context.Context, run *JobRun) (interface{}, error) { return nil, nil })
+	s := NewBatchService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), NewEnrichmentService(t.TempDir()), NewSuppressionService(repo, jobs), BatchConfig{OpenTTL: time.Hour, CompletedTTL: time.Hour, MaxOpenPerUser: 2, MaxResultBytes: 64})
+
+	sources := []string{models.ToolNuclei, models.ToolZap}
+	_, err = s.Create("alice", models.BatchCreateRequest{BatchID: "old", Sources: sources})
+	require.NoError(t, err)
+	_, err = s.Create("alice", models.BatchCreateRequest{BatchID: "new", Sources: sources})
+	require.NoError(t, err)
+	_, err = s.Create("alice", models.BatchCreateRequest{Sources: sources})
+	assert.ErrorIs(t, err, ErrBatchLimit)
+	_, err = s.Create("bob", models.BatchCreateRequest{Sources: sources})
+	assert.NoError(t, err)
+
+	big := json.RawMessage(`{"results":["` + strings.Repeat("x", 64) + `"]}`)
+	_, err = s.AddResult("alice", "new", models.ToolNuclei, models.BatchResultRequest{Result: big})
+	assert.ErrorIs(t, err, ErrResultTooLarge)
+	_, err = s.AddResult("alice", "old", models.ToolNuclei, models.BatchResultRequest{Result: json.RawMessage(`{"results":[]}`)})
+	require.NoError(t, err)
+
+	// Two hours later nobody delivered the zap result of "old"
+	s.sweep(time.Now().Add(2 * time.Hour))
+
+	batch, err := s.GetBatch("alice", "old")
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchStatusExpired, batch.Status)
+	assert.Empty(t, batch.Results)
+	_, err = s.AddResult("alice", "old", models.ToolZap, models.BatchResultRequest{Result: json.RawMessage(`{}`)})
+	assert.ErrorIs(t, err, ErrBatchExpired)
+
+	// Expired batches no longer count as open
+	_, err = s.Create("alice", models.BatchCreateRequest{Sources: sources})
+	assert.NoError(t, err)
+}
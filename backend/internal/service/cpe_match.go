@@ -0,0 +1,339 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"napscan-be/internal/models"
+)
+
+// cpe is the part of a CPE name the version matching looks at
+type cpe struct {
+	part, vendor, product, version, update string
+}
+
+// parseCPE reads CPE 2.2 URIs as nmap writes them ("cpe:/a:openbsd:openssh:8.2p1")
+// and CPE 2.3 formatted strings as the NVD writes them ("cpe:2.3:a:openbsd:openssh:8.2:p1:...")
+func parseCPE(s string) (cpe, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	var fields []string
+	switch {
+	case strings.HasPrefix(s, "cpe:2.3:"):
+		fields = splitCPE23(strings.TrimPrefix(s, "cpe:2.3:"))
+	case strings.HasPrefix(s, "cpe:/"):
+		fields = strings.Split(strings.TrimPrefix(s, "cpe:/"), ":")
+	default:
+		return cpe{}, false
+	}
+	if len(fields) < 3 || fields[1] == "" || fields[2] == "" {
+		return cpe{}, false
+	}
+	for len(fields) < 5 {
+		fields = append(fields, "")
+	}
+	return cpe{part: fields[0], vendor: fields[1], product: fields[2], version: fields[3], update: fields[4]}, true
+}
+
+// splitCPE23 splits on the colons that are not escaped and drops the escapes
+func splitCPE23(s string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			field.WriteByte(s[i])
+		case s[i] == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(s[i])
+		}
+	}
+	return append(fields, field.String())
+}
+
+func (c cpe) key() string {
+	return c.part + ":" + c.vendor + ":" + c.product
+}
+
+// cpeRule is one vulnerable CPE match of a CVE in the NVD configurations. Version is
+// an exact version, or "*" with the range in the bounds.
+type cpeRule struct {
+	CVE                   string
+	Criteria              string
+	Version               string
+	VersionStartIncluding string
+	VersionStartExcluding string
+	VersionEndIncluding   string
+	VersionEndExcluding   string
+}
+
+// matches reports whether version falls in the rule. Rules for any version without
+// a range mark a whole product as vulnerable, which says nothing about the version
+// found, so they never match.
+func (r cpeRule) matches(version string) bool {
+	switch r.Version {
+	case "-":
+		return false
+	case "*", "":
+	default:
+		return compareVersions(version, r.Version) == 0
+	}
+
+	bounded := false
+	if b := r.VersionStartIncluding; b != "" {
+		bounded = true
+		if compareVersions(version, b) < 0 {
+			return false
+		}
+	}
+	if b := r.VersionStartExcluding; b != "" {
+		bounded = true
+		if compareVersions(version, b) <= 0 {
+			return false
+		}
+	}
+	if b := r.VersionEndIncluding; b != "" {
+		bounded = true
+		if compareVersions(version, b) > 0 {
+			return false
+		}
+	}
+	if b := r.VersionEndExcluding; b != "" {
+		bounded = true
+		if compareVersions(version, b) >= 0 {
+			return false
+		}
+	}
+	return bounded
+}
+
+// describe writes the rule the way it reads in an advisory, e.g. "versions from 2.0 before 2.15.0"
+func (r cpeRule) describe() string {
+	if r.Version != "*" && r.Version != "" {
+		return "version " + r.Version
+	}
+	var parts []string
+	if r.VersionStartIncluding != "" {
+		parts = append(parts, "from "+r.VersionStartIncluding)
+	}
+	if r.VersionStartExcluding != "" {
+		parts = append(parts, "after "+r.VersionStartExcluding)
+	}
+	if r.VersionEndIncluding != "" {
+		parts = append(parts, "up to "+r.VersionEndIncluding)
+	}
+	if r.VersionEndExcluding != "" {
+		parts = append(parts, "before "+r.VersionEndExcluding)
+	}
+	return "versions " + strings.Join(parts, " ")
+}
+
+// preReleaseTags sort before the release they lead up to: 2.0rc1 < 2.0
+var preReleaseTags = map[string]bool{"alpha": true, "a": true, "beta": true, "b": true, "rc": true, "pre": true, "dev": true}
+
+// compareVersions compares dotted versions with letters in them, like 8.2p1 or
+// 2.4.41, number by number and word by word. A version with more parts is newer
+// unless the extra part is a pre-release tag.
+func compareVersions(a, b string) int {
+	ta, tb := versionTokens(a), versionTokens(b)
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		if i >= len(ta) {
+			return -tailSign(tb[i])
+		}
+		if i >= len(tb) {
+			return tailSign(ta[i])
+		}
+		if c := compareVersionToken(ta[i], tb[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// tailSign is the sign of a version that continues with tok where the other ended
+func tailSign(tok string) int {
+	if preReleaseTags[tok] {
+		return -1
+	}
+	return 1
+}
+
+func compareVersionToken(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(na, nb)
+	case errA == nil:
+		// 1.0.1 is newer than 1.0rc1
+		return 1
+	case errB == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// versionTokens splits a version into runs of digits and of letters
+func versionTokens(v string) []string {
+	var tokens []string
+	var tok strings.Builder
+	digits := false
+	for _, r := range strings.ToLower(v) {
+		isDigit, isLetter := unicode.IsDigit(r), unicode.IsLetter(r)
+		if (!isDigit && !isLetter) || (tok.Len() > 0 && isDigit != digits) {
+			if tok.Len() > 0 {
+				tokens = append(tokens, tok.String())
+				tok.Reset()
+			}
+		}
+		if isDigit || isLetter {
+			tok.WriteRune(r)
+			digits = isDigit
+		}
+	}
+	if tok.Len() > 0 {
+		tokens = append(tokens, tok.String())
+	}
+	return tokens
+}
+
+// distroMarkers in a version banner point to distribution packages, which often
+// carry backported fixes the upstream version number does not show
+var distroMarkers = []string{"ubuntu", "debian", "el6", "el7", "el8", "el9", "rhel", "centos", "fedora", "suse", "alpine", "freebsd"}
+
+// VersionFindings reports the CVEs whose NVD CPE match data covers the product
+// versions nmap identified in a job. Nothing is reported for other tools.
+func (s *EnrichmentService) VersionFindings(job *models.Job) []models.Finding {
+	if job.Tool != models.ToolNmap || job.Status != models.JobStatusCompleted {
+		return nil
+	}
+	findings := s.matchVersions(job.Result)
+	for i := range findings {
+		findings[i].JobID = job.ID
+	}
+	return findings
+}
+
+// matchVersions turns the identified services of an nmap result into one
+// "potentially vulnerable version" finding per matching CVE
+func (s *EnrichmentService) matchVersions(result interface{}) []models.Finding {
+	if result == nil {
+		return nil
+	}
+	res, err := decodeNmapResult(result)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var findings []models.Finding
+	for _, scan := range []struct {
+		key string
+		run *models.NmapRun
+	}{{"tcp", res.TCP}, {"udp", res.UDP}} {
+		if scan.run == nil {
+			continue
+		}
+		for h, host := range scan.run.Hosts {
+			for p, port := range host.Ports.Ports {
+				if port.State.State != "open" {
+					continue
+				}
+				ref := fmt.Sprintf("%s.hosts[%d].ports.ports[%d]", scan.key, h, p)
+				for _, f := range s.matchService(port.Service) {
+					f.Host, f.Port, f.RawRef = hostTarget(host), port.PortID, ref
+					f.Tool = models.ToolNmap
+					f.Fingerprint = fingerprint(f)
+					findings = append(findings, f)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// matchService matches the application CPEs of a service against the NVD rules.
+// Callers hold s.mu.
+func (s *EnrichmentService) matchService(svc models.Service) []models.Finding {
+	bannerVersion := strings.Fields(svc.Version)
+	banner := strings.ToLower(svc.Version + " " + svc.ExtraInfo)
+
+	// A version read from a banner is as good as OpenVAS' remote_banner detection;
+	// distribution packages drop it to package_unreliable
+	qod := 80
+	for _, marker := range distroMarkers {
+		if strings.Contains(banner, marker) {
+			qod = 30
+			break
+		}
+	}
+
+	var findings []models.Finding
+	seen := make(map[string]bool)
+	for _, raw := range svc.CPEs {
+		c, ok := parseCPE(raw)
+		if !ok || c.part != "a" {
+			continue
+		}
+		version := c.version
+		if version == "" && len(bannerVersion) > 0 {
+			version = strings.ToLower(bannerVersion[0])
+		}
+		if version == "" {
+			continue
+		}
+		if c.update != "" {
+			version += c.update
+		}
+
+		for _, rule := range s.cpeIndex[c.key()] {
+			if seen[rule.CVE] || !rule.matches(version) {
+				continue
+			}
+			seen[rule.CVE] = true
+			findings = append(findings, s.versionFinding(svc, raw, version, rule, qod))
+		}
+	}
+	return findings
+}
+
+func (s *EnrichmentService) versionFinding(svc models.Service, raw, version string, rule cpeRule, qod int) models.Finding {
+	detail := s.nvd[rule.CVE].detail
+	product := svc.Product
+	if product == "" {
+		product = svc.Name
+	}
+
+	f := models.Finding{
+		RuleID:      "vulnerable-version",
+		Title:       fmt.Sprintf("%s %s may be vulnerable to %s", product, version, rule.CVE),
+		Description: detail.Description,
+		Severity:    models.SeverityMedium,
+		CVSS:        detail.CVSS,
+		CVSSVector:  detail.CVSSVector,
+		QoD:         qod,
+		CVEs:        []string{rule.CVE},
+		CWEs:        detail.CWEs,
+		Evidence:    fmt.Sprintf("%s matches %s (%s)", raw, rule.Criteria, rule.describe()),
+	}
+	if detail.CVSS > 0 {
+		f.Severity = cvssSeverity(detail.CVSS)
+	}
+	return f
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.4.41", "2.4.41", 0},
+		{"2.4.9", "2.4.41", -1},
+		{"8.2p1", "8.2", 1},
+		{"8.2p1", "9.3p2", -1},
+		{"9.3p2", "9.3p1", 1},
+		{"2.0rc1", "2.0", -1},
+		{"1.0.1", "1.0rc1", 1},
+		{"1.1.1k", "1.1.1t", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, compareVersions(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestVersionFindingsFromNmapCPEs(t *testing.T) {
+	s := NewEnrichmentService(t.TempDir())
+	nvd := `{"vulnerabilities":[
+		{"cve":{"id":"CVE-2023-38408","metrics":{"cvssMetricV31":[{"type":"Primary","cvssData":{"baseScore":9.8}}]},
+			"configurations":[{"nodes":[{"cpeMatch":[
+				{"vulnerable":true,"criteria":"cpe:2.3:a:openbsd:openssh:*:*:*:*:*:*:*:*","versionEndExcluding":"9.3p2"},
+				{"vulnerable":false,"criteria":"cpe:2.3:o:linux:linux_kernel:-:*:*:*:*:*:*:*"}]}]}]}},
+		{"cve":{"id":"CVE-2021-41773","metrics":{"cvssMetricV31":[{"type":"Primary","cvssData":{"baseScore":7.5}}]},
+			"configurations":[{"nodes":[{"cpeMatch":[
+				{"vulnerable":true,"criteria":"cpe:2.3:a:apache:http_server:2.4.49:*:*:*:*:*:*:*"}]}]}]}},
+		{"cve":{"id":"CVE-2099-0001","configurations":[{"nodes":[{"cpeMatch":[
+				{"vulnerable":true,"criteria":"cpe:2.3:a:apache:http_server:*:*:*:*:*:*:*:*"}]}]}]}}]}`
+	_, err := s.Update(FeedNVD, "nvdcve-2.0-recent.json", strings.NewReader(nvd))
+	require.NoError(t, err)
+
+	job := &models.Job{ID: "job-1", Tool: models.ToolNmap, Status: models.JobStatusCompleted, Result: CombinedScanResponse{
+		TCP: &models.NmapRun{Hosts: []models.Host{{
+			Addresses: []models.Address{{Addr: "10.0.0.5", AddrType: "ipv4"}},
+			Ports: models.Ports{Ports: []models.Port{
+				{PortID: "22", Proto: "tcp", State: models.State{State: "open"}, Service: models.Service{
+					Name: "ssh", Product: "OpenSSH", Version: "8.2p1 Ubuntu 4ubuntu0.5", CPEs: []string{"cpe:/a:openbsd:openssh:8.2p1", "cpe:/o:linux:linux_kernel"}}},
+				{PortID: "80", Proto: "tcp", State: models.State{State: "open"}, Service: models.Service{
+					Name: "http", Product: "Apache httpd", Version: "2.4.49", CPEs: []string{"cpe:/a:apache:http_server"}}},
+				{PortID: "443", Proto: "tcp", State: models.State{State: "closed"}, Service: models.Service{
+					Name: "https", CPEs: []string{"cpe:/a:apache:http_server:2.4.49"}}},
+			}},
+		}}},
+	}}
+
+	findings := s.VersionFindings(job)
+	require.Len(t, findings, 2)
+
+	ssh := findings[0]
+	assert.Equal(t, "OpenSSH 8.2p1 may be vulnerable to CVE-2023-38408", ssh.Title)
+	assert.Equal(t, models.SeverityCritical, ssh.Severity)
+	assert.Equal(t, "22", ssh.Port)
+	assert.Equal(t, "job-1", ssh.JobID)
+	// Ubuntu backports fixes into its OpenSSH packages
+	assert.Equal(t, 30, ssh.QoD)
+	assert.Contains(t, ssh.Evidence, "versions before 9.3p2")
+
+	// The version comes from the banner when the CPE has none
+	httpd := findings[1]
+	assert.Equal(t, []string{"CVE-2021-41773"}, httpd.CVEs)
+	assert.Equal(t, 80, httpd.QoD)
+	assert.NotEmpty(t, httpd.Fingerprint)
+
+	assert.Nil(t, s.VersionFindings(&models.Job{Tool: models.ToolNuclei, Status: models.JobStatusCompleted}))
+}
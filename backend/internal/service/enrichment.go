@@ -0,0 +1,553 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"napscan-be/internal/models"
+)
+
+const (
+	FeedNVD  = "nvd"
+	FeedKEV  = "kev"
+	FeedEPSS = "epss"
+)
+
+var (
+	ErrUnknownFeed = errors.New("unknown feed")
+	ErrInvalidFeed = errors.New("invalid feed file")
+)
+
+// EnrichmentDirFromEnv returns NAPSCAN_VULNDB_DIR or vulndb next to the database
+func EnrichmentDirFromEnv() string {
+	if v := strings.TrimSpace(os.Getenv("NAPSCAN_VULNDB_DIR")); v != "" {
+		return v
+	}
+	if db := strings.TrimSpace(os.Getenv("NAPSCAN_DB_PATH")); db != "" {
+		return filepath.Join(filepath.Dir(db), "vulndb")
+	}
+	return "vulndb"
+}
+
+// EnrichmentService adds what the NVD, the CISA KEV catalog and EPSS know about a
+// CVE to the findings that carry it. The feeds are read from files in a local
+// directory, so scanning works offline:
+//
+//	nvd/*.json[.gz]  NVD CVE API 2.0 feed files, e.g. nvdcve-2.0-2024.json.gz
+//	kev.json         known_exploited_vulnerabilities.json
+//	epss.csv         epss_scores-YYYY-MM-DD.csv, optionally gzipped
+type EnrichmentService struct {
+	dir string
+
+	mu  sync.RWMutex
+	nvd map[string]nvdRecord
+	// cpeIndex holds the vulnerable CPE matches of the NVD feed per part:vendor:product
+	cpeIndex map[string][]cpeRule
+	kev      map[string]time.Time
+	epss     map[string]epssScore
+	status   map[string]models.FeedStatus
+}
+
+type nvdRecord struct {
+	detail       models.CVEDetail
+	rules        []cpeRule
+	lastModified time.Time
+}
+
+type epssScore struct {
+	score, percentile float64
+}
+
+func NewEnrichmentService(dir string) *EnrichmentService {
+	return &EnrichmentService{
+		dir:      dir,
+		nvd:      map[string]nvdRecord{},
+		cpeIndex: map[string][]cpeRule{},
+		kev:      map[string]time.Time{},
+		epss:     map[string]epssScore{},
+		status:   map[string]models.FeedStatus{},
+	}
+}
+
+// Load reads every feed found in the data directory. Missing feeds are not an error,
+// findings are just not enriched from them.
+func (s *EnrichmentService) Load() error {
+	var errs []error
+	for _, feed := range []string{FeedNVD, FeedKEV, FeedEPSS} {
+		if err := s.reload(feed); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Update replaces a feed file with the uploaded one and reloads the feed. NVD files
+// are stored under their own name, so yearly feeds can be uploaded one by one. A
+// file that does not parse leaves the stored feed untouched.
+func (s *EnrichmentService) Update(feed, filename string, r io.Reader) (*models.FeedStatus, error) {
+	dest, err := s.feedPath(feed, filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := validateFeedFile(feed, tmp.Name()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeed, err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return nil, err
+	}
+	if err := s.reload(feed); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := s.status[feed]
+	return &status, nil
+}
+
+// Status describes the loaded feeds
+func (s *EnrichmentService) Status() []models.FeedStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	feeds := make([]models.FeedStatus, 0, 3)
+	for _, feed := range []string{FeedNVD, FeedKEV, FeedEPSS} {
+		status, ok := s.status[feed]
+		if !ok {
+			status = models.FeedStatus{Feed: feed, Files: []string{}}
+		}
+		feeds = append(feeds, status)
+	}
+	return feeds
+}
+
+// Lookup returns what the feeds know about a CVE
+func (s *EnrichmentService) Lookup(cve string) (models.CVEDetail, bool) {
+	cve = strings.ToUpper(strings.TrimSpace(cve))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, found := s.nvd[cve]
+	detail := rec.detail
+	detail.ID = cve
+	if added, ok := s.kev[cve]; ok {
+		found = true
+		detail.KEV = true
+		if !added.IsZero() {
+			detail.KEVDateAdded = &added
+		}
+	}
+	if score, ok := s.epss[cve]; ok {
+		found = true
+		detail.EPSS, detail.EPSSPercentile = score.score, score.percentile
+	}
+	return detail, found
+}
+
+// Enrich attaches the known CVE details to the findings in place. CVSS, vector and
+// description fill in what the tool left empty; NVD CWEs are added, and a CVE in
+// the KEV catalog marks the finding as exploited.
+func (s *EnrichmentService) Enrich(findings []models.Finding) {
+	for i := range findings {
+		f := &findings[i]
+		for _, cve := range f.CVEs {
+			detail, ok := s.Lookup(cve)
+			if !ok {
+				continue
+			}
+			f.CVEDetails = append(f.CVEDetails, detail)
+			f.CWEs = mergeStrings(f.CWEs, detail.CWEs)
+			f.KnownExploit = f.KnownExploit || detail.KEV
+			if f.Description == "" {
+				f.Description = detail.Description
+			}
+			if f.CVSS == 0 && detail.CVSS > 0 {
+				f.CVSS, f.CVSSVector = detail.CVSS, detail.CVSSVector
+			}
+			if f.CVSSVector == "" && f.CVSS == detail.CVSS {
+				f.CVSSVector = detail.CVSSVector
+			}
+		}
+	}
+}
+
+func (s *EnrichmentService) feedPath(feed, filename string) (string, error) {
+	switch feed {
+	case FeedNVD:
+		name := filepath.Base(strings.TrimSpace(filename))
+		if !strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".json.gz") {
+			return "", fmt.Errorf("%w: NVD files must be .json or .json.gz", ErrInvalidFeed)
+		}
+		return filepath.Join(s.dir, FeedNVD, name), nil
+	case FeedKEV:
+		return filepath.Join(s.dir, "kev.json"), nil
+	case FeedEPSS:
+		return filepath.Join(s.dir, "epss.csv"), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFeed, feed)
+}
+
+// reload parses the files of a feed and swaps them in
+func (s *EnrichmentService) reload(feed string) error {
+	status := models.FeedStatus{Feed: feed, Files: []string{}}
+	var err error
+
+	switch feed {
+	case FeedNVD:
+		var files []string
+		files, err = filepath.Glob(filepath.Join(s.dir, FeedNVD, "*.json*"))
+		sort.Strings(files)
+		records := map[string]nvdRecord{}
+		for _, file := range files {
+			if err = readNVDFeed(file, records); err != nil {
+				break
+			}
+			status.Files = append(status.Files, filepath.Base(file))
+		}
+		if err == nil {
+			status.Entries = len(records)
+			index := buildCPEIndex(records)
+			s.swap(feed, status, func() { s.nvd, s.cpeIndex = records, index })
+		}
+	case FeedKEV:
+		path := filepath.Join(s.dir, "kev.json")
+		var kev map[string]time.Time
+		if kev, status.Version, err = readKEVFeed(path); err == nil {
+			status.Files, status.Entries = []string{filepath.Base(path)}, len(kev)
+			s.swap(feed, status, func() { s.kev = kev })
+		}
+	case FeedEPSS:
+		path := filepath.Join(s.dir, "epss.csv")
+		var epss map[string]epssScore
+		if epss, status.Version, err = readEPSSFeed(path); err == nil {
+			status.Files, status.Entries = []string{filepath.Base(path)}, len(epss)
+			s.swap(feed, status, func() { s.epss = epss })
+		}
+	}
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load %s feed: %w", feed, err)
+	}
+	log.Printf("Loaded %s feed: %d entries", feed, status.Entries)
+	return nil
+}
+
+func (s *EnrichmentService) swap(feed string, status models.FeedStatus, set func()) {
+	now := time.Now()
+	status.LoadedAt = &now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set()
+	s.status[feed] = status
+}
+
+func validateFeedFile(feed, path string) error {
+	var err error
+	switch feed {
+	case FeedNVD:
+		records := map[string]nvdRecord{}
+		if err = readNVDFeed(path, records); err == nil && len(records) == 0 {
+			err = errors.New("no CVEs in file")
+		}
+	case FeedKEV:
+		var kev map[string]time.Time
+		if kev, _, err = readKEVFeed(path); err == nil && len(kev) == 0 {
+			err = errors.New("no vulnerabilities in catalog")
+		}
+	case FeedEPSS:
+		var epss map[string]epssScore
+		if epss, _, err = readEPSSFeed(path); err == nil && len(epss) == 0 {
+			err = errors.New("no scores in file")
+		}
+	}
+	return err
+}
+
+// openFeedFile opens a feed file, gunzipping it when it is compressed whatever its name
+func openFeedFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(2)
+	if !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return struct {
+			io.Reader
+			io.Closer
+		}{br, f}, nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+// nvdFeed is the part of an NVD CVE API 2.0 response the enrichment reads
+type nvdFeed struct {
+	Vulnerabilities []struct {
+		CVE nvdCVE `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type nvdCVE struct {
+	ID           string `json:"id"`
+	Published    string `json:"published"`
+	LastModified string `json:"lastModified"`
+	Descriptions []struct {
+		Lang  string `json:"lang"`
+		Value string `json:"value"`
+	} `json:"descriptions"`
+	Metrics    map[string][]nvdMetric `json:"metrics"`
+	Weaknesses []struct {
+		Description []struct {
+			Value string `json:"value"`
+		} `json:"description"`
+	} `json:"weaknesses"`
+	Configurations []struct {
+		Nodes []struct {
+			CPEMatch []struct {
+				Vulnerable            bool   `json:"vulnerable"`
+				Criteria              string `json:"criteria"`
+				VersionStartIncluding string `json:"versionStartIncluding"`
+				VersionStartExcluding string `json:"versionStartExcluding"`
+				VersionEndIncluding   string `json:"versionEndIncluding"`
+				VersionEndExcluding   string `json:"versionEndExcluding"`
+			} `json:"cpeMatch"`
+		} `json:"nodes"`
+	} `json:"configurations"`
+}
+
+type nvdMetric struct {
+	Type     string `json:"type"`
+	CVSSData struct {
+		VectorString string  `json:"vectorString"`
+		BaseScore    float64 `json:"baseScore"`
+	} `json:"cvssData"`
+}
+
+// nvdMetricOrder is the CVSS version preferred for the score of a CVE
+var nvdMetricOrder = []string{"cvssMetricV31", "cvssMetricV30", "cvssMetricV40", "cvssMetricV2"}
+
+// readNVDFeed adds the CVEs of an NVD feed file to records. When a CVE is in several
+// files the last modified entry wins.
+func readNVDFeed(path string, records map[string]nvdRecord) error {
+	r, err := openFeedFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var feed nvdFeed
+	if err := json.NewDecoder(r).Decode(&feed); err != nil {
+		return err
+	}
+
+	for _, v := range feed.Vulnerabilities {
+		cve := v.CVE
+		id := strings.ToUpper(strings.TrimSpace(cve.ID))
+		if id == "" {
+			continue
+		}
+		rec := nvdRecord{detail: models.CVEDetail{ID: id}, lastModified: parseFeedTime(cve.LastModified)}
+		if old, ok := records[id]; ok && old.lastModified.After(rec.lastModified) {
+			continue
+		}
+
+		for _, d := range cve.Descriptions {
+			if d.Lang == "en" {
+				rec.detail.Description = strings.TrimSpace(d.Value)
+				break
+			}
+		}
+		if published := parseFeedTime(cve.Published); !published.IsZero() {
+			rec.detail.Published = &published
+		}
+		for _, version := range nvdMetricOrder {
+			if m, ok := primaryMetric(cve.Metrics[version]); ok {
+				rec.detail.CVSS, rec.detail.CVSSVector = m.CVSSData.BaseScore, m.CVSSData.VectorString
+				break
+			}
+		}
+		for _, w := range cve.Weaknesses {
+			for _, d := range w.Description {
+				// NVD writes NVD-CWE-Other and NVD-CWE-noinfo when there is no CWE
+				if strings.HasPrefix(d.Value, "CWE-") && !containsString(rec.detail.CWEs, d.Value) {
+					rec.detail.CWEs = append(rec.detail.CWEs, d.Value)
+				}
+			}
+		}
+		// Platform conditions (runs on X) are ignored; only the vulnerable CPEs are kept
+		for _, conf := range cve.Configurations {
+			for _, node := range conf.Nodes {
+				for _, m := range node.CPEMatch {
+					if !m.Vulnerable {
+						continue
+					}
+					rec.rules = append(rec.rules, cpeRule{
+						CVE:                   id,
+						Criteria:              m.Criteria,
+						VersionStartIncluding: m.VersionStartIncluding,
+						VersionStartExcluding: m.VersionStartExcluding,
+						VersionEndIncluding:   m.VersionEndIncluding,
+						VersionEndExcluding:   m.VersionEndExcluding,
+					})
+				}
+			}
+		}
+		records[id] = rec
+	}
+	return nil
+}
+
+// buildCPEIndex groups the CPE rules of all CVEs by the product they name
+func buildCPEIndex(records map[string]nvdRecord) map[string][]cpeRule {
+	index := make(map[string][]cpeRule)
+	for _, rec := range records {
+		for _, rule := range rec.rules {
+			c, ok := parseCPE(rule.Criteria)
+			if !ok {
+				continue
+			}
+			// 8.2 with update p1 is the 8.2p1 banners show
+			rule.Version = c.version
+			if c.update != "" && c.update != "*" && c.update != "-" && rule.Version != "*" {
+				rule.Version += c.update
+			}
+			index[c.key()] = append(index[c.key()], rule)
+		}
+	}
+	return index
+}
+
+// primaryMetric prefers the score of the NVD itself over those of other sources
+func primaryMetric(metrics []nvdMetric) (nvdMetric, bool) {
+	for _, m := range metrics {
+		if m.Type == "Primary" {
+			return m, true
+		}
+	}
+	if len(metrics) > 0 {
+		return metrics[0], true
+	}
+	return nvdMetric{}, false
+}
+
+// readKEVFeed reads the CISA KEV catalog in its JSON format
+func readKEVFeed(path string) (map[string]time.Time, string, error) {
+	r, err := openFeedFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+
+	var catalog struct {
+		CatalogVersion  string `json:"catalogVersion"`
+		Vulnerabilities []struct {
+			CVEID     string `json:"cveID"`
+			DateAdded string `json:"dateAdded"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, "", err
+	}
+
+	kev := make(map[string]time.Time, len(catalog.Vulnerabilities))
+	for _, v := range catalog.Vulnerabilities {
+		if id := strings.ToUpper(strings.TrimSpace(v.CVEID)); id != "" {
+			kev[id] = parseFeedTime(v.DateAdded)
+		}
+	}
+	return kev, catalog.CatalogVersion, nil
+}
+
+// readEPSSFeed reads the daily EPSS CSV: a "#model_version:...,score_date:..." line,
+// a "cve,epss,percentile" header and one line per CVE
+func readEPSSFeed(path string) (map[string]epssScore, string, error) {
+	r, err := openFeedFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+
+	scores := make(map[string]epssScore)
+	var scoreDate string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") {
+			for _, field := range strings.Split(strings.TrimPrefix(text, "#"), ",") {
+				if key, value, ok := strings.Cut(field, ":"); ok && key == "score_date" {
+					scoreDate = value
+				}
+			}
+			continue
+		}
+		if text == "" || strings.HasPrefix(text, "cve,") {
+			continue
+		}
+
+		fields := strings.Split(text, ",")
+		if len(fields) < 3 {
+			return nil, "", fmt.Errorf("line %d: expected cve,epss,percentile", line)
+		}
+		score, err1 := strconv.ParseFloat(fields[1], 64)
+		percentile, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil {
+			return nil, "", fmt.Errorf("line %d: invalid score", line)
+		}
+		scores[strings.ToUpper(fields[0])] = epssScore{score: score, percentile: percentile}
+	}
+	return scores, scoreDate, scanner.Err()
+}
+
+// parseFeedTime reads the timestamps of the feeds; NVD leaves out the time zone
+// and KEV the time
+func parseFeedTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999", "2006-01-02"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
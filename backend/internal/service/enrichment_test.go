@@ -0,0 +1,75 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichmentFromLocalFeeds(t *testing.T) {
+	dir := t.TempDir()
+	s := NewEnrichmentService(dir)
+
+	nvd := `{"format":"NVD_CVE","version":"2.0","vulnerabilities":[{"cve":{
+		"id":"CVE-2021-44228","published":"2021-12-10T10:15:09.143","lastModified":"2024-04-03T17:15:09.900",
+		"descriptions":[{"lang":"es","value":"..."},{"lang":"en","value":"Apache Log4j2 JNDI features do not protect against attacker controlled LDAP."}],
+		"metrics":{
+			"cvssMetricV2":[{"type":"Primary","cvssData":{"vectorString":"AV:N/AC:M/Au:N/C:C/I:C/A:C","baseScore":9.3}}],
+			"cvssMetricV31":[{"type":"Secondary","cvssData":{"vectorString":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H","baseScore":10.0}}]},
+		"weaknesses":[{"description":[{"value":"CWE-917"}]},{"description":[{"value":"NVD-CWE-Other"}]}]}}]}`
+	status, err := s.Update(FeedNVD, "nvdcve-2.0-2021.json", strings.NewReader(nvd))
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Entries)
+
+	kev := `{"catalogVersion":"2024.06.01","vulnerabilities":[{"cveID":"CVE-2021-44228","dateAdded":"2021-12-10"}]}`
+	_, err = s.Update(FeedKEV, "known_exploited_vulnerabilities.json", strings.NewReader(kev))
+	require.NoError(t, err)
+
+	// EPSS scores are published gzipped
+	var epss bytes.Buffer
+	gz := gzip.NewWriter(&epss)
+	gz.Write([]byte("#model_version:v2023.03.01,score_date:2024-06-01T00:00:00+0000\ncve,epss,percentile\nCVE-2021-44228,0.97565,0.99996\n"))
+	gz.Close()
+	status, err = s.Update(FeedEPSS, "epss_scores-2024-06-01.csv.gz", &epss)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-06-01T00:00:00+0000", status.Version)
+
+	// A broken upload keeps the feed that was there
+	_, err = s.Update(FeedKEV, "kev.json", strings.NewReader(`{"vulnerabilities":[]}`))
+	assert.ErrorIs(t, err, ErrInvalidFeed)
+	_, err = s.Update("osv", "osv.json", strings.NewReader(kev))
+	assert.ErrorIs(t, err, ErrUnknownFeed)
+
+	// The feeds are read back from disk on start
+	s = NewEnrichmentService(dir)
+	require.NoError(t, s.Load())
+
+	findings := []models.Finding{
+		{Severity: models.SeverityCritical, CVEs: []string{"CVE-2021-44228"}, CWEs: []string{"CWE-502"}},
+		{Severity: models.SeverityHigh, CVEs: []string{"CVE-2099-0001"}},
+	}
+	s.Enrich(findings)
+
+	f := findings[0]
+	require.Len(t, f.CVEDetails, 1)
+	detail := f.CVEDetails[0]
+	assert.Equal(t, 10.0, detail.CVSS)
+	assert.Equal(t, "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", detail.CVSSVector)
+	assert.Equal(t, []string{"CWE-917"}, detail.CWEs)
+	assert.Equal(t, time.Date(2021, 12, 10, 10, 15, 9, 143000000, time.UTC), *detail.Published)
+	assert.True(t, detail.KEV)
+	assert.InDelta(t, 0.97565, detail.EPSS, 1e-9)
+
+	assert.True(t, f.KnownExploit)
+	assert.Equal(t, 10.0, f.CVSS)
+	assert.Equal(t, []string{"CWE-502", "CWE-917"}, f.CWEs)
+	assert.Equal(t, "Apache Log4j2 JNDI features do not protect against attacker controlled LDAP.", f.Description)
+	assert.Empty(t, findings[1].CVEDetails)
+}
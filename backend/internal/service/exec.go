@@ -0,0 +1,43 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// newToolCommand builds the command for an external scanner. Cancelling ctx
+// kills the tool together with all of its child processes.
+func newToolCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	// Don't wait forever for pipes held open by orphaned grandchildren
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
+
+// lineWriter calls fn for every complete line written to it. It is used to follow
+// the progress output of a tool while it runs.
+type lineWriter struct {
+	fn  func(line string)
+	buf []byte
+}
+
+func newLineWriter(fn func(line string)) *lineWriter {
+	return &lineWriter{fn: fn}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(strings.TrimRight(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
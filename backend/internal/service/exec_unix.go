@@ -0,0 +1,17 @@
+//go:build !windows
+
+package service
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the tool in its own process group and makes context
+// cancellation kill the whole group, including helpers the tool spawned itself
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
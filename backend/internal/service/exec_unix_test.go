@@ -0,0 +1,61 @@
+//go:build !windows
+
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processGone reports whether a process has exited. A killed orphan can stay a
+// zombie for a while when nothing reaps it, which counts as gone.
+func processGone(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return true
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	return err == nil && strings.Contains(string(stat), ") Z ")
+}
+
+func TestCancelKillsTheToolsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "sleep.pid")
+	s := newTestJobService(t, DefaultPoolConfig())
+	s.Register(models.ToolNmap, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		// The shell stands in for a tool that spawns helpers of its own
+		out, err := newToolCommand(ctx, "sh", "-c", `sleep 60 & echo $! > "$1"; wait`, "sh", pidFile).CombinedOutput()
+		return string(out), err
+	})
+
+	job, err := s.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.1"})
+	require.NoError(t, err)
+	waitForStatus(t, s, job.ID, models.JobStatusRunning)
+
+	var pid int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	require.False(t, processGone(pid))
+
+	_, err = s.Cancel(job.ID)
+	require.NoError(t, err)
+	waitForStatus(t, s, job.ID, models.JobStatusCancelled)
+	assert.Eventually(t, func() bool { return processGone(pid) }, 2*time.Second, 10*time.Millisecond,
+		"the helper the tool started outlived the job")
+}
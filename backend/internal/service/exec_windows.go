@@ -0,0 +1,8 @@
+//go:build windows
+
+package service
+
+import "os/exec"
+
+// setProcessGroup is a no-op on Windows, cancellation kills the tool process only
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -5,10 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
+
+	"napscan-be/internal/models"
 )
 
 type FfufService struct{}
@@ -17,14 +17,19 @@ func NewFfufService() *FfufService {
 	return &FfufService{}
 }
 
-func (s *FfufService) ExecuteScan(ctx context.Context, target string) (interface{}, error) {
-	// Ensure URL has protocol
-	if !strings.HasPrefix(target, "http") {
-		target = "https://" + target
+func (s *FfufService) ExecuteScan(ctx context.Context, target *Target) (interface{}, error) {
+	baseURL, err := target.URL("https")
+	if err != nil {
+		return nil, err
 	}
 
-	// Create temporary file for JSON output
-	tmpFile := filepath.Join(os.TempDir(), "ffuf_"+time.Now().Format("20060102150405")+".json")
+	// Create temporary file for JSON output, one per job since several ffuf jobs run at once
+	f, err := os.CreateTemp("", "ffuf_*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ffuf output file: %w", err)
+	}
+	tmpFile := f.Name()
+	f.Close()
 	defer os.Remove(tmpFile)
 
 	// Update wordlist path to new location
@@ -34,9 +39,9 @@ func (s *FfufService) ExecuteScan(ctx context.Context, target string) (interface
 		wordlistPath = "../internal/models/wordlist.txt"
 	}
 
-	cmd := exec.CommandContext(ctx,
+	cmd := newToolCommand(ctx,
 		"ffuf",
-		"-u", target+"/FUZZ",
+		"-u", strings.TrimSuffix(baseURL, "/")+"/FUZZ",
 		"-w", wordlistPath,
 		"-fc", "404,307",
 		"-of", "json",
@@ -53,7 +58,12 @@ func (s *FfufService) ExecuteScan(ctx context.Context, target string) (interface
 	if err != nil {
 		return nil, fmt.Errorf("failed to read ffuf output: %w", err)
 	}
+	recordOutput(ctx, "ffuf.json", "application/json", jsonData)
+	return parseFfufJSON(jsonData)
+}
 
+// parseFfufJSON reads the report ffuf writes with -of json
+func parseFfufJSON(jsonData []byte) (interface{}, error) {
 	if len(jsonData) < 10 {
 		return nil, fmt.Errorf("ffuf returned empty/invalid output")
 	}
@@ -65,3 +75,53 @@ func (s *FfufService) ExecuteScan(ctx context.Context, target string) (interface
 
 	return result, nil
 }
+
+// RunJob runs ffuf as a background job
+func (s *FfufService) RunJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+
+	target, err := ParseTarget(run.Target())
+	if err != nil {
+		return nil, err
+	}
+	return s.ExecuteScan(ctx, target)
+}
+
+// ffufHit is a result of ffuf's JSON output
+type ffufHit struct {
+	Input            map[string]string `json:"input"`
+	Status           int               `json:"status"`
+	Length           int               `json:"length"`
+	URL              string            `json:"url"`
+	RedirectLocation string            `json:"redirectlocation"`
+}
+
+// normalizeFfufResult turns the hits of an ffuf job into findings. Discovered paths
+// are leads for a human to look at, so they are all reported as info.
+func normalizeFfufResult(result interface{}) ([]models.Finding, error) {
+	var res struct {
+		Results []ffufHit `json:"results"`
+	}
+	if err := decodeJobResult(result, &res); err != nil {
+		return nil, err
+	}
+
+	findings := make([]models.Finding, 0, len(res.Results))
+	for i, hit := range res.Results {
+		f := models.Finding{
+			RuleID:   "content-discovery",
+			Title:    fmt.Sprintf("Discovered path /%s (HTTP %d)", hit.Input["FUZZ"], hit.Status),
+			Severity: models.SeverityInfo,
+			URL:      hit.URL,
+			Evidence: fmt.Sprintf("HTTP %d, %d bytes", hit.Status, hit.Length),
+			RawRef:   fmt.Sprintf("results[%d]", i),
+		}
+		if hit.RedirectLocation != "" {
+			f.Evidence += ", redirects to " + hit.RedirectLocation
+		}
+		f.Host, f.Port = urlHostPort(hit.URL)
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"napscan-be/internal/models"
+)
+
+// findingNormalizer turns the result of a job into findings. Each tool service
+// provides its own next to the code that produces the result.
+type findingNormalizer func(result interface{}) ([]models.Finding, error)
+
+var findingNormalizers = map[string]findingNormalizer{
+	models.ToolNmap:    normalizeNmapResult,
+	models.ToolNuclei:  normalizeNucleiResult,
+	models.ToolZap:     normalizeZapResult,
+	models.ToolFfuf:    normalizeFfufResult,
+	models.ToolSslyze:  normalizeSslyzeResult,
+	models.ToolOpenVAS: normalizeOpenVASResult,
+}
+
+// NormalizeFindings returns the findings in the result of a completed job
+func NormalizeFindings(job *models.Job) ([]models.Finding, error) {
+	findings, err := normalizeResult(job.Tool, job.Result)
+	if err != nil {
+		return nil, err
+	}
+	for i := range findings {
+		findings[i].JobID = job.ID
+	}
+	return findings, nil
+}
+
+// normalizeResult returns the findings in a result of tool
+func normalizeResult(tool string, result interface{}) ([]models.Finding, error) {
+	normalize, ok := findingNormalizers[tool]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, tool)
+	}
+	if result == nil {
+		return []models.Finding{}, nil
+	}
+	findings, err := normalize(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s result: %w", tool, err)
+	}
+	for i := range findings {
+		findings[i].Tool = tool
+		findings[i].Fingerprint = fingerprint(findings[i])
+	}
+	return findings, nil
+}
+
+// sortFindings orders findings from the most to the least severe, keeping the
+// order of the tools within a severity
+func sortFindings(findings []models.Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank(findings[i].Severity) < severityRank(findings[j].Severity)
+	})
+}
+
+func severityRank(sev models.Severity) int {
+	for i, s := range models.Severities {
+		if s == sev {
+			return i
+		}
+	}
+	return len(models.Severities)
+}
+
+// decodeJobResult reads the result of a job into v, whether it is still the Go value
+// returned by the runner or JSON loaded from the repository
+func decodeJobResult(result interface{}, v interface{}) error {
+	raw, ok := result.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(result); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// parseSeverity maps the severity or risk names of the tools onto the normalized ones
+func parseSeverity(s string) models.Severity {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.HasPrefix(s, "crit"):
+		return models.SeverityCritical
+	case strings.HasPrefix(s, "high"):
+		return models.SeverityHigh
+	case strings.HasPrefix(s, "med"):
+		return models.SeverityMedium
+	case strings.HasPrefix(s, "low"):
+		return models.SeverityLow
+	}
+	return models.SeverityInfo
+}
+
+// cvssSeverity maps a CVSS v3 base score onto a severity
+func cvssSeverity(score float64) models.Severity {
+	switch {
+	case score >= 9:
+		return models.SeverityCritical
+	case score >= 7:
+		return models.SeverityHigh
+	case score >= 4:
+		return models.SeverityMedium
+	case score > 0:
+		return models.SeverityLow
+	}
+	return models.SeverityInfo
+}
+
+// normalizeCWE formats CWE IDs as "CWE-79". Tools write "79", "cwe-79" or "-1" for none.
+func normalizeCWE(id string) string {
+	id = strings.TrimSpace(strings.ToUpper(id))
+	id = strings.TrimPrefix(id, "CWE-")
+	if id == "" || id == "0" || strings.HasPrefix(id, "-") {
+		return ""
+	}
+	return "CWE-" + id
+}
+
+// normalizeCWEs formats a list of CWE IDs and drops the empty ones
+func normalizeCWEs(ids []string) []string {
+	var out []string
+	for _, id := range ids {
+		if cwe := normalizeCWE(id); cwe != "" {
+			out = append(out, cwe)
+		}
+	}
+	return out
+}
+
+// normalizeCVEs upper-cases CVE IDs and drops the empty ones
+func normalizeCVEs(ids []string) []string {
+	var out []string
+	for _, id := range ids {
+		if id = strings.ToUpper(strings.TrimSpace(id)); id != "" {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// urlHostPort returns the host and port of a URL, with the default port of its scheme
+func urlHostPort(raw string) (string, string) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", ""
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+	return u.Hostname(), port
+}
+
+// stringList decodes a JSON string or array of strings, tools are not consistent about it
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*l = nil
+	if s != "" {
+		*l = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// jsonText decodes a JSON string or number as text, e.g. ports written either way
+type jsonText string
+
+func (t *jsonText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = jsonText(s)
+		return nil
+	}
+	if string(data) == "null" {
+		*t = ""
+		return nil
+	}
+	*t = jsonText(data)
+	return nil
+}
+
+// jsonField follows a path of object keys through decoded JSON, nil when it is missing
+func jsonField(v interface{}, path ...string) interface{} {
+	for _, key := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+func jsonList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+func jsonString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func jsonBool(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeFindings(t *testing.T) {
+	tests := []struct {
+		tool   string
+		result string
+		want   models.Finding
+	}{
+		{
+			tool: models.ToolNuclei,
+			result: `{"results":[{"template-id":"CVE-2021-44228","matched-at":"https://app.example.com/login","matcher-name":"dns",
+				"info":{"name":"Log4Shell","severity":"critical","classification":{"cve-id":"cve-2021-44228","cwe-id":["cwe-502"],"cvss-score":10}}}]}`,
+			want: models.Finding{Tool: models.ToolNuclei, RuleID: "CVE-2021-44228:dns", Title: "Log4Shell", Severity: models.SeverityCritical,
+				CVSS: 10, CVEs: []string{"CVE-2021-44228"}, CWEs: []string{"CWE-502"}, Host: "app.example.com", Port: "443",
+				URL: "https://app.example.com/login", Evidence: "matcher: dns", RawRef: "results[0]"},
+		},
+		{
+			tool: models.ToolZap,
+			result: `{"alertsRaw":{"alerts":[{"pluginId":"40012","alert":"Cross Site Scripting (Reflected)","risk":"High","cweid":"79",
+				"url":"http://app.example.com:8080/search?q=x","param":"q"}]}}`,
+			want: models.Finding{Tool: models.ToolZap, RuleID: "40012", Title: "Cross Site Scripting (Reflected)", Severity: models.SeverityHigh,
+				CWEs: []string{"CWE-79"}, Host: "app.example.com", Port: "8080", URL: "http://app.example.com:8080/search?q=x",
+				Evidence: "param: q", RawRef: "alertsRaw.alerts[0]"},
+		},
+		{
+			tool: models.ToolOpenVAS,
+			result: `{"results":{"result":[{"name":"OpenSSH RCE","host":" 10.0.0.5 ","port":"22/tcp","threat":"High","severity":"9.8","qod":{"value":"95","type":"exploit"},
+				"description":"Installed version: 8.5p1",
+				"nvt":{"oid":"1.3.6.1.4.1.25623.1.0.1","tags":"cvss_base_vector=CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:H/A:H|summary=Remote code execution in sshd.|solution_type=VendorFix","refs":[{"type":"cve","id":"CVE-2024-6387"},{"type":"url","id":"https://example.com"}]}}]}}`,
+			want: models.Finding{Tool: models.ToolOpenVAS, RuleID: "1.3.6.1.4.1.25623.1.0.1", Title: "OpenSSH RCE", Severity: models.SeverityCritical,
+				Description: "Remote code execution in sshd.", Evidence: "Installed version: 8.5p1",
+				CVSS: 9.8, CVSSVector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:H/A:H", QoD: 95, KnownExploit: true, CVEs: []string{"CVE-2024-6387"}, Host: "10.0.0.5", Port: "22", RawRef: "results.result[0]"},
+		},
+		{
+			tool: models.ToolSslyze,
+			result: `{"server_scan_results":[{"server_location":{"hostname":"app.example.com","port":443},
+				"scan_result":{"heartbleed":{"result":{"is_vulnerable_to_heartbleed":true}}}}]}`,
+			want: models.Finding{Tool: models.ToolSslyze, RuleID: "heartbleed", Title: "Vulnerable to Heartbleed", Severity: models.SeverityCritical,
+				CVEs: []string{"CVE-2014-0160"}, Host: "app.example.com", Port: "443", RawRef: "server_scan_results[0].scan_result.heartbleed"},
+		},
+		{
+			tool:   models.ToolFfuf,
+			result: `{"results":[{"input":{"FUZZ":"admin"},"status":403,"length":12,"url":"https://app.example.com/admin"}]}`,
+			want: models.Finding{Tool: models.ToolFfuf, RuleID: "content-discovery", Title: "Discovered path /admin (HTTP 403)", Severity: models.SeverityInfo,
+				Host: "app.example.com", Port: "443", URL: "https://app.example.com/admin", Evidence: "HTTP 403, 12 bytes", RawRef: "results[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			// Stored results come back from the repository as raw JSON
+			job := &models.Job{ID: "job-1", Tool: tt.tool, Result: json.RawMessage(tt.result)}
+			findings, err := NormalizeFindings(job)
+			require.NoError(t, err)
+			require.Len(t, findings, 1)
+			assert.Len(t, findings[0].Fingerprint, 32)
+
+			tt.want.JobID = "job-1"
+			tt.want.Fingerprint = findings[0].Fingerprint
+			assert.Equal(t, tt.want, findings[0])
+		})
+	}
+}
+
+func TestDedupMergesToolsWithStableFingerprints(t *testing.T) {
+	zap := `{"alertsRaw":{"alerts":[
+		{"pluginId":"10035","alert":"Strict-Transport-Security Header Not Set","risk":"Low","cweid":"319","url":"https://app.example.com/"},
+		{"pluginId":"10035","alert":"Strict-Transport-Security Header Not Set","risk":"Low","cweid":"319","url":"https://app.example.com/login"},
+		{"pluginId":"40012","alert":"Cross Site Scripting (Reflected)","risk":"High","cweid":"79","url":"https://app.example.com/search?q=1"}]}}`
+	nuclei := `{"results":[
+		{"template-id":"http-missing-security-headers","matcher-name":"strict-transport-security","matched-at":"https://app.example.com","info":{"name":"HTTP Missing Security Headers","severity":"info"}},
+		{"template-id":"reflected-xss","matched-at":"https://app.example.com/search?q=2","info":{"name":"Reflected XSS","severity":"medium","classification":{"cwe-id":["cwe-79"]}}}]}`
+	// gvmd names the address it scanned and, next to it, the hostname the others report
+	report, err := parseGVMDReport([]byte(`<get_reports_response><report><report><results>
+		<result><name>SSL/TLS: HSTS Missing</name><host>203.0.113.10<asset asset_id="a1"/><hostname>app.example.com</hostname></host>
+		<port>443/tcp</port><threat>Log</threat><severity>0.0</severity><nvt oid="1.3.6.1.4.1.25623.1.0.105879"/></result></results></report></report></get_reports_response>`))
+	require.NoError(t, err)
+	assert.Equal(t, "app.example.com", report.Results.Result[0].Hostname)
+	stored, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(stored), `"host":"203.0.113.10","hostname":"app.example.com"`)
+	openvas := string(stored)
+
+	run := func(suffix string) []models.Finding {
+		var all []models.Finding
+		for tool, result := range map[string]string{models.ToolZap: zap, models.ToolNuclei: nuclei, models.ToolOpenVAS: openvas} {
+			findings, err := NormalizeFindings(&models.Job{ID: tool + suffix, Tool: tool, Result: json.RawMessage(result)})
+			require.NoError(t, err)
+			all = append(all, findings...)
+		}
+		merged := dedupFindings(all)
+		sortFindings(merged)
+		return merged
+	}
+
+	first := run("-1")
+	require.Len(t, first, 2)
+
+	xss := first[0]
+	assert.Equal(t, models.SeverityHigh, xss.Severity)
+	assert.Equal(t, models.ToolZap, xss.Tool)
+	assert.Len(t, xss.Sources, 2)
+
+	hsts := first[1]
+	assert.Len(t, hsts.Sources, 4)
+	assert.Equal(t, "app.example.com", hsts.Host)
+	tools := make(map[string]int)
+	for _, source := range hsts.Sources {
+		tools[source.Tool]++
+	}
+	assert.Equal(t, map[string]int{models.ToolZap: 2, models.ToolNuclei: 1, models.ToolOpenVAS: 1}, tools, "the OpenVAS result merges with ZAP and nuclei")
+	assert.Equal(t, models.SeverityLow, hsts.Severity)
+	assert.Equal(t, []string{"CWE-319"}, hsts.CWEs)
+
+	// A rescan finds the same issues under the same fingerprints
+	second := run("-2")
+	require.Len(t, second, 2)
+	assert.Equal(t, xss.Fingerprint, second[0].Fingerprint)
+	assert.Equal(t, hsts.Fingerprint, second[1].Fingerprint)
+}
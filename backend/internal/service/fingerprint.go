@@ -0,0 +1,133 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+
+	"napscan-be/internal/models"
+)
+
+// fingerprintVersion is part of every fingerprint; bump it when the scheme changes
+const fingerprintVersion = "v1"
+
+// ruleFamilies groups the rules of different tools that look for the same issue and
+// carry no CVE or CWE to match them on. Issues of a family are site-wide, so they
+// are matched per host and port, whatever URL a tool reported them on.
+var ruleFamilies = map[string]string{
+	models.ToolZap + ":10035": "missing-hsts",
+	models.ToolNuclei + ":http-missing-security-headers:strict-transport-security": "missing-hsts",
+	models.ToolOpenVAS + ":1.3.6.1.4.1.25623.1.0.105879":                           "missing-hsts",
+	models.ToolZap + ":10038":                                                      "missing-csp",
+	models.ToolNuclei + ":http-missing-security-headers:content-security-policy":   "missing-csp",
+	models.ToolZap + ":10020":                                                      "missing-x-frame-options",
+	models.ToolNuclei + ":http-missing-security-headers:x-frame-options":           "missing-x-frame-options",
+	models.ToolZap + ":10021":                                                      "missing-x-content-type-options",
+	models.ToolNuclei + ":http-missing-security-headers:x-content-type-options":    "missing-x-content-type-options",
+	models.ToolSslyze + ":tls10-enabled":                                           "deprecated-tls10",
+	models.ToolNuclei + ":deprecated-tls:tls_1.0":                                  "deprecated-tls10",
+	models.ToolSslyze + ":tls11-enabled":                                           "deprecated-tls11",
+	models.ToolNuclei + ":deprecated-tls:tls_1.1":                                  "deprecated-tls11",
+}
+
+// fingerprint identifies a finding by where it is and what it is, never by which tool
+// or run reported it. What it is comes from, in order: a known rule family, the
+// lowest CVE, the lowest CWE, and finally the tool's own rule. CVE and family issues
+// are matched per host and port, CWE and tool rules also per URL path.
+func fingerprint(f models.Finding) string {
+	host := strings.TrimSuffix(strings.Trim(strings.ToLower(f.Host), "[]"), ".")
+	path := ""
+
+	var identity string
+	switch {
+	case ruleFamilies[f.Tool+":"+f.RuleID] != "":
+		identity = "family:" + ruleFamilies[f.Tool+":"+f.RuleID]
+	case len(f.CVEs) > 0:
+		identity = "cve:" + lowest(f.CVEs)
+	case len(f.CWEs) > 0:
+		identity = "cwe:" + lowest(f.CWEs)
+		path = urlPath(f.URL)
+	default:
+		identity = "rule:" + f.Tool + ":" + f.RuleID
+		path = urlPath(f.URL)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{fingerprintVersion, host, f.Port, path, identity}, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func lowest(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted[0]
+}
+
+// urlPath returns the path of a URL without query or trailing slash
+func urlPath(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if path == "" && u.Host != "" {
+		path = "/"
+	}
+	return path
+}
+
+// dedupFindings merges findings with the same fingerprint into one finding with
+// several sources. The most severe report leads the merged finding; CVEs, CWEs and
+// exploit flags of all reports are combined, CVSS and QoD take the highest.
+// Reports that are not suppressed lead over suppressed ones, and the merged finding
+// is only suppressed when all its reports are. Findings keep the order of their
+// first report.
+func dedupFindings(findings []models.Finding) []models.Finding {
+	merged := make([]models.Finding, 0, len(findings))
+	index := make(map[string]int, len(findings))
+
+	for _, f := range findings {
+		source := models.FindingSource{Tool: f.Tool, JobID: f.JobID, RuleID: f.RuleID, RawRef: f.RawRef, SuppressedBy: f.SuppressedBy}
+		i, ok := index[f.Fingerprint]
+		if !ok {
+			f.Sources = []models.FindingSource{source}
+			index[f.Fingerprint] = len(merged)
+			merged = append(merged, f)
+			continue
+		}
+
+		m := &merged[i]
+		sources := append(m.Sources, source)
+		cves := mergeStrings(m.CVEs, f.CVEs)
+		cwes := mergeStrings(m.CWEs, f.CWEs)
+		cvss, qod, exploit := m.CVSS, m.QoD, m.KnownExploit || f.KnownExploit
+		if f.CVSS > cvss {
+			cvss = f.CVSS
+		}
+		if f.QoD > qod {
+			qod = f.QoD
+		}
+		leads := severityRank(f.Severity) < severityRank(m.Severity)
+		if (f.SuppressedBy == "") != (m.SuppressedBy == "") {
+			leads = f.SuppressedBy == ""
+		}
+		if leads {
+			*m = f
+		}
+		m.Sources, m.CVEs, m.CWEs = sources, cves, cwes
+		m.CVSS, m.QoD, m.KnownExploit = cvss, qod, exploit
+	}
+	return merged
+}
+
+// mergeStrings returns the union of a and b in first-seen order
+func mergeStrings(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, s := range b {
+		if !containsString(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"napscan-be/internal/models"
+)
+
+// eventBuffer is how many undelivered updates a slow subscriber may fall behind.
+// Older updates are dropped first, the latest state always gets through.
+const eventBuffer = 16
+
+// Subscribe streams snapshots of a job: the current state first, then every change.
+// The channel is closed once the job has finished; call the returned func to stop early.
+func (s *JobService) Subscribe(id string) (<-chan *models.Job, func(), error) {
+	ch := make(chan *models.Job, eventBuffer)
+
+	s.mu.Lock()
+	a, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		job, err := s.Get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Already finished, there is nothing more to stream than the final state
+		ch <- job
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	ch <- copyJob(a.job)
+	if s.subs[id] == nil {
+		s.subs[id] = make(map[chan *models.Job]struct{})
+	}
+	s.subs[id][ch] = struct{}{}
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[id][ch]; ok {
+			delete(s.subs[id], ch)
+			close(ch)
+		}
+		if len(s.subs[id]) == 0 {
+			delete(s.subs, id)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// publishLocked sends a snapshot of job to its subscribers without blocking, and
+// ends their streams when the job is finished. The caller must hold s.mu.
+func (s *JobService) publishLocked(job *models.Job) {
+	subs := s.subs[job.ID]
+	if len(subs) == 0 {
+		return
+	}
+
+	for ch := range subs {
+		snapshot := copyJob(job)
+		select {
+		case ch <- snapshot:
+		default:
+			// Drop the oldest update to make room for the newest one
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- snapshot
+		}
+	}
+
+	if job.Status.IsTerminal() {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(s.subs, job.ID)
+	}
+}
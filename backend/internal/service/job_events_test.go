@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeStreamsUntilTheJobFinishes(t *testing.T) {
+	s := newTestJobService(t, DefaultPoolConfig())
+	progress, release := make(chan struct{}), make(chan struct{})
+	s.Register(models.ToolNmap, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		<-progress
+		run.SetProgress(50, "half way")
+		<-release
+		return nil, nil
+	})
+
+	job, err := s.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.1"})
+	require.NoError(t, err)
+	waitForStatus(t, s, job.ID, models.JobStatusRunning)
+
+	events, unsubscribe, err := s.Subscribe(job.ID)
+	require.NoError(t, err)
+	defer unsubscribe()
+	other, stop, err := s.Subscribe(job.ID)
+	require.NoError(t, err)
+
+	// The current state comes first
+	first := <-events
+	assert.Equal(t, models.JobStatusRunning, first.Status)
+	<-other
+
+	// A subscriber that stops early gets its channel closed and nothing more
+	stop()
+	_, ok := <-other
+	assert.False(t, ok)
+	stop()
+
+	close(progress)
+	update := <-events
+	assert.Equal(t, 50, update.Progress)
+	assert.Equal(t, "half way", update.Message)
+
+	close(release)
+	var last *models.Job
+	for job := range events {
+		last = job
+	}
+	require.NotNil(t, last, "the final state is sent before the channel is closed")
+	assert.Equal(t, models.JobStatusCompleted, last.Status)
+
+	// A finished job streams its final state only
+	events, _, err = s.Subscribe(job.ID)
+	require.NoError(t, err)
+	final, ok := <-events
+	require.True(t, ok)
+	assert.Equal(t, models.JobStatusCompleted, final.Status)
+	_, ok = <-events
+	assert.False(t, ok)
+
+	_, _, err = s.Subscribe("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"napscan-be/internal/models"
+)
+
+// PoolConfig limits how many jobs run at the same time. Jobs over the limits wait
+// in a FIFO queue. A limit of 0 means unlimited.
+type PoolConfig struct {
+	MaxConcurrent int
+	ToolLimits    map[string]int
+}
+
+// DefaultPoolConfig keeps the scanning host usable: one OpenVAS task, two ZAP scans
+// and four nmap scans at most, and no more than eight jobs overall.
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxConcurrent: 8,
+		ToolLimits: map[string]int{
+			models.ToolOpenVAS: 1,
+			models.ToolZap:     2,
+			models.ToolNmap:    4,
+		},
+	}
+}
+
+// PoolConfigFromEnv reads NAPSCAN_MAX_CONCURRENT_JOBS and NAPSCAN_TOOL_LIMITS
+// (e.g. "openvas=1,zap=2,nmap=4") on top of DefaultPoolConfig
+func PoolConfigFromEnv() PoolConfig {
+	cfg := DefaultPoolConfig()
+
+	if v := strings.TrimSpace(os.Getenv("NAPSCAN_MAX_CONCURRENT_JOBS")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Printf("Ignoring invalid NAPSCAN_MAX_CONCURRENT_JOBS %q", v)
+		} else {
+			cfg.MaxConcurrent = n
+		}
+	}
+
+	for _, pair := range strings.Split(os.Getenv("NAPSCAN_TOOL_LIMITS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tool, value, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || n < 0 {
+			log.Printf("Ignoring invalid tool limit %q", pair)
+			continue
+		}
+		cfg.ToolLimits[strings.TrimSpace(tool)] = n
+	}
+
+	return cfg
+}
+
+// Queue returns the running jobs and the waiting jobs in the order they will be started
+func (s *JobService) Queue() *models.QueueStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := &models.QueueStatus{
+		Running:       []*models.Job{},
+		Queued:        []models.QueueEntry{},
+		MaxConcurrent: s.pool.MaxConcurrent,
+		ToolLimits:    make(map[string]int, len(s.pool.ToolLimits)),
+		RunningByTool: make(map[string]int, len(s.running)),
+	}
+	for tool, n := range s.pool.ToolLimits {
+		status.ToolLimits[tool] = n
+	}
+	for tool, n := range s.running {
+		if n > 0 {
+			status.RunningByTool[tool] = n
+		}
+	}
+
+	for i, id := range s.queue {
+		status.Queued = append(status.Queued, models.QueueEntry{
+			Position: i + 1,
+			Job:      copyJob(s.jobs[id].job),
+		})
+	}
+	for _, a := range s.jobs {
+		if a.job.Status == models.JobStatusRunning {
+			status.Running = append(status.Running, copyJob(a.job))
+		}
+	}
+	sort.Slice(status.Running, func(i, j int) bool {
+		return status.Running[i].StartedAt.Before(*status.Running[j].StartedAt)
+	})
+
+	return status
+}
+
+// dispatchLocked starts queued jobs in FIFO order while there is capacity.
+// A job whose tool is at its limit does not block jobs of other tools behind it.
+// The caller must hold s.mu.
+func (s *JobService) dispatchLocked() {
+	remaining := s.queue[:0]
+	for _, id := range s.queue {
+		a := s.jobs[id]
+		if !s.hasCapacityLocked(a.job.Tool) {
+			remaining = append(remaining, id)
+			continue
+		}
+
+		s.active++
+		s.running[a.job.Tool]++
+		a.job.Status = models.JobStatusRunning
+		if a.job.StartedAt == nil {
+			// Resumed jobs keep the time their scan was started originally
+			now := time.Now()
+			a.job.StartedAt = &now
+		}
+		s.persistLocked(a.job)
+
+		go s.execute(a)
+	}
+	s.queue = remaining
+}
+
+func (s *JobService) hasCapacityLocked(tool string) bool {
+	if s.pool.MaxConcurrent > 0 && s.active >= s.pool.MaxConcurrent {
+		return false
+	}
+	if limit := s.pool.ToolLimits[tool]; limit > 0 && s.running[tool] >= limit {
+		return false
+	}
+	return true
+}
+
+// releaseLocked frees the worker slot of a finished job and starts the next ones.
+// The caller must hold s.mu.
+func (s *JobService) releaseLocked(tool string) {
+	s.active--
+	s.running[tool]--
+	s.dispatchLocked()
+	close(s.freed)
+	s.freed = make(chan struct{})
+}
+
+// borrowSlot takes a worker slot of tool for a running job that runs several tool
+// processes at once, such as the shards of an nmap range scan, so the pool limits
+// count each of them. Queued jobs come first: dispatchLocked has already started
+// every job that fits, the slot is one none of them can use.
+func (s *JobService) borrowSlot(tool string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.hasCapacityLocked(tool) {
+		return false
+	}
+	s.active++
+	s.running[tool]++
+	return true
+}
+
+// returnSlot gives back a slot taken by borrowSlot
+func (s *JobService) returnSlot(tool string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked(tool)
+}
+
+// slotFreed returns a channel that is closed when the next worker slot is released
+func (s *JobService) slotFreed() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.freed
+}
+
+// dequeueLocked removes a waiting job from the queue and reports whether it was there.
+// The caller must hold s.mu.
+func (s *JobService) dequeueLocked(id string) bool {
+	for i, queued := range s.queue {
+		if queued == id {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
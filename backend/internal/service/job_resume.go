@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+)
+
+// Resume reloads the jobs that were queued or running when the backend stopped.
+// Scans of resumable tools are re-attached through their stored refs and polled to
+// completion. Local tool processes died with the backend, so those jobs are marked
+// interrupted and can be re-queued with Requeue.
+func (s *JobService) Resume() error {
+	var pending []*models.Job
+	for _, status := range []models.JobStatus{models.JobStatusRunning, models.JobStatusQueued} {
+		jobs, err := s.repo.ListJobs(context.Background(), repository.JobFilter{Status: status})
+		if err != nil {
+			return fmt.Errorf("failed to load %s jobs: %w", status, err)
+		}
+		pending = append(pending, jobs...)
+	}
+
+	// Running jobs get their worker slots back first, then the queue in FIFO order
+	sort.SliceStable(pending, func(i, j int) bool {
+		ri := pending[i].Status == models.JobStatusRunning
+		rj := pending[j].Status == models.JobStatusRunning
+		if ri != rj {
+			return ri
+		}
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+
+	var finished []*models.Job
+	defer func() {
+		for _, job := range finished {
+			s.notifyFinished(job)
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var resumed, requeued, interrupted int
+	for _, job := range pending {
+		if _, ok := s.jobs[job.ID]; ok {
+			continue
+		}
+
+		runner, ok := s.runners[job.Tool]
+		if !ok {
+			now := time.Now()
+			job.Status = models.JobStatusFailed
+			job.Error = fmt.Sprintf("%v: %s", ErrUnknownTool, job.Tool)
+			job.FinishedAt = &now
+			s.persistLocked(job)
+			finished = append(finished, job)
+			continue
+		}
+
+		switch {
+		case job.Status == models.JobStatusQueued:
+			requeued++
+		case s.resumable[job.Tool]:
+			job.Status = models.JobStatusQueued
+			job.Message = "Resuming after a backend restart"
+			resumed++
+		default:
+			now := time.Now()
+			job.Status = models.JobStatusInterrupted
+			job.Message = "Interrupted by a backend restart"
+			job.FinishedAt = &now
+			s.persistLocked(job)
+			finished = append(finished, job)
+			interrupted++
+			continue
+		}
+
+		s.persistLocked(job)
+		s.enqueueLocked(job, runner)
+	}
+	s.dispatchLocked()
+
+	if len(pending) > 0 {
+		log.Printf("Jobs restored: %d resumed, %d re-queued, %d interrupted", resumed, requeued, interrupted)
+	}
+	return nil
+}
+
+// Requeue runs an interrupted job again from the start under the same ID
+func (s *JobService) Requeue(id string) (*models.Job, error) {
+	job, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; ok || job.Status != models.JobStatusInterrupted {
+		return nil, ErrJobNotInterrupted
+	}
+	runner, ok := s.runners[job.Tool]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, job.Tool)
+	}
+
+	job.Status = models.JobStatusQueued
+	job.Progress = 0
+	job.Message = "Re-queued"
+	job.Refs = nil
+	job.Result = nil
+	job.Error = ""
+	job.StartedAt = nil
+	job.FinishedAt = nil
+	if err := s.repo.SaveJob(context.Background(), job); err != nil {
+		return nil, fmt.Errorf("failed to save job: %w", err)
+	}
+
+	s.enqueueLocked(job, runner)
+	snapshot := copyJob(job)
+	s.dispatchLocked()
+	return snapshot, nil
+}
+
+// enqueueLocked makes job active and appends it to the FIFO queue.
+// The caller must hold s.mu and call dispatchLocked afterwards.
+func (s *JobService) enqueueLocked(job *models.Job, runner JobRunner) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.jobs[job.ID] = &activeJob{
+		job:    job,
+		req:    models.JobRequest{Tool: job.Tool, Target: job.Target, Options: job.Options, ScanID: job.ScanID, ParentID: job.ParentID},
+		runner: runner,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	s.queue = append(s.queue, job.ID)
+}
@@ -0,0 +1,607 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobFinished       = errors.New("job already finished")
+	ErrUnknownTool       = errors.New("unknown tool")
+	ErrInvalidOptions    = errors.New("invalid options")
+	ErrJobNotInterrupted = errors.New("only interrupted jobs can be re-queued")
+)
+
+// OptionsValidator checks the options of a job before it is queued
+type OptionsValidator func(options map[string]string) error
+
+// JobRunner executes the actual tool for a job and returns its result.
+// It is called from a background goroutine, never from a request handler.
+type JobRunner func(ctx context.Context, run *JobRun) (interface{}, error)
+
+// JobRun is the handle a JobRunner uses to read its job and report back
+type JobRun struct {
+	svc *JobService
+	id  string
+	req models.JobRequest
+}
+
+// Target returns the target of the job
+func (r *JobRun) Target() string {
+	return r.req.Target
+}
+
+// Option returns a job option or "" when it is not set
+func (r *JobRun) Option(key string) string {
+	return r.req.Options[key]
+}
+
+// SetProgress records the percent complete and an optional status message
+func (r *JobRun) SetProgress(percent int, message string) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	r.svc.update(r.id, func(j *models.Job) {
+		j.Progress = percent
+		if message != "" {
+			j.Message = message
+		}
+	})
+}
+
+// SetRef stores the ID of the scan inside an external daemon (e.g. OpenVAS task ID)
+func (r *JobRun) SetRef(key, value string) {
+	r.svc.update(r.id, func(j *models.Job) {
+		if j.Refs == nil {
+			j.Refs = make(map[string]string)
+		}
+		j.Refs[key] = value
+	})
+}
+
+// SetShards replaces the parts of a range scan
+func (r *JobRun) SetShards(shards []models.JobShard) {
+	r.svc.update(r.id, func(j *models.Job) {
+		j.Shards = append([]models.JobShard(nil), shards...)
+	})
+}
+
+// SetShard records the progress of a part of a range scan, adding it when it is new
+func (r *JobRun) SetShard(shard models.JobShard) {
+	r.svc.update(r.id, func(j *models.Job) {
+		for i := range j.Shards {
+			if j.Shards[i].Index == shard.Index {
+				j.Shards[i] = shard
+				return
+			}
+		}
+		j.Shards = append(j.Shards, shard)
+	})
+}
+
+// Ref returns a previously stored external reference
+func (r *JobRun) Ref(key string) string {
+	r.svc.mu.RLock()
+	defer r.svc.mu.RUnlock()
+	if a, ok := r.svc.jobs[r.id]; ok {
+		return a.job.Refs[key]
+	}
+	return ""
+}
+
+// SaveOutput stores a native output file of the tool next to the job
+func (r *JobRun) SaveOutput(name, contentType string, content []byte) {
+	out := &models.ToolOutput{
+		JobID:       r.id,
+		Name:        name,
+		Tool:        r.req.Tool,
+		ContentType: contentType,
+		Size:        len(content),
+		Content:     content,
+		CreatedAt:   time.Now(),
+	}
+	if err := r.svc.repo.SaveOutput(context.Background(), out); err != nil {
+		log.Printf("Failed to save output %s of job %s: %v", name, r.id, err)
+	}
+}
+
+type jobRunKey struct{}
+
+// withJobRun attaches the run to ctx so the services can report back without extra parameters
+func withJobRun(ctx context.Context, run *JobRun) context.Context {
+	return context.WithValue(ctx, jobRunKey{}, run)
+}
+
+// jobRunFromContext returns the current run or nil when the service is not called from a job
+func jobRunFromContext(ctx context.Context) *JobRun {
+	run, _ := ctx.Value(jobRunKey{}).(*JobRun)
+	return run
+}
+
+// recordOutput saves a native tool output when the call is part of a job
+func recordOutput(ctx context.Context, name, contentType string, content []byte) {
+	if run := jobRunFromContext(ctx); run != nil {
+		run.SaveOutput(name, contentType, content)
+	}
+}
+
+// recordRef stores an external scan ID when the call is part of a job
+func recordRef(ctx context.Context, key, value string) {
+	if run := jobRunFromContext(ctx); run != nil {
+		run.SetRef(key, value)
+	}
+}
+
+// reportShards lists the parts of a range scan when the call is part of a job
+func reportShards(ctx context.Context, shards []models.JobShard) {
+	if run := jobRunFromContext(ctx); run != nil {
+		run.SetShards(shards)
+	}
+}
+
+// reportShard updates the progress of a part of a range scan when the call is part of a job
+func reportShard(ctx context.Context, shard models.JobShard) {
+	if run := jobRunFromContext(ctx); run != nil {
+		run.SetShard(shard)
+	}
+}
+
+// borrowJobSlot takes another worker slot of the job's tool for work the job runs in
+// parallel. Outside a job there is no pool to take it from and it always succeeds.
+func borrowJobSlot(ctx context.Context) (release func(), ok bool) {
+	run := jobRunFromContext(ctx)
+	if run == nil {
+		return func() {}, true
+	}
+	if !run.svc.borrowSlot(run.req.Tool) {
+		return nil, false
+	}
+	return func() { run.svc.returnSlot(run.req.Tool) }, true
+}
+
+// jobSlotFreed returns a channel closed when the pool releases a worker slot, or nil
+// outside a job
+func jobSlotFreed(ctx context.Context) <-chan struct{} {
+	if run := jobRunFromContext(ctx); run != nil {
+		return run.svc.slotFreed()
+	}
+	return nil
+}
+
+// jobRef returns an external scan ID stored by an earlier run of the job, or ""
+func jobRef(ctx context.Context, key string) string {
+	if run := jobRunFromContext(ctx); run != nil {
+		return run.Ref(key)
+	}
+	return ""
+}
+
+type progressKey struct{}
+
+// withProgress routes reportProgress calls made with ctx to fn, so a service can
+// combine the progress of parallel steps before it reaches the job
+func withProgress(ctx context.Context, fn func(percent int, message string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress updates the percent complete when the call is part of a job
+func reportProgress(ctx context.Context, percent int, message string) {
+	if fn, ok := ctx.Value(progressKey{}).(func(int, string)); ok {
+		fn(percent, message)
+		return
+	}
+	if run := jobRunFromContext(ctx); run != nil {
+		run.SetProgress(percent, message)
+	}
+}
+
+// activeJob is a job that has not finished yet
+type activeJob struct {
+	job       *models.Job
+	req       models.JobRequest
+	runner    JobRunner
+	ctx       context.Context
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// JobService runs scans in the background and keeps track of their state.
+// Active jobs are kept in memory, every change is written through to the repository.
+// At most pool.MaxConcurrent jobs run at once, the rest wait in a FIFO queue.
+type JobService struct {
+	mu      sync.RWMutex
+	jobs    map[string]*activeJob
+	queue   []string                                 // IDs of waiting jobs in submission order
+	running map[string]int                           // running jobs per tool
+	active  int                                      // running jobs overall
+	subs    map[string]map[chan *models.Job]struct{} // event stream subscribers per job
+	runners map[string]JobRunner
+	// resumable tools run inside external daemons and survive a backend restart
+	resumable map[string]bool
+	// validators check the options of a tool; tools without one take no options
+	validators map[string]OptionsValidator
+	finished   []func(job *models.Job)
+	pool       PoolConfig
+	repo       repository.Repository
+	// freed is closed and replaced whenever a worker slot is released
+	freed chan struct{}
+}
+
+func NewJobService(repo repository.Repository, pool PoolConfig) *JobService {
+	return &JobService{
+		jobs:       make(map[string]*activeJob),
+		running:    make(map[string]int),
+		subs:       make(map[string]map[chan *models.Job]struct{}),
+		runners:    make(map[string]JobRunner),
+		resumable:  make(map[string]bool),
+		validators: make(map[string]OptionsValidator),
+		pool:       pool,
+		freed:      make(chan struct{}),
+		repo:       repo,
+	}
+}
+
+// Register makes a tool available to Submit
+func (s *JobService) Register(tool string, runner JobRunner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runners[tool] = runner
+}
+
+// RegisterResumable registers a tool whose scans keep running in an external daemon.
+// After a restart its runner is called again with the refs of the earlier run, so it
+// can re-attach to the scan instead of starting a new one.
+func (s *JobService) RegisterResumable(tool string, runner JobRunner) {
+	s.Register(tool, runner)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resumable[tool] = true
+}
+
+// RegisterOptions sets the validator of the options of a tool
+func (s *JobService) RegisterOptions(tool string, validate OptionsValidator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators[tool] = validate
+}
+
+// ValidateOptions checks options for tool the way Submit does. Options of tools
+// without a validator are passed through untouched, since their runners ignore them.
+func (s *JobService) ValidateOptions(tool string, options map[string]string) error {
+	s.mu.RLock()
+	validate := s.validators[tool]
+	s.mu.RUnlock()
+	if validate == nil {
+		return nil
+	}
+	if err := validate(options); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidOptions, tool, err)
+	}
+	return nil
+}
+
+// OnFinish registers fn to be called with the final state of every job that finishes.
+// It is called from the job's goroutine and must not block for long.
+func (s *JobService) OnFinish(fn func(job *models.Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = append(s.finished, fn)
+}
+
+// HasTool reports whether a runner is registered for tool
+func (s *JobService) HasTool(tool string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.runners[tool]
+	return ok
+}
+
+// Submit creates a job and queues it for a background worker.
+// It returns immediately with the queued job.
+func (s *JobService) Submit(req models.JobRequest) (*models.Job, error) {
+	req.Target = strings.TrimSpace(req.Target)
+	if _, err := ParseTarget(req.Target); err != nil {
+		return nil, err
+	}
+	if err := s.ValidateOptions(req.Tool, req.Options); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	runner, ok := s.runners[req.Tool]
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, req.Tool)
+	}
+
+	job := &models.Job{
+		ID:        uuid.NewString(),
+		Tool:      req.Tool,
+		Target:    req.Target,
+		Options:   req.Options,
+		ScanID:    req.ScanID,
+		ParentID:  req.ParentID,
+		Status:    models.JobStatusQueued,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.SaveJob(context.Background(), job); err != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("failed to save job: %w", err)
+	}
+	s.enqueueLocked(job, runner)
+	snapshot := copyJob(job)
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	return snapshot, nil
+}
+
+// JobImport is a job that ran outside of NapScan
+type JobImport struct {
+	Request models.JobRequest
+	Result  interface{}
+	// Output is the native output Result was read from
+	Output                *models.ToolOutput
+	StartedAt, FinishedAt time.Time
+}
+
+// Import stores jobs that ran outside of NapScan as completed, with the native outputs
+// their results were read from. The OnFinish hooks see them like any other jobs that
+// finished, but only once all of them are stored, so a scan never shows some of them.
+func (s *JobService) Import(imports []JobImport) ([]*models.Job, error) {
+	for _, imp := range imports {
+		if _, ok := findingNormalizers[imp.Request.Tool]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTool, imp.Request.Tool)
+		}
+	}
+
+	ctx := context.Background()
+	jobs := make([]*models.Job, len(imports))
+	for i, imp := range imports {
+		startedAt, finishedAt := imp.StartedAt, imp.FinishedAt
+		job := &models.Job{
+			ID:         uuid.NewString(),
+			Tool:       imp.Request.Tool,
+			Target:     strings.TrimSpace(imp.Request.Target),
+			ScanID:     imp.Request.ScanID,
+			Status:     models.JobStatusCompleted,
+			Progress:   100,
+			Message:    "Imported",
+			Result:     imp.Result,
+			CreatedAt:  time.Now(),
+			StartedAt:  &startedAt,
+			FinishedAt: &finishedAt,
+		}
+		if err := s.repo.SaveJob(ctx, job); err != nil {
+			return nil, fmt.Errorf("failed to save job: %w", err)
+		}
+		if output := imp.Output; output != nil {
+			output.JobID = job.ID
+			output.Tool = job.Tool
+			output.Size = len(output.Content)
+			output.CreatedAt = job.CreatedAt
+			if err := s.repo.SaveOutput(ctx, output); err != nil {
+				return nil, fmt.Errorf("failed to save output: %w", err)
+			}
+		}
+		jobs[i] = job
+	}
+
+	for i, job := range jobs {
+		s.notifyFinished(job)
+		jobs[i] = copyJob(job)
+	}
+	return jobs, nil
+}
+
+// Get returns a snapshot of the job, falling back to the repository for finished jobs
+func (s *JobService) Get(id string) (*models.Job, error) {
+	s.mu.RLock()
+	a, ok := s.jobs[id]
+	if ok {
+		snapshot := copyJob(a.job)
+		s.mu.RUnlock()
+		return snapshot, nil
+	}
+	s.mu.RUnlock()
+
+	stored, err := s.repo.GetJob(context.Background(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrJobNotFound
+	}
+	return stored, err
+}
+
+// List returns stored jobs, newest first
+func (s *JobService) List(filter repository.JobFilter) ([]*models.Job, error) {
+	jobs, err := s.repo.ListJobs(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer the live in-memory state for jobs that are still running
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, j := range jobs {
+		if a, ok := s.jobs[j.ID]; ok {
+			jobs[i] = copyJob(a.job)
+		}
+	}
+	return jobs, nil
+}
+
+// Outputs lists the native output files stored for a job
+func (s *JobService) Outputs(id string) ([]*models.ToolOutput, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListOutputs(context.Background(), id)
+}
+
+// Output returns a single native output file of a job
+func (s *JobService) Output(id, name string) (*models.ToolOutput, error) {
+	out, err := s.repo.GetOutput(context.Background(), id, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrJobNotFound
+	}
+	return out, err
+}
+
+// Cancel stops a queued or running job. The job context is cancelled, which kills
+// local tool processes and stops scans inside the ZAP and OpenVAS daemons.
+func (s *JobService) Cancel(id string) (*models.Job, error) {
+	s.mu.Lock()
+	a, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		if _, err := s.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobFinished
+	}
+
+	a.cancelled = true
+	a.cancel()
+
+	// A job that is still waiting never got a worker, finish it right away
+	if s.dequeueLocked(id) {
+		now := time.Now()
+		a.job.Status = models.JobStatusCancelled
+		a.job.Message = "Cancelled by user"
+		a.job.FinishedAt = &now
+		s.persistLocked(a.job)
+		delete(s.jobs, id)
+		snapshot := copyJob(a.job)
+		s.mu.Unlock()
+		s.notifyFinished(snapshot)
+		return snapshot, nil
+	}
+
+	a.job.Message = "Cancellation requested"
+	s.persistLocked(a.job)
+	snapshot := copyJob(a.job)
+	s.mu.Unlock()
+
+	return snapshot, nil
+}
+
+// execute runs a job on a worker slot taken by dispatchLocked
+func (s *JobService) execute(a *activeJob) {
+	id := a.job.ID
+	run := &JobRun{svc: s, id: id, req: a.req}
+
+	var (
+		result interface{}
+		err    error
+	)
+	if a.ctx.Err() == nil {
+		result, err = s.safeRun(a.ctx, run, a.runner)
+	}
+	a.cancel()
+
+	s.update(id, func(j *models.Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		if a.cancelled {
+			j.Status = models.JobStatusCancelled
+			j.Message = "Cancelled by user"
+			return
+		}
+		if err != nil {
+			j.Status = models.JobStatusFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = models.JobStatusCompleted
+		j.Progress = 100
+		j.Message = "Completed"
+		j.Result = result
+	})
+
+	// Finished jobs are served from the repository from now on
+	s.mu.Lock()
+	snapshot := copyJob(a.job)
+	delete(s.jobs, id)
+	s.releaseLocked(a.req.Tool)
+	s.mu.Unlock()
+
+	s.notifyFinished(snapshot)
+}
+
+// notifyFinished calls the OnFinish hooks. The caller must not hold s.mu.
+func (s *JobService) notifyFinished(job *models.Job) {
+	s.mu.RLock()
+	hooks := append([]func(*models.Job){}, s.finished...)
+	s.mu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(copyJob(job))
+	}
+}
+
+// safeRun turns a panicking runner into a failed job instead of crashing the server
+func (s *JobService) safeRun(ctx context.Context, run *JobRun, runner JobRunner) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", run.id, r)
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return runner(withJobRun(ctx, run), run)
+}
+
+// update applies fn to the live job and writes the new state through to the repository
+func (s *JobService) update(id string, fn func(j *models.Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	fn(a.job)
+	s.persistLocked(a.job)
+}
+
+// persistLocked writes the job through to the repository and notifies the event
+// stream subscribers. The caller must hold s.mu.
+func (s *JobService) persistLocked(job *models.Job) {
+	if err := s.repo.SaveJob(context.Background(), job); err != nil {
+		log.Printf("Failed to persist job %s: %v", job.ID, err)
+	}
+	s.publishLocked(job)
+}
+
+// copyJob returns a copy that is safe to serialize while the job keeps running
+func copyJob(j *models.Job) *models.Job {
+	c := *j
+	if j.Options != nil {
+		c.Options = make(map[string]string, len(j.Options))
+		for k, v := range j.Options {
+			c.Options[k] = v
+		}
+	}
+	if j.Refs != nil {
+		c.Refs = make(map[string]string, len(j.Refs))
+		for k, v := range j.Refs {
+			c.Refs[k] = v
+		}
+	}
+	if j.Shards != nil {
+		c.Shards = append([]models.JobShard(nil), j.Shards...)
+	}
+	return &c
+}
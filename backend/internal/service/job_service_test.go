@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJobService(t *testing.T, pool PoolConfig) *JobService {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	return NewJobService(repo, pool)
+}
+
+func waitForStatus(t *testing.T, s *JobService, id string, status models.JobStatus) {
+	require.Eventually(t, func() bool {
+		job, err := s.Get(id)
+		return err == nil && job.Status == status
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSubmitRunsJobsAndGetReturnsTheirState(t *testing.T) {
+	s := newTestJobService(t, DefaultPoolConfig())
+	release := make(chan struct{})
+	s.Register(models.ToolNuclei, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		run.SetProgress(40, "templates loaded")
+		<-release
+		if run.Target() == "broken.example.com" {
+			return nil, errors.New("nuclei exited with status 2")
+		}
+		return map[string]interface{}{"target": run.Target(), "severity": run.Option("severity")}, nil
+	})
+
+	_, err := s.Submit(models.JobRequest{Tool: "unknown", Target: "example.com"})
+	assert.ErrorIs(t, err, ErrUnknownTool)
+	_, err = s.Submit(models.JobRequest{Tool: models.ToolNuclei, Target: "-oN /tmp/x"})
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+
+	job, err := s.Submit(models.JobRequest{Tool: models.ToolNuclei, Target: " example.com ", Options: map[string]string{"severity": "high"}})
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusQueued, job.Status, "the snapshot is taken before the job starts")
+	assert.Equal(t, "example.com", job.Target)
+	broken, err := s.Submit(models.JobRequest{Tool: models.ToolNuclei, Target: "broken.example.com"})
+	require.NoError(t, err)
+
+	waitForStatus(t, s, job.ID, models.JobStatusRunning)
+	require.Eventually(t, func() bool {
+		running, err := s.Get(job.ID)
+		return err == nil && running.Progress == 40
+	}, 2*time.Second, 10*time.Millisecond)
+	running, err := s.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "templates loaded", running.Message)
+	assert.NotNil(t, running.StartedAt)
+
+	close(release)
+	waitForStatus(t, s, job.ID, models.JobStatusCompleted)
+	waitForStatus(t, s, broken.ID, models.JobStatusFailed)
+
+	// Finished jobs come back from the repository
+	done, err := s.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 100, done.Progress)
+	assert.NotNil(t, done.FinishedAt)
+	result, err := json.Marshal(done.Result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"target":"example.com","severity":"high"}`, string(result))
+
+	failed, err := s.Get(broken.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "nuclei exited with status 2", failed.Error)
+
+	_, err = s.Get("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestPoolRespectsToolLimitsInFIFOOrder(t *testing.T) {
+	s := newTestJobService(t, PoolConfig{MaxConcurrent: 2, ToolLimits: map[string]int{models.ToolNmap: 1}})
+
+	release := make(chan struct{})
+	blocking := func(ctx context.Context, run *JobRun) (interface{}, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}
+	s.Register(models.ToolNmap, blocking)
+	s.Register(models.ToolNuclei, blocking)
+
+	first, err := s.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "a"})
+	require.NoError(t, err)
+	second, err := s.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "b"})
+	require.NoError(t, err)
+	third, err := s.Submit(models.JobRequest{Tool: models.ToolNuclei, Target: "c"})
+	require.NoError(t, err)
+	fourth, err := s.Submit(models.JobRequest{Tool: models.ToolNuclei, Target: "d"})
+	require.NoError(t, err)
+
+	// nmap is capped at 1, so the nuclei job behind it takes the second slot
+	queue := s.Queue()
+	assert.Len(t, queue.Running, 2)
+	require.Len(t, queue.Queued, 2)
+	assert.Equal(t, second.ID, queue.Queued[0].Job.ID)
+	assert.Equal(t, 1, queue.Queued[0].Position)
+	assert.Equal(t, fourth.ID, queue.Queued[1].Job.ID)
+	assert.Equal(t, 2, queue.Queued[1].Position)
+
+	// Cancelling a waiting job finishes it without taking a slot
+	cancelled, err := s.Cancel(fourth.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCancelled, cancelled.Status)
+	assert.Len(t, s.Queue().Queued, 1)
+
+	close(release)
+	waitForStatus(t, s, first.ID, models.JobStatusCompleted)
+	waitForStatus(t, s, third.ID, models.JobStatusCompleted)
+	waitForStatus(t, s, second.ID, models.JobStatusCompleted)
+	assert.Empty(t, s.Queue().Running)
+}
+
+func TestBorrowedSlotsCountAgainstToolLimits(t *testing.T) {
+	s := newTestJobService(t, PoolConfig{MaxConcurrent: 8, ToolLimits: map[string]int{models.ToolNmap: 2}})
+	release := make(chan struct{})
+	s.Register(models.ToolNmap, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+
+	first, err := s.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.0/24"})
+	require.NoError(t, err)
+	waitForStatus(t, s, first.ID, models.JobStatusRunning)
+
+	// The range scan borrows the second nmap slot for a shard, the next job waits for it
+	require.True(t, s.borrowSlot(models.ToolNmap))
+	assert.False(t, s.borrowSlot(models.ToolNmap))
+	second, err := s.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.2"})
+	require.NoError(t, err)
+	queue := s.Queue()
+	assert.Equal(t, 2, queue.RunningByTool[models.ToolNmap])
+	assert.Len(t, queue.Queued, 1)
+
+	freed := s.slotFreed()
+	s.returnSlot(models.ToolNmap)
+	<-freed
+	waitForStatus(t, s, second.ID, models.JobStatusRunning)
+	assert.False(t, s.borrowSlot(models.ToolNmap), "the queued job took the slot given back")
+
+	close(release)
+	waitForStatus(t, s, first.ID, models.JobStatusCompleted)
+	waitForStatus(t, s, second.ID, models.JobStatusCompleted)
+}
+
+func TestResumeReattachesDaemonScansAndInterruptsLocalTools(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	started := time.Now().Add(-time.Minute)
+	for _, job := range []*models.Job{
+		{ID: "local", Tool: models.ToolNmap, Target: "a", Status: models.JobStatusRunning, CreatedAt: started, StartedAt: &started},
+		{ID: "daemon", Tool: models.ToolZap, Target: "b", Status: models.JobStatusRunning, CreatedAt: started, StartedAt: &started,
+			Refs: map[string]string{"ascan_id": "7"}},
+		{ID: "waiting", Tool: models.ToolNmap, Target: "c", Status: models.JobStatusQueued, CreatedAt: started},
+	} {
+		require.NoError(t, repo.SaveJob(context.Background(), job))
+	}
+
+	s := NewJobService(repo, DefaultPoolConfig())
+	s.Register(models.ToolNmap, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return "ran " + run.Target(), nil
+	})
+	s.RegisterResumable(models.ToolZap, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return "attached to " + jobRef(ctx, "ascan_id"), nil
+	})
+	require.NoError(t, s.Resume())
+
+	waitForStatus(t, s, "daemon", models.JobStatusCompleted)
+	waitForStatus(t, s, "waiting", models.JobStatusCompleted)
+	daemon, err := s.Get("daemon")
+	require.NoError(t, err)
+	result, err := json.Marshal(daemon.Result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `"attached to 7"`, string(result))
+	assert.WithinDuration(t, started, *daemon.StartedAt, time.Millisecond)
+
+	local, err := s.Get("local")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusInterrupted, local.Status)
+
+	_, err = s.Requeue("daemon")
+	assert.ErrorIs(t, err, ErrJobNotInterrupted)
+
+	requeued, err := s.Requeue("local")
+	require.NoError(t, err)
+	assert.Equal(t, "local", requeued.ID)
+	waitForStatus(t, s, "local", models.JobStatusCompleted)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"napscan-be/internal/models"
+)
+
+// nmapNeedsRoot is what nmap prints when a scan needs raw sockets it cannot open,
+// e.g. "You requested a scan type which requires root privileges."
+const nmapNeedsRoot = "requires root privileges"
+
+// nmapNoRawSockets explains the warnings of scans that fell back to what nmap can do
+// without raw sockets
+const nmapNoRawSockets = "nmap has no raw socket access (root or CAP_NET_RAW)"
+
+// DetectPrivileges finds out whether nmap can open raw sockets by asking it for a SYN
+// scan of one local port. Without them, jobs fall back to TCP connect scans and skip
+// UDP and OS detection. NmapConfig.Privileged, when set, is taken as is.
+func (s *NmapService) DetectPrivileges(ctx context.Context) bool {
+	if s.cfg.Privileged != nil {
+		return *s.cfg.Privileged
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	out, err := newToolCommand(ctx, "nmap", "-sS", "-n", "-Pn", "-p", "1", "--max-retries", "0", "-oX", "-", "--", "127.0.0.1").CombinedOutput()
+	switch {
+	case err == nil:
+		s.privileged.Store(true)
+	case strings.Contains(string(out), nmapNeedsRoot):
+		log.Printf("%s, nmap jobs will run TCP connect scans without UDP and OS detection", nmapNoRawSockets)
+		s.privileged.Store(false)
+	default:
+		// Most likely nmap is missing, which the jobs will report themselves
+		log.Printf("Failed to detect nmap privileges: %v, output: %s", err, strings.TrimSpace(string(out)))
+	}
+	return s.privileged.Load()
+}
+
+// Privileged reports whether nmap jobs run with raw sockets
+func (s *NmapService) Privileged() bool {
+	return s.privileged.Load()
+}
+
+// nmapUnprivileged turns a profile into what nmap can scan without raw sockets: a TCP
+// connect scan instead of the raw techniques, without UDP and OS detection. The
+// warnings say what was changed.
+func nmapUnprivileged(settings models.NmapProfileSettings) (models.NmapProfileSettings, []string) {
+	var warnings []string
+	if settings.TCPTechnique != "connect" {
+		technique := settings.TCPTechnique
+		if technique == "" {
+			technique = "syn"
+		}
+		warnings = append(warnings, fmt.Sprintf("%s: TCP connect scan (-sT) instead of %s scan", nmapNoRawSockets, technique))
+		settings.TCPTechnique = "connect"
+	}
+	if settings.UDP {
+		warnings = append(warnings, nmapNoRawSockets+": UDP scan skipped")
+		settings.UDP, settings.UDPPorts = false, ""
+	}
+	if settings.OSDetection {
+		warnings = append(warnings, nmapNoRawSockets+": OS detection skipped")
+		settings.OSDetection = false
+	}
+	return settings, warnings
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+)
+
+var (
+	ErrNmapProfileNotFound = errors.New("nmap profile not found")
+	ErrInvalidNmapProfile  = errors.New("invalid nmap profile")
+	// ErrNmapProfileConflict is returned when creating a profile under a taken name
+	// and when changing a built-in profile
+	ErrNmapProfileConflict = errors.New("nmap profile conflict")
+)
+
+// nmapDefaultUDPPorts are DNS, DHCP, TFTP, NTP, SNMP, IKE, SSDP and IPsec NAT-T
+const nmapDefaultUDPPorts = "53,67,68,69,123,161,500,1900,4500"
+
+// nmapTCPTechniques maps the TCP scan techniques a profile may pick to their flag
+var nmapTCPTechniques = map[string]string{
+	"syn":     "-sS",
+	"connect": "-sT",
+	"ack":     "-sA",
+	"window":  "-sW",
+	"maimon":  "-sM",
+	"fin":     "-sF",
+	"null":    "-sN",
+	"xmas":    "-sX",
+}
+
+// nmapScriptCategories are the NSE script categories nmap ships with
+var nmapScriptCategories = map[string]bool{
+	"auth": true, "broadcast": true, "brute": true, "default": true, "discovery": true,
+	"dos": true, "exploit": true, "external": true, "fuzzer": true, "intrusive": true,
+	"malware": true, "safe": true, "version": true, "vuln": true,
+}
+
+var nmapProfileNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// builtinNmapProfiles are always available. "default" is what nmap jobs ran
+// before profiles existed.
+var builtinNmapProfiles = []models.NmapProfile{
+	{
+		Name:        models.NmapProfileDefault,
+		Description: "Service detection on the 1000 most common TCP ports and a few common UDP services",
+		NmapProfileSettings: models.NmapProfileSettings{
+			UDP:              true,
+			UDPPorts:         nmapDefaultUDPPorts,
+			Timing:           intRef(4),
+			ServiceDetection: true,
+		},
+	},
+	{
+		Name:        "quick",
+		Description: "Light service detection on the 100 most common TCP ports, no UDP",
+		NmapProfileSettings: models.NmapProfileSettings{
+			TopPorts:         100,
+			Timing:           intRef(4),
+			ServiceDetection: true,
+			VersionIntensity: intRef(2),
+		},
+	},
+	{
+		Name:        "full",
+		Description: "Service detection and the default scripts on every TCP port, plus a few common UDP services",
+		NmapProfileSettings: models.NmapProfileSettings{
+			Ports:            "1-65535",
+			UDP:              true,
+			UDPPorts:         nmapDefaultUDPPorts,
+			Timing:           intRef(4),
+			ServiceDetection: true,
+			ScriptCategories: []string{"default"},
+		},
+	},
+}
+
+// NmapProfileService manages the named sets of nmap settings jobs select with the
+// "profile" option. The built-in profiles are read-only; admins add their own.
+type NmapProfileService struct {
+	repo repository.NmapProfileRepository
+}
+
+func NewNmapProfileService(repo repository.NmapProfileRepository) *NmapProfileService {
+	return &NmapProfileService{repo: repo}
+}
+
+// List returns the built-in profiles followed by the stored ones by name
+func (s *NmapProfileService) List() ([]*models.NmapProfile, error) {
+	stored, err := s.repo.ListNmapProfiles(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]*models.NmapProfile, 0, len(builtinNmapProfiles)+len(stored))
+	for _, p := range builtinNmapProfiles {
+		profiles = append(profiles, builtinProfile(p))
+	}
+	return append(profiles, stored...), nil
+}
+
+// Get returns a profile by name
+func (s *NmapProfileService) Get(name string) (*models.NmapProfile, error) {
+	for _, p := range builtinNmapProfiles {
+		if p.Name == name {
+			return builtinProfile(p), nil
+		}
+	}
+	profile, err := s.repo.GetNmapProfile(context.Background(), name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %q", ErrNmapProfileNotFound, name)
+	}
+	return profile, err
+}
+
+// Create validates req and stores a new profile for userID
+func (s *NmapProfileService) Create(userID string, req models.NmapProfileRequest) (*models.NmapProfile, error) {
+	name := strings.TrimSpace(req.Name)
+	if !nmapProfileNameRe.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be 1-64 lowercase letters, digits, - or _", ErrInvalidNmapProfile)
+	}
+	if _, err := s.Get(name); err == nil {
+		return nil, fmt.Errorf("%w: %q already exists", ErrNmapProfileConflict, name)
+	} else if !errors.Is(err, ErrNmapProfileNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	profile := &models.NmapProfile{
+		Name:      name,
+		CreatedBy: userID,
+		CreatedAt: now,
+	}
+	if err := s.save(profile, req, now); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Update replaces the settings of a stored profile
+func (s *NmapProfileService) Update(name string, req models.NmapProfileRequest) (*models.NmapProfile, error) {
+	profile, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Builtin {
+		return nil, fmt.Errorf("%w: built-in profile %q cannot be changed", ErrNmapProfileConflict, name)
+	}
+	if err := s.save(profile, req, time.Now()); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Delete removes a stored profile. Schedules and scans that select it fail to queue
+// nmap until they pick another one.
+func (s *NmapProfileService) Delete(name string) error {
+	if isBuiltinNmapProfile(name) {
+		return fmt.Errorf("%w: built-in profile %q cannot be deleted", ErrNmapProfileConflict, name)
+	}
+	err := s.repo.DeleteNmapProfile(context.Background(), name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %q", ErrNmapProfileNotFound, name)
+	}
+	return err
+}
+
+// ValidateOptions checks the options of an nmap job: the only one is "profile",
+// which must name an existing profile
+func (s *NmapProfileService) ValidateOptions(options map[string]string) error {
+	for key := range options {
+		if key != "profile" {
+			return fmt.Errorf("unknown option %q", key)
+		}
+	}
+	_, err := s.Resolve(options)
+	return err
+}
+
+// Resolve returns the profile selected by the options of an nmap job, the default
+// one when none is
+func (s *NmapProfileService) Resolve(options map[string]string) (*models.NmapProfile, error) {
+	name := strings.TrimSpace(options["profile"])
+	if name == "" {
+		name = models.NmapProfileDefault
+	}
+	return s.Get(name)
+}
+
+// save validates req, copies it onto profile and stores it
+func (s *NmapProfileService) save(profile *models.NmapProfile, req models.NmapProfileRequest, now time.Time) error {
+	settings := req.NmapProfileSettings
+	if err := normalizeNmapSettings(&settings); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidNmapProfile, err)
+	}
+	profile.Description = strings.TrimSpace(req.Description)
+	profile.NmapProfileSettings = settings
+	profile.UpdatedAt = now
+
+	if err := s.repo.SaveNmapProfile(context.Background(), profile); err != nil {
+		return fmt.Errorf("failed to save nmap profile: %w", err)
+	}
+	return nil
+}
+
+// normalizeNmapSettings validates settings and brings them into canonical form
+func normalizeNmapSettings(settings *models.NmapProfileSettings) error {
+	settings.Ports = strings.ReplaceAll(settings.Ports, " ", "")
+	settings.UDPPorts = strings.ReplaceAll(settings.UDPPorts, " ", "")
+	settings.TCPTechnique = strings.ToLower(strings.TrimSpace(settings.TCPTechnique))
+
+	if settings.Ports != "" && settings.TopPorts != 0 {
+		return errors.New("ports and top_ports are mutually exclusive")
+	}
+	if settings.Ports != "" {
+		if err := validatePortList(settings.Ports); err != nil {
+			return fmt.Errorf("ports: %v", err)
+		}
+	}
+	if settings.TopPorts < 0 || settings.TopPorts > 65535 {
+		return errors.New("top_ports must be between 1 and 65535")
+	}
+	if settings.TCPTechnique != "" && nmapTCPTechniques[settings.TCPTechnique] == "" {
+		return fmt.Errorf("unknown tcp_technique %q", settings.TCPTechnique)
+	}
+
+	if !settings.UDP && settings.UDPPorts != "" {
+		return errors.New("udp_ports needs udp")
+	}
+	if settings.UDP && settings.UDPPorts == "" {
+		settings.UDPPorts = nmapDefaultUDPPorts
+	}
+	if settings.UDPPorts != "" {
+		if err := validatePortList(settings.UDPPorts); err != nil {
+			return fmt.Errorf("udp_ports: %v", err)
+		}
+	}
+
+	if settings.Timing != nil && (*settings.Timing < 0 || *settings.Timing > 5) {
+		return errors.New("timing must be between 0 and 5")
+	}
+	if settings.VersionIntensity != nil {
+		if !settings.ServiceDetection {
+			return errors.New("version_intensity needs service_detection")
+		}
+		if *settings.VersionIntensity < 0 || *settings.VersionIntensity > 9 {
+			return errors.New("version_intensity must be between 0 and 9")
+		}
+	}
+
+	seen := make(map[string]bool, len(settings.ScriptCategories))
+	categories := make([]string, 0, len(settings.ScriptCategories))
+	for _, category := range settings.ScriptCategories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if !nmapScriptCategories[category] {
+			return fmt.Errorf("unknown script category %q", category)
+		}
+		if !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	settings.ScriptCategories = categories
+	if len(categories) == 0 {
+		settings.ScriptCategories = nil
+	}
+	return nil
+}
+
+// validatePortList accepts comma separated ports and ranges such as "22,80,8000-8100"
+func validatePortList(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		if !isRange {
+			to = from
+		}
+		lo, err := parsePort(from)
+		if err != nil {
+			return err
+		}
+		hi, err := parsePort(to)
+		if err != nil {
+			return err
+		}
+		if lo > hi {
+			return fmt.Errorf("range %q is reversed", item)
+		}
+	}
+	return nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%q is not a port between 1 and 65535", s)
+	}
+	return port, nil
+}
+
+// nmapProfileArgs turns a profile into the flags of its TCP scan and, when the
+// profile scans UDP, of its UDP scan. Only validated settings reach the command line.
+func nmapProfileArgs(settings models.NmapProfileSettings) (tcp, udp []string) {
+	timing := nmapTimingArgs(settings)
+
+	if flag := nmapTCPTechniques[settings.TCPTechnique]; flag != "" {
+		tcp = append(tcp, flag)
+	}
+	tcp = append(tcp, timing...)
+	switch {
+	case settings.Ports != "":
+		tcp = append(tcp, "-p", settings.Ports)
+	case settings.TopPorts > 0:
+		tcp = append(tcp, "--top-ports", strconv.Itoa(settings.TopPorts))
+	}
+	if settings.ServiceDetection {
+		tcp = append(tcp, "-sV")
+		if settings.VersionIntensity != nil {
+			tcp = append(tcp, "--version-intensity", strconv.Itoa(*settings.VersionIntensity))
+		}
+	}
+	if settings.OSDetection {
+		tcp = append(tcp, "-O")
+	}
+	if len(settings.ScriptCategories) > 0 {
+		tcp = append(tcp, "--script", strings.Join(settings.ScriptCategories, ","))
+	}
+
+	if settings.UDP {
+		udp = append(append([]string{"-sU"}, timing...), "-p", settings.UDPPorts)
+	}
+	return tcp, udp
+}
+
+// nmapTimingArgs is the -T flag of a profile, if it sets a timing template
+func nmapTimingArgs(settings models.NmapProfileSettings) []string {
+	if settings.Timing == nil {
+		return nil
+	}
+	return []string{"-T" + strconv.Itoa(*settings.Timing)}
+}
+
+func builtinProfile(p models.NmapProfile) *models.NmapProfile {
+	p.Builtin = true
+	return &p
+}
+
+func isBuiltinNmapProfile(name string) bool {
+	for _, p := range builtinNmapProfiles {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func intRef(n int) *int {
+	return &n
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNmapProfiles(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	profiles := NewNmapProfileService(repo)
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	jobs.Register(models.ToolNmap, func(ctx context.Context, run *JobRun) (interface{}, error) { return nil, nil })
+	jobs.RegisterOptions(models.ToolNmap, profiles.ValidateOptions)
+
+	// The default profile runs what nmap jobs always ran
+	def, err := profiles.Resolve(nil)
+	require.NoError(t, err)
+	assert.True(t, def.Builtin)
+	tcp, udp := nmapProfileArgs(def.NmapProfileSettings)
+	assert.Equal(t, []string{"-T4", "-sV"}, tcp)
+	assert.Equal(t, []string{"-sU", "-T4", "-p", "53,67,68,69,123,161,500,1900,4500"}, udp)
+
+	for _, settings := range []models.NmapProfileSettings{
+		{Ports: "1-100 --script=exploit"},
+		{Ports: "80", TopPorts: 10},
+		{Ports: "100-1"},
+		{TCPTechnique: "idle"},
+		{Timing: intRef(6)},
+		{VersionIntensity: intRef(5)},
+		{UDPPorts: "53"},
+		{ScriptCategories: []string{"vuln", "http-*"}},
+	} {
+		_, err := profiles.Create("admin", models.NmapProfileRequest{Name: "bad", NmapProfileSettings: settings})
+		assert.ErrorIs(t, err, ErrInvalidNmapProfile, "%+v", settings)
+	}
+	_, err = profiles.Create("admin", models.NmapProfileRequest{Name: "Web Ports"})
+	assert.ErrorIs(t, err, ErrInvalidNmapProfile)
+	_, err = profiles.Create("admin", models.NmapProfileRequest{Name: "quick"})
+	assert.ErrorIs(t, err, ErrNmapProfileConflict)
+
+	web, err := profiles.Create("admin", models.NmapProfileRequest{Name: "web", NmapProfileSettings: models.NmapProfileSettings{
+		Ports:            "80, 443,8000-8100",
+		TCPTechnique:     "Connect",
+		UDP:              true,
+		Timing:           intRef(3),
+		ServiceDetection: true,
+		VersionIntensity: intRef(9),
+		OSDetection:      true,
+		ScriptCategories: []string{"vuln", "default", "vuln"},
+	}})
+	require.NoError(t, err)
+	tcp, udp = nmapProfileArgs(web.NmapProfileSettings)
+	assert.Equal(t, []string{"-sT", "-T3", "-p", "80,443,8000-8100", "-sV", "--version-intensity", "9", "-O", "--script", "default,vuln"}, tcp)
+	assert.Equal(t, []string{"-sU", "-T3", "-p", nmapDefaultUDPPorts}, udp)
+
+	list, err := profiles.List()
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	assert.Equal(t, "web", list[3].Name)
+
+	// Jobs select profiles by name and nothing else
+	_, err = jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.5", Options: map[string]string{"profile": "web"}})
+	assert.NoError(t, err)
+	_, err = jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.5", Options: map[string]string{"profile": "missing"}})
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+	_, err = jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.5", Options: map[string]string{"args": "--script=exploit"}})
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+
+	_, err = profiles.Update("default", models.NmapProfileRequest{})
+	assert.ErrorIs(t, err, ErrNmapProfileConflict)
+	assert.ErrorIs(t, profiles.Delete("full"), ErrNmapProfileConflict)
+	require.NoError(t, profiles.Delete("web"))
+	_, err = profiles.Get("web")
+	assert.ErrorIs(t, err, ErrNmapProfileNotFound)
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"napscan-be/internal/models"
+)
+
+// nmapDiscoveryShare is the part of the progress of a range scan taken by host discovery
+const nmapDiscoveryShare = 10
+
+// NmapConfig controls how nmap scans networks
+type NmapConfig struct {
+	// ShardSize is the number of live hosts one nmap process scans
+	ShardSize int
+	// ShardParallel is how many shards of a job run at the same time. The first runs
+	// on the job's own nmap worker slot, each other one borrows a free nmap slot from
+	// the job pool. Across all jobs at most the nmap tool limit of shards and single
+	// host scans run at once, each as a TCP and, with UDP, a UDP nmap process.
+	ShardParallel int
+	// MaxRangeAddresses is the size of the largest network a job may sweep
+	MaxRangeAddresses int
+	// Privileged says whether nmap may open raw sockets, which SYN and UDP scans and
+	// OS detection need. It is detected at startup when nil. When it is set to true
+	// nmap is run with --privileged, for an nmap given CAP_NET_RAW with setcap.
+	Privileged *bool
+}
+
+// DefaultNmapConfig scans 16 hosts per shard, up to 4 shards at a time, in networks
+// of up to a /16
+func DefaultNmapConfig() NmapConfig {
+	return NmapConfig{
+		ShardSize:         16,
+		ShardParallel:     4,
+		MaxRangeAddresses: 1 << 16,
+	}
+}
+
+// NmapConfigFromEnv reads NAPSCAN_NMAP_SHARD_SIZE, NAPSCAN_NMAP_SHARD_PARALLEL,
+// NAPSCAN_NMAP_MAX_RANGE and NAPSCAN_NMAP_PRIVILEGED on top of DefaultNmapConfig
+func NmapConfigFromEnv() NmapConfig {
+	cfg := DefaultNmapConfig()
+
+	limits := map[string]*int{
+		"NAPSCAN_NMAP_SHARD_SIZE":     &cfg.ShardSize,
+		"NAPSCAN_NMAP_SHARD_PARALLEL": &cfg.ShardParallel,
+		"NAPSCAN_NMAP_MAX_RANGE":      &cfg.MaxRangeAddresses,
+	}
+	for name, target := range limits {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Printf("Ignoring invalid %s %q", name, v)
+				continue
+			}
+			*target = n
+		}
+	}
+
+	if v := strings.TrimSpace(os.Getenv("NAPSCAN_NMAP_PRIVILEGED")); v != "" {
+		privileged, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("Ignoring invalid NAPSCAN_NMAP_PRIVILEGED %q", v)
+		} else {
+			cfg.Privileged = &privileged
+		}
+	}
+
+	return cfg
+}
+
+// scanRange sweeps a network for live hosts with a ping scan (nmap uses ARP on the
+// local segment when it has raw socket access) and scans the hosts that answered in
+// shards of cfg.ShardSize, up to cfg.ShardParallel at a time as the job pool lends
+// nmap slots. The shards are merged into one report per protocol; the progress of
+// each shard is kept on the job.
+func (s *NmapService) scanRange(ctx context.Context, target *Target, profile models.NmapProfileSettings) (*CombinedScanResponse, error) {
+	if bits := target.prefix.Addr().BitLen() - target.prefix.Bits(); bits >= 31 || 1<<bits > s.cfg.MaxRangeAddresses {
+		return nil, fmt.Errorf("%w: %s has more than %d addresses", ErrInvalidTarget, target, s.cfg.MaxRangeAddresses)
+	}
+
+	discoveryCtx := withProgress(ctx, func(percent int, message string) {
+		reportProgress(ctx, percent*nmapDiscoveryShare/100, "Host discovery: "+message)
+	})
+	discovery, err := s.ExecuteScan(discoveryCtx, []string{target.Hosts()}, "discovery", append([]string{"-sn"}, nmapTimingArgs(profile)...)...)
+	if err != nil {
+		return nil, fmt.Errorf("host discovery error: %w", err)
+	}
+
+	live := liveHosts(discovery)
+	shards := shardHosts(live, s.cfg.ShardSize)
+	reportProgress(ctx, nmapDiscoveryShare, fmt.Sprintf("%d hosts up, %d shards", len(live), len(shards)))
+
+	tcpArgs, udpArgs := nmapProfileArgs(profile)
+	// Discovery found the hosts already, the shards do not ping them again
+	tcpArgs = append(tcpArgs, "-Pn")
+	if udpArgs != nil {
+		udpArgs = append(udpArgs, "-Pn")
+	}
+
+	// A failing shard aborts the others, like a failing half of a single host scan
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		progress = make([]int, len(shards))
+		results  = make([]*CombinedScanResponse, len(shards))
+		errs     = make([]error, len(shards))
+		failed   = -1 // the shard that aborted the others
+		parallel = make(chan struct{}, s.cfg.ShardParallel)
+		own      = make(chan struct{}, 1) // the job's own worker slot
+		wg       sync.WaitGroup
+	)
+	jobShards := make([]models.JobShard, len(shards))
+	for i, hosts := range shards {
+		jobShards[i] = models.JobShard{
+			Index:  i + 1,
+			Hosts:  len(hosts),
+			From:   hosts[0],
+			To:     hosts[len(hosts)-1],
+			Status: models.JobStatusQueued,
+		}
+	}
+	reportShards(ctx, jobShards)
+
+	for i, hosts := range shards {
+		release, err := shardSlot(ctx, parallel, own)
+		if err != nil {
+			// The shards that did not start yet will not
+			mu.Lock()
+			for _, shard := range jobShards[i:] {
+				shard.Status = models.JobStatusCancelled
+				reportShard(ctx, shard)
+			}
+			mu.Unlock()
+			errs[i] = err
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, hosts []string, shard models.JobShard) {
+			defer wg.Done()
+			defer release()
+
+			shard.Status = models.JobStatusRunning
+			reportShard(ctx, shard)
+			shardCtx := withProgress(ctx, func(percent int, message string) {
+				mu.Lock()
+				progress[i] = percent
+				total := 0
+				for _, p := range progress {
+					total += p
+				}
+				shard.Progress, shard.Message = percent, message
+				reportShard(ctx, shard)
+				mu.Unlock()
+				reportProgress(ctx, nmapDiscoveryShare+total*(100-nmapDiscoveryShare)/(100*len(progress)),
+					fmt.Sprintf("Shard %d/%d %s", i+1, len(shards), message))
+			})
+
+			res, err := s.scanHosts(shardCtx, hosts, tcpArgs, udpArgs, "_shard"+strconv.Itoa(i+1))
+			mu.Lock()
+			switch {
+			case err == nil:
+				progress[i] = 100
+				shard.Status, shard.Progress, shard.Message = models.JobStatusCompleted, 100, ""
+			case ctx.Err() != nil:
+				shard.Status = models.JobStatusCancelled
+			default:
+				shard.Status, shard.Error = models.JobStatusFailed, err.Error()
+				failed = i
+				cancel()
+			}
+			reportShard(ctx, shard)
+			mu.Unlock()
+			results[i], errs[i] = res, err
+		}(i, hosts, jobShards[i])
+	}
+	wg.Wait()
+
+	// Report the shard that failed rather than the ones it aborted
+	if failed >= 0 {
+		return nil, fmt.Errorf("shard %d: %w", failed+1, errs[failed])
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i+1, err)
+		}
+	}
+
+	var tcpRuns, udpRuns []models.NmapRun
+	for _, res := range results {
+		tcpRuns = append(tcpRuns, *res.TCP)
+		if res.UDP != nil {
+			udpRuns = append(udpRuns, *res.UDP)
+		}
+	}
+	combined := &CombinedScanResponse{TCP: mergeNmapRuns(discovery, tcpRuns)}
+	if profile.UDP {
+		combined.UDP = mergeNmapRuns(discovery, udpRuns)
+	}
+	return combined, nil
+}
+
+// shardSlot waits until another shard may start: fewer than cap(parallel) shards
+// run, and the job's own worker slot is free or the job pool lends another one. It
+// returns the function that gives the slots back.
+func shardSlot(ctx context.Context, parallel, own chan struct{}) (func(), error) {
+	select {
+	case parallel <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		// Taken before borrowing, so a slot released in between is not missed
+		freed := jobSlotFreed(ctx)
+		select {
+		case own <- struct{}{}:
+			return func() { <-own; <-parallel }, nil
+		default:
+		}
+		if release, ok := borrowJobSlot(ctx); ok {
+			return func() { release(); <-parallel }, nil
+		}
+
+		select {
+		case own <- struct{}{}:
+			return func() { <-own; <-parallel }, nil
+		case <-freed:
+		case <-ctx.Done():
+			<-parallel
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// liveHosts returns the addresses of the hosts a ping scan found up, in address order
+func liveHosts(discovery models.NmapRun) []string {
+	var addrs []netip.Addr
+	seen := make(map[netip.Addr]bool)
+	for _, host := range discovery.Hosts {
+		if host.Status.State != "up" {
+			continue
+		}
+		for _, a := range host.Addresses {
+			if a.AddrType != "ipv4" && a.AddrType != "ipv6" {
+				continue
+			}
+			addr, err := netip.ParseAddr(a.Addr)
+			if err == nil && !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+			break
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+
+	hosts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = addr.String()
+	}
+	return hosts
+}
+
+// shardHosts splits hosts into consecutive groups of at most size
+func shardHosts(hosts []string, size int) [][]string {
+	var shards [][]string
+	for len(hosts) > 0 {
+		n := size
+		if n > len(hosts) {
+			n = len(hosts)
+		}
+		shards = append(shards, hosts[:n])
+		hosts = hosts[n:]
+	}
+	return shards
+}
+
+// mergeNmapRuns combines the reports of the shards of a range scan into one report.
+// It starts when discovery did and counts the hosts discovery found; Args and
+// ScanInfo are those of the first shard, which all shards share.
+func mergeNmapRuns(discovery models.NmapRun, runs []models.NmapRun) *models.NmapRun {
+	merged := models.NmapRun{
+		Scanner:          discovery.Scanner,
+		Args:             discovery.Args,
+		Version:          discovery.Version,
+		XMLOutputVersion: discovery.XMLOutputVersion,
+		Start:            discovery.Start,
+		StartStr:         discovery.StartStr,
+		Hosts:            []models.Host{},
+		RunStats:         discovery.RunStats,
+	}
+	if len(runs) > 0 {
+		merged.Args = runs[0].Args
+		merged.ScanInfo = runs[0].ScanInfo
+	}
+
+	for _, run := range runs {
+		merged.PreScripts = append(merged.PreScripts, run.PreScripts...)
+		merged.Hosts = append(merged.Hosts, run.Hosts...)
+		merged.PostScripts = append(merged.PostScripts, run.PostScripts...)
+
+		finished := run.RunStats.Finished
+		if finished.Time > merged.RunStats.Finished.Time {
+			merged.RunStats.Finished.Time = finished.Time
+			merged.RunStats.Finished.TimeStr = finished.TimeStr
+		}
+		if finished.Exit != "" && finished.Exit != "success" {
+			merged.RunStats.Finished.Exit = finished.Exit
+			merged.RunStats.Finished.ErrorMsg = finished.ErrorMsg
+		}
+	}
+	sort.SliceStable(merged.Hosts, func(i, j int) bool {
+		a, errA := netip.ParseAddr(hostTarget(merged.Hosts[i]))
+		b, errB := netip.ParseAddr(hostTarget(merged.Hosts[j]))
+		return errA == nil && errB == nil && a.Less(b)
+	})
+
+	finished := &merged.RunStats.Finished
+	if merged.Start > 0 && finished.Time >= merged.Start {
+		finished.Elapsed = float64(finished.Time - merged.Start)
+	}
+	hosts := merged.RunStats.Hosts
+	finished.Summary = fmt.Sprintf("Nmap done at %s; %d IP addresses (%d hosts up) scanned in %.2f seconds in %d shards",
+		time.Unix(finished.Time, 0).Format(time.ANSIC), hosts.Total, hosts.Up, finished.Elapsed, len(runs))
+	return &merged
+}
@@ -2,20 +2,33 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"log"
-	"os/exec"
+	"net/netip"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"napscan-be/internal/models"
 )
 
-type NmapService struct{}
+// NmapService runs nmap with the flags of a profile
+type NmapService struct {
+	profiles *NmapProfileService
+	cfg      NmapConfig
+	// privileged is whether nmap can open raw sockets, see DetectPrivileges
+	privileged atomic.Bool
+}
 
-func NewNmapService() *NmapService {
-	return &NmapService{}
+func NewNmapService(profiles *NmapProfileService, cfg NmapConfig) *NmapService {
+	s := &NmapService{profiles: profiles, cfg: cfg}
+	s.privileged.Store(cfg.Privileged == nil || *cfg.Privileged)
+	return s
 }
 
 type ScanResult struct {
@@ -26,16 +39,30 @@ type ScanResult struct {
 type CombinedScanResponse struct {
 	TCP *models.NmapRun `json:"tcp"`
 	UDP *models.NmapRun `json:"udp"`
+	// Warnings says what the scan skipped or changed, e.g. without raw sockets
+	Warnings []string `json:"warnings,omitempty"`
 }
 
-func (s *NmapService) ExecuteScan(target string, scanType string, args ...string) (models.NmapRun, error) {
-	baseArgs := append([]string{scanType,"-n", "-T4", "-oX", "-"}, args...)
-	baseArgs = append(baseArgs, target)
+// ExecuteScan runs nmap against hosts and records its XML output as nmap_<name>.xml.
+// The hosts come from a parsed Target or from nmap's own output.
+func (s *NmapService) ExecuteScan(ctx context.Context, hosts []string, name string, args ...string) (models.NmapRun, error) {
+	baseArgs := append([]string{"-n", "-oX", "-", "--stats-every", "5s"}, args...)
+	if s.cfg.Privileged != nil && *s.cfg.Privileged && s.privileged.Load() {
+		baseArgs = append(baseArgs, "--privileged")
+	}
+	if nmapIPv6(hosts) {
+		baseArgs = append(baseArgs, "-6")
+	}
+	baseArgs = append(append(baseArgs, "--"), hosts...)
 
-	cmd := exec.Command("nmap", baseArgs...)
+	cmd := newToolCommand(ctx, "nmap", baseArgs...)
 
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	cmd.Stdout = io.MultiWriter(&stdout, newLineWriter(func(line string) {
+		if task, percent, ok := parseNmapTaskProgress(line); ok {
+			reportProgress(ctx, percent, fmt.Sprintf("%s %d%%", task, percent))
+		}
+	}))
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
@@ -47,15 +74,89 @@ func (s *NmapService) ExecuteScan(target string, scanType string, args ...string
 		return models.NmapRun{}, err
 	}
 
+	recordOutput(ctx, "nmap_"+name+".xml", "application/xml", stdout.Bytes())
+	return parseNmapXML(stdout.Bytes())
+}
+
+// parseNmapXML reads an nmap XML report (-oX)
+func parseNmapXML(data []byte) (models.NmapRun, error) {
 	var result models.NmapRun
-	if err := xml.Unmarshal(stdout.Bytes(), &result); err != nil {
+	if err := xml.Unmarshal(data, &result); err != nil {
 		return models.NmapRun{}, err
 	}
-
 	return result, nil
 }
 
-func (s *NmapService) RunParallelScan(target string) (*CombinedScanResponse, error) {
+// RunParallelScan runs the TCP scan of profile and, when it has one, its UDP scan
+// next to it. UDP is nil in the response when the profile skips UDP. Networks are
+// swept for live hosts first, see scanRange. Without raw sockets the profile is
+// scaled down to a TCP connect scan, and the response warns about it.
+func (s *NmapService) RunParallelScan(ctx context.Context, target *Target, profile models.NmapProfileSettings) (*CombinedScanResponse, error) {
+	var warnings []string
+	fallback := !s.privileged.Load()
+	if fallback {
+		profile, warnings = nmapUnprivileged(profile)
+	}
+
+	res, err := s.runScan(ctx, target, profile)
+	// nmap may have lost its privileges since they were detected
+	if err != nil && !fallback && ctx.Err() == nil && strings.Contains(err.Error(), nmapNeedsRoot) {
+		log.Printf("%s, falling back to TCP connect scans: %v", nmapNoRawSockets, err)
+		s.privileged.Store(false)
+		profile, warnings = nmapUnprivileged(profile)
+		res, err = s.runScan(ctx, target, profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Warnings = warnings
+	return res, nil
+}
+
+func (s *NmapService) runScan(ctx context.Context, target *Target, profile models.NmapProfileSettings) (*CombinedScanResponse, error) {
+	if target.Kind == TargetCIDR {
+		return s.scanRange(ctx, target, profile)
+	}
+	tcpArgs, udpArgs := nmapProfileArgs(profile)
+	return s.scanHosts(ctx, []string{target.Hosts()}, tcpArgs, udpArgs, "")
+}
+
+// scanHosts runs the TCP scan and the optional UDP scan of hosts in parallel.
+// suffix tells the outputs of the shards of a range scan apart.
+func (s *NmapService) scanHosts(ctx context.Context, hosts []string, tcpArgs, udpArgs []string, suffix string) (*CombinedScanResponse, error) {
+	if udpArgs == nil {
+		result, err := s.ExecuteScan(withProgress(ctx, func(percent int, message string) {
+			reportProgress(ctx, percent, "TCP: "+message)
+		}), hosts, "tcp"+suffix, tcpArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("TCP scan error: %w", err)
+		}
+		return &CombinedScanResponse{TCP: &result}, nil
+	}
+
+	// A failing half aborts the other one instead of letting it run to completion
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each half reports its own progress, the job shows the average
+	var (
+		progressMu sync.Mutex
+		progress   [2]int
+		// failed is the half that aborted the other, whose error is only that it was killed
+		failed = -1
+	)
+	halfProgress := func(half int, label string) context.Context {
+		return withProgress(ctx, func(percent int, message string) {
+			progressMu.Lock()
+			progress[half] = percent
+			total := (progress[0] + progress[1]) / 2
+			progressMu.Unlock()
+			reportProgress(ctx, total, label+": "+message)
+		})
+	}
+	tcpCtx := halfProgress(0, "TCP")
+	udpCtx := halfProgress(1, "UDP")
+
 	var wg sync.WaitGroup
 	tcpChan := make(chan ScanResult, 1)
 	udpChan := make(chan ScanResult, 1)
@@ -64,13 +165,29 @@ func (s *NmapService) RunParallelScan(target string) (*CombinedScanResponse, err
 
 	go func() {
 		defer wg.Done()
-		result, err := s.ExecuteScan(target, "-sV")
+		result, err := s.ExecuteScan(tcpCtx, hosts, "tcp"+suffix, tcpArgs...)
+		if err != nil {
+			progressMu.Lock()
+			if failed < 0 {
+				failed = 0
+			}
+			progressMu.Unlock()
+			cancel()
+		}
 		tcpChan <- ScanResult{Result: result, Err: err}
 	}()
 
 	go func() {
 		defer wg.Done()
-		result, err := s.ExecuteScan(target, "-sU", "-p", "53,67,68,69,123,161,500,1900,4500")
+		result, err := s.ExecuteScan(udpCtx, hosts, "udp"+suffix, udpArgs...)
+		if err != nil {
+			progressMu.Lock()
+			if failed < 0 {
+				failed = 1
+			}
+			progressMu.Unlock()
+			cancel()
+		}
 		udpChan <- ScanResult{Result: result, Err: err}
 	}()
 
@@ -81,6 +198,9 @@ func (s *NmapService) RunParallelScan(target string) (*CombinedScanResponse, err
 	tcpRes := <-tcpChan
 	udpRes := <-udpChan
 
+	if failed == 1 {
+		return nil, fmt.Errorf("UDP scan error: %w", udpRes.Err)
+	}
 	if tcpRes.Err != nil {
 		return nil, fmt.Errorf("TCP scan error: %w", tcpRes.Err)
 	}
@@ -94,3 +214,101 @@ func (s *NmapService) RunParallelScan(target string) (*CombinedScanResponse, err
 		UDP: &udpRes.Result,
 	}, nil
 }
+
+// nmapIPv6 reports whether hosts are IPv6 addresses or networks, which nmap only
+// scans with -6
+func nmapIPv6(hosts []string) bool {
+	for _, host := range hosts {
+		if prefix, err := netip.ParsePrefix(host); err == nil && prefix.Addr().Is6() {
+			return true
+		}
+		if addr, err := netip.ParseAddr(host); err == nil && addr.Is6() {
+			return true
+		}
+	}
+	return false
+}
+
+var nmapTaskProgressRe = regexp.MustCompile(`<taskprogress task="([^"]+)".*?percent="([\d.]+)"`)
+
+// parseNmapTaskProgress reads the <taskprogress> elements nmap writes into its XML
+// output with --stats-every. The percentage is for the current phase (e.g. "SYN Stealth Scan").
+func parseNmapTaskProgress(line string) (string, int, bool) {
+	m := nmapTaskProgressRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", 0, false
+	}
+	percent, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return m[1], int(percent), true
+}
+
+// RunJob runs the scan of the profile selected by the "profile" option as a background job
+func (s *NmapService) RunJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	// The profile was checked when the job was queued, but an admin may have deleted it since
+	profile, err := s.profiles.Resolve(map[string]string{"profile": run.Option("profile")})
+	if err != nil {
+		return nil, err
+	}
+	target, err := ParseTarget(run.Target())
+	if err != nil {
+		return nil, err
+	}
+	return s.RunParallelScan(ctx, target, profile.NmapProfileSettings)
+}
+
+// normalizeNmapResult reports every open port as an info finding, so the attack
+// surface shows up next to the vulnerabilities found on it
+func normalizeNmapResult(result interface{}) ([]models.Finding, error) {
+	res, err := decodeNmapResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []models.Finding{}
+	for _, scan := range []struct {
+		key string
+		run *models.NmapRun
+	}{{"tcp", res.TCP}, {"udp", res.UDP}} {
+		if scan.run == nil {
+			continue
+		}
+		for h, host := range scan.run.Hosts {
+			for p, port := range host.Ports.Ports {
+				if port.State.State != "open" {
+					continue
+				}
+				title := fmt.Sprintf("Open port %s/%s", port.PortID, port.Proto)
+				if port.Service.Name != "" {
+					title += " (" + port.Service.Name + ")"
+				}
+				findings = append(findings, models.Finding{
+					RuleID:   "open-port",
+					Title:    title,
+					Severity: models.SeverityInfo,
+					Host:     hostTarget(host),
+					Port:     port.PortID,
+					Evidence: serviceBanner(port.Service),
+					RawRef:   fmt.Sprintf("%s.hosts[%d].ports.ports[%d]", scan.key, h, p),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// serviceBanner joins what -sV identified, e.g. "OpenSSH 8.2p1 Ubuntu 4ubuntu0.5 (Ubuntu Linux; protocol 2.0)"
+func serviceBanner(svc models.Service) string {
+	var parts []string
+	for _, part := range []string{svc.Product, svc.Version} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if svc.ExtraInfo != "" {
+		parts = append(parts, "("+svc.ExtraInfo+")")
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nmapXML = `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap" args="nmap -sV -O -sC --traceroute -oX - 10.0.0.5" start="1718000000" startstr="Mon Jun 10 06:13:20 2024" version="7.94" xmloutputversion="1.05">
+<scaninfo type="syn" protocol="tcp" numservices="1000" services="1-1000"/>
+<host starttime="1718000001" endtime="1718000042">
+<status state="up" reason="echo-reply" reason_ttl="63"/>
+<address addr="10.0.0.5" addrtype="ipv4"/>
+<address addr="00:0C:29:AA:BB:CC" addrtype="mac" vendor="VMware"/>
+<hostnames><hostname name="app.example.com" type="PTR"/></hostnames>
+<ports>
+<extraports state="closed" count="998"><extrareasons reason="reset" count="998" proto="tcp" ports="1-21,23-442"/></extraports>
+<port protocol="tcp" portid="22"><state state="open" reason="syn-ack" reason_ttl="63"/>
+<service name="ssh" product="OpenSSH" version="8.2p1 Ubuntu 4ubuntu0.5" extrainfo="Ubuntu Linux; protocol 2.0" ostype="Linux" method="probed" conf="10"><cpe>cpe:/a:openbsd:openssh:8.2p1</cpe><cpe>cpe:/o:linux:linux_kernel</cpe></service>
+<script id="ssh-hostkey" output="&#xa;  3072 aa:bb (RSA)">
+<table><elem key="type">ssh-rsa</elem><elem key="bits">3072</elem></table>
+</script>
+</port>
+<port protocol="tcp" portid="443"><state state="open" reason="syn-ack" reason_ttl="63"/>
+<service name="http" product="nginx" tunnel="ssl" method="probed" conf="10"/>
+<script id="ssl-cert" output="Subject: commonName=app.example.com">
+<table key="subject"><elem key="commonName">app.example.com</elem></table>
+<table key="extensions"><table><elem key="name">X509v3 Subject Alternative Name</elem><elem key="value">DNS:app.example.com</elem></table></table>
+<elem key="sig_algo">sha256WithRSAEncryption</elem>
+</script>
+</port>
+</ports>
+<os><portused state="open" proto="tcp" portid="22"/>
+<osmatch name="Linux 4.15 - 5.8" accuracy="96" line="67000">
+<osclass type="general purpose" vendor="Linux" osfamily="Linux" osgen="4.X" accuracy="96"><cpe>cpe:/o:linux:linux_kernel:4</cpe></osclass>
+</osmatch>
+</os>
+<uptime seconds="86400" lastboot="Sun Jun  9 06:13:20 2024"/>
+<distance value="2"/>
+<hostscript><script id="clock-skew" output="mean: 0s"/></hostscript>
+<trace port="443" proto="tcp">
+<hop ttl="1" ipaddr="10.0.0.1" rtt="0.45"/>
+<hop ttl="2" ipaddr="10.0.0.5" rtt="0.90" host="app.example.com"/>
+</trace>
+<times srtt="900" rttvar="200" to="100000"/>
+</host>
+<runstats><finished time="1718000042" timestr="Mon Jun 10 06:14:02 2024" summary="Nmap done at Mon Jun 10 06:14:02 2024; 1 IP address (1 host up) scanned in 42.17 seconds" elapsed="42.17" exit="success"/><hosts up="1" down="0" total="1"/></runstats>
+</nmaprun>`
+
+func TestNmapXMLModel(t *testing.T) {
+	var run models.NmapRun
+	require.NoError(t, xml.Unmarshal([]byte(nmapXML), &run))
+
+	assert.Equal(t, "7.94", run.Version)
+	assert.Equal(t, int64(1718000000), run.Start)
+	assert.Equal(t, []models.ScanInfo{{Type: "syn", Protocol: "tcp", NumServices: 1000, Services: "1-1000"}}, run.ScanInfo)
+	assert.Equal(t, 42.17, run.RunStats.Finished.Elapsed)
+	assert.Equal(t, "success", run.RunStats.Finished.Exit)
+	assert.Equal(t, models.HostStats{Up: 1, Total: 1}, run.RunStats.Hosts)
+
+	require.Len(t, run.Hosts, 1)
+	host := run.Hosts[0]
+	assert.Equal(t, models.HostStatus{State: "up", Reason: "echo-reply", ReasonTTL: 63}, host.Status)
+	assert.Equal(t, int64(41), host.EndTime-host.StartTime)
+	assert.Equal(t, "VMware", host.Addresses[1].Vendor)
+	assert.Equal(t, []models.Hostname{{Name: "app.example.com", Type: "PTR"}}, host.Hostnames)
+	assert.Equal(t, []models.ExtraPorts{{State: "closed", Count: 998, Reasons: []models.ExtraReasons{{Reason: "reset", Count: 998}}}}, host.Ports.ExtraPorts)
+
+	ssh := host.Ports.Ports[0]
+	assert.Equal(t, models.State{State: "open", Reason: "syn-ack", ReasonTTL: 63}, ssh.State)
+	assert.Equal(t, "Ubuntu Linux; protocol 2.0", ssh.Service.ExtraInfo)
+	assert.Equal(t, []string{"cpe:/a:openbsd:openssh:8.2p1", "cpe:/o:linux:linux_kernel"}, ssh.Service.CPEs)
+	require.Len(t, ssh.Scripts, 1)
+	assert.Equal(t, "\n  3072 aa:bb (RSA)", ssh.Scripts[0].Output)
+	assert.Equal(t, []models.ScriptElem{{Key: "type", Value: "ssh-rsa"}, {Key: "bits", Value: "3072"}}, ssh.Scripts[0].Tables[0].Elements)
+
+	cert := host.Ports.Ports[1].Scripts[0]
+	assert.Equal(t, "ssl", host.Ports.Ports[1].Service.Tunnel)
+	assert.Equal(t, []models.ScriptElem{{Key: "sig_algo", Value: "sha256WithRSAEncryption"}}, cert.Elements)
+	assert.Equal(t, "subject", cert.Tables[0].Key)
+	assert.Equal(t, "DNS:app.example.com", cert.Tables[1].Tables[0].Elements[1].Value)
+
+	require.NotNil(t, host.OS)
+	assert.Equal(t, "Linux 4.15 - 5.8", host.OS.Matches[0].Name)
+	assert.Equal(t, 96, host.OS.Matches[0].Accuracy)
+	assert.Equal(t, []string{"cpe:/o:linux:linux_kernel:4"}, host.OS.Matches[0].Classes[0].CPEs)
+	assert.Equal(t, int64(86400), host.Uptime.Seconds)
+	assert.Equal(t, 2, host.Distance.Value)
+	assert.Equal(t, "clock-skew", host.HostScripts[0].ID)
+	assert.Equal(t, models.Hop{TTL: 2, IPAddr: "10.0.0.5", RTT: "0.90", Host: "app.example.com"}, host.Trace.Hops[1])
+	assert.Equal(t, &models.Times{SRTT: 900, RTTVar: 200, To: 100000}, host.Times)
+
+	// Stored results are read back through JSON
+	b, err := json.Marshal(CombinedScanResponse{TCP: &run})
+	require.NoError(t, err)
+	res, err := decodeNmapResult(json.RawMessage(b))
+	require.NoError(t, err)
+	assert.Equal(t, run, *res.TCP)
+}
+
+// fakeNmap answers a ping scan with three live hosts and reports ssh open on every
+// host it is asked to scan
+const fakeNmap = `#!/bin/sh
+hosts=""; sweep=0; after=0
+for a in "$@"; do
+  [ $after = 1 ] && hosts="$hosts $a"
+  [ "$a" = "--" ] && after=1
+  [ "$a" = "-sn" ] && sweep=1
+done
+echo '<nmaprun scanner="nmap" args="nmap '"$*"'" start="100" version="7.94">'
+if [ $sweep = 1 ]; then
+  for ip in 10.0.0.9 10.0.0.1 10.0.0.5; do
+    echo "<host><status state=\"up\"/><address addr=\"$ip\" addrtype=\"ipv4\"/></host>"
+  done
+  echo '<runstats><finished time="110" exit="success"/><hosts up="3" down="5" total="8"/></runstats>'
+else
+  for ip in $hosts; do
+    echo "<host><status state=\"up\"/><address addr=\"$ip\" addrtype=\"ipv4\"/><ports><port protocol=\"tcp\" portid=\"22\"><state state=\"open\"/><service name=\"ssh\"/></port></ports></host>"
+  done
+  echo '<runstats><finished time="150" exit="success"/><hosts up="1" down="0" total="1"/></runstats>'
+fi
+echo '</nmaprun>'
+`
+
+func TestNmapRangeScan(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake nmap is a shell script")
+	}
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "nmap"), []byte(fakeNmap), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	profiles := NewNmapProfileService(repo)
+	nmap := NewNmapService(profiles, NmapConfig{ShardSize: 2, ShardParallel: 2, MaxRangeAddresses: 256})
+	// A single nmap slot leaves none to borrow, the shards run one after the other
+	jobs := NewJobService(repo, PoolConfig{MaxConcurrent: 8, ToolLimits: map[string]int{models.ToolNmap: 1}})
+	jobs.Register(models.ToolNmap, nmap.RunJob)
+	jobs.RegisterOptions(models.ToolNmap, profiles.ValidateOptions)
+
+	job, err := jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.0/29", Options: map[string]string{"profile": "quick"}})
+	require.NoError(t, err)
+	waitForStatus(t, jobs, job.ID, models.JobStatusCompleted)
+
+	job, err = jobs.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.JobShard{
+		{Index: 1, Hosts: 2, From: "10.0.0.1", To: "10.0.0.5", Status: models.JobStatusCompleted, Progress: 100},
+		{Index: 2, Hosts: 1, From: "10.0.0.9", To: "10.0.0.9", Status: models.JobStatusCompleted, Progress: 100},
+	}, job.Shards)
+
+	res, err := decodeNmapResult(job.Result)
+	require.NoError(t, err)
+	assert.Nil(t, res.UDP, "quick skips UDP")
+	var hosts []string
+	for _, host := range res.TCP.Hosts {
+		hosts = append(hosts, hostTarget(host))
+		assert.Equal(t, "22", host.Ports.Ports[0].PortID)
+	}
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.5", "10.0.0.9"}, hosts)
+	assert.Contains(t, res.TCP.Args, "-Pn -- 10.0.0.1 10.0.0.5")
+	assert.Equal(t, models.HostStats{Up: 3, Down: 5, Total: 8}, res.TCP.RunStats.Hosts)
+	assert.Equal(t, 50.0, res.TCP.RunStats.Finished.Elapsed)
+
+	outputs, err := repo.ListOutputs(t.Context(), job.ID)
+	require.NoError(t, err)
+	var names []string
+	for _, out := range outputs {
+		names = append(names, out.Name)
+	}
+	assert.ElementsMatch(t, []string{"nmap_discovery.xml", "nmap_tcp_shard1.xml", "nmap_tcp_shard2.xml"}, names)
+
+	// Networks over the limit are refused before anything runs
+	job, err = jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.0/23"})
+	require.NoError(t, err)
+	waitForStatus(t, jobs, job.ID, models.JobStatusFailed)
+	job, err = jobs.Get(job.ID)
+	require.NoError(t, err)
+	assert.Contains(t, job.Error, "more than 256 addresses")
+}
+
+// unprivilegedNmap refuses the scans that need raw sockets like nmap does for a
+// user without them, and reports ssh open on 10.0.0.5 otherwise
+const unprivilegedNmap = `#!/bin/sh
+for a in "$@"; do
+  case "$a" in -sS|-sU|-O)
+    echo "You requested a scan type which requires root privileges." >&2
+    echo "QUITTING!" >&2
+    exit 1;;
+  esac
+done
+echo '<nmaprun scanner="nmap" args="nmap '"$*"'">'
+echo '<host><status state="up"/><address addr="10.0.0.5" addrtype="ipv4"/><ports><port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port></ports></host>'
+echo '</nmaprun>'
+`
+
+func TestNmapUnprivilegedFallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake nmap is a shell script")
+	}
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "nmap"), []byte(unprivilegedNmap), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	profiles := NewNmapProfileService(repo)
+	jobs := NewJobService(repo, DefaultPoolConfig())
+
+	scan := func(nmap *NmapService, profile string) *CombinedScanResponse {
+		jobs.Register(models.ToolNmap, nmap.RunJob)
+		job, err := jobs.Submit(models.JobRequest{Tool: models.ToolNmap, Target: "10.0.0.5", Options: map[string]string{"profile": profile}})
+		require.NoError(t, err)
+		waitForStatus(t, jobs, job.ID, models.JobStatusCompleted)
+		job, err = jobs.Get(job.ID)
+		require.NoError(t, err)
+		res, err := decodeNmapResult(job.Result)
+		require.NoError(t, err)
+		return res
+	}
+
+	nmap := NewNmapService(profiles, DefaultNmapConfig())
+	assert.False(t, nmap.DetectPrivileges(t.Context()))
+	res := scan(nmap, "default")
+	assert.Contains(t, res.TCP.Args, "-sT")
+	assert.Nil(t, res.UDP, "UDP needs raw sockets")
+	assert.Equal(t, []string{
+		"nmap has no raw socket access (root or CAP_NET_RAW): TCP connect scan (-sT) instead of syn scan",
+		"nmap has no raw socket access (root or CAP_NET_RAW): UDP scan skipped",
+	}, res.Warnings)
+
+	// Told it is privileged, nmap refuses at run time and the job retries without raw sockets
+	privileged := true
+	nmap = NewNmapService(profiles, NmapConfig{ShardSize: 16, ShardParallel: 4, MaxRangeAddresses: 256, Privileged: &privileged})
+	assert.True(t, nmap.DetectPrivileges(t.Context()))
+	res = scan(nmap, "default")
+	assert.NotContains(t, res.TCP.Args, "--privileged")
+	assert.Len(t, res.Warnings, 2)
+	assert.False(t, nmap.Privileged())
+
+	// A profile that only connects needs no fallback
+	_, warnings := nmapUnprivileged(models.NmapProfileSettings{TCPTechnique: "connect", Ports: "22"})
+	assert.Empty(t, warnings)
+}
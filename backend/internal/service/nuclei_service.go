@@ -1,14 +1,18 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"napscan-be/internal/models"
 )
 
 type NucleiService struct{}
@@ -17,29 +21,50 @@ func NewNucleiService() *NucleiService {
 	return &NucleiService{}
 }
 
-func (s *NucleiService) ExecuteScan(ctx context.Context, target string) ([]map[string]interface{}, error) {
-	tmpFile := filepath.Join(os.TempDir(), "nuclei_"+time.Now().Format("20060102150405")+".jsonl")
+func (s *NucleiService) ExecuteScan(ctx context.Context, target *Target) ([]map[string]interface{}, error) {
+	// Several nuclei jobs run at once, each needs a file of its own
+	f, err := os.CreateTemp("", "nuclei_*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create nuclei output file: %w", err)
+	}
+	tmpFile := f.Name()
+	f.Close()
 	defer os.Remove(tmpFile)
 
-	cmd := exec.CommandContext(ctx,
+	cmd := newToolCommand(ctx,
 		"nuclei",
-		"-target", target,
+		"-target", target.String(),
 		"-jsonl",
 		"-o", tmpFile,
 		"-silent",
 		"-nc",
+		"-stats",
+		"-sj",
+		"-si", "5",
 	)
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("nuclei execution failed: %v, output: %s", err, string(output))
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = io.MultiWriter(&output, newLineWriter(func(line string) {
+		if percent, ok := parseNucleiStats(line); ok {
+			reportProgress(ctx, percent, fmt.Sprintf("nuclei %d%%", percent))
+		}
+	}))
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("nuclei execution failed: %v, output: %s", err, output.String())
 	}
 
 	jsonData, err := os.ReadFile(tmpFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read nuclei output: %w", err)
 	}
+	recordOutput(ctx, "nuclei.jsonl", "application/x-ndjson", jsonData)
+	return parseNucleiJSONL(jsonData)
+}
 
+// parseNucleiJSONL reads the results nuclei writes with -jsonl, one JSON object per line
+func parseNucleiJSONL(jsonData []byte) ([]map[string]interface{}, error) {
 	trimmed := strings.TrimSpace(string(jsonData))
 	if trimmed == "" {
 		return []map[string]interface{}{}, nil
@@ -61,3 +86,124 @@ func (s *NucleiService) ExecuteScan(ctx context.Context, target string) ([]map[s
 
 	return results, nil
 }
+
+// parseNucleiStats reads the percent field of a -stats-json line. Depending on the
+// nuclei version the value is a number or a string.
+func parseNucleiStats(line string) (int, bool) {
+	if !strings.HasPrefix(line, "{") {
+		return 0, false
+	}
+	var stats struct {
+		Percent json.RawMessage `json:"percent"`
+	}
+	if err := json.Unmarshal([]byte(line), &stats); err != nil || len(stats.Percent) == 0 {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(strings.Trim(string(stats.Percent), `"`), 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(percent), true
+}
+
+// RunJob runs nuclei as a background job
+func (s *NucleiService) RunJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Second)
+	defer cancel()
+
+	target, err := ParseTarget(run.Target())
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.ExecuteScan(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"target":  run.Target(),
+		"results": results,
+	}, nil
+}
+
+// nucleiResult is the part of a nuclei JSONL line that findings are made of
+type nucleiResult struct {
+	TemplateID string `json:"template-id"`
+	Info       struct {
+		Name           string     `json:"name"`
+		Severity       string     `json:"severity"`
+		Description    string     `json:"description"`
+		Tags           stringList `json:"tags"`
+		Classification struct {
+			CVEID     stringList `json:"cve-id"`
+			CWEID     stringList `json:"cwe-id"`
+			CVSSScore float64    `json:"cvss-score"`
+		} `json:"classification"`
+	} `json:"info"`
+	Host             string   `json:"host"`
+	Port             jsonText `json:"port"`
+	MatchedAt        string   `json:"matched-at"`
+	MatcherName      string   `json:"matcher-name"`
+	ExtractedResults []string `json:"extracted-results"`
+}
+
+// normalizeNucleiResult turns the results of a nuclei job into findings
+func normalizeNucleiResult(result interface{}) ([]models.Finding, error) {
+	var res struct {
+		Results []nucleiResult `json:"results"`
+	}
+	if err := decodeJobResult(result, &res); err != nil {
+		return nil, err
+	}
+
+	findings := make([]models.Finding, 0, len(res.Results))
+	for i, r := range res.Results {
+		f := models.Finding{
+			RuleID:      r.TemplateID,
+			Title:       r.Info.Name,
+			Description: strings.TrimSpace(r.Info.Description),
+			Severity:    parseSeverity(r.Info.Severity),
+			CVSS:        r.Info.Classification.CVSSScore,
+			CVEs:        normalizeCVEs(r.Info.Classification.CVEID),
+			CWEs:        normalizeCWEs(r.Info.Classification.CWEID),
+			Port:        string(r.Port),
+			RawRef:      fmt.Sprintf("results[%d]", i),
+		}
+		if f.Title == "" {
+			f.Title = r.TemplateID
+		}
+		// Templates of vulnerabilities in the CISA KEV catalog are tagged "kev"
+		for _, tag := range r.Info.Tags {
+			f.KnownExploit = f.KnownExploit || strings.EqualFold(strings.TrimSpace(tag), "kev")
+		}
+		// Templates with several matchers report one result per matcher that fired
+		if r.MatcherName != "" {
+			f.RuleID += ":" + r.MatcherName
+		}
+
+		// matched-at is a URL for HTTP templates and host:port for network ones
+		if host, port := urlHostPort(r.MatchedAt); host != "" {
+			f.URL = r.MatchedAt
+			f.Host = host
+			if f.Port == "" {
+				f.Port = port
+			}
+		} else if host, port, err := net.SplitHostPort(r.MatchedAt); err == nil {
+			f.Host = host
+			if f.Port == "" {
+				f.Port = port
+			}
+		} else {
+			f.Host = r.Host
+		}
+
+		var evidence []string
+		if r.MatcherName != "" {
+			evidence = append(evidence, "matcher: "+r.MatcherName)
+		}
+		evidence = append(evidence, r.ExtractedResults...)
+		f.Evidence = strings.Join(evidence, "\n")
+
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
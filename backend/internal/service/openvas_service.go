@@ -2,14 +2,18 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"napscan-be/internal/models"
 )
 
 // Structures for XML Parsing
@@ -36,17 +40,17 @@ type GVMDReportRef struct {
 
 // Report Parsing
 type GVMDReportResponse struct {
-	XMLName xml.Name   `xml:"get_reports_response"`
+	XMLName xml.Name          `xml:"get_reports_response"`
 	Report  GVMDReportWrapper `xml:"report"`
 }
 
 type GVMDReportWrapper struct {
-    InnerReport GVMDReportContent `xml:"report"`
+	InnerReport GVMDReportContent `xml:"report"`
 }
 
 type GVMDReportContent struct {
-	ScanRunStatus string       `xml:"scan_run_status" json:"scan_run_status"`
-	Results       GVMDResults  `xml:"results" json:"results"`
+	ScanRunStatus string      `xml:"scan_run_status" json:"scan_run_status"`
+	Results       GVMDResults `xml:"results" json:"results"`
 }
 
 type GVMDResults struct {
@@ -54,22 +58,123 @@ type GVMDResults struct {
 }
 
 type GVMDResult struct {
-	Name        string  `xml:"name" json:"name"`
-	Host        string  `xml:"host" json:"host"`
+	Name string   `xml:"name" json:"name"`
+	Host GVMDHost `xml:"host" json:"host"`
+	// Hostname is the name gvmd resolved for the host, which ZAP and nuclei report too
+	Hostname    string  `xml:"-" json:"hostname,omitempty"`
 	Port        string  `xml:"port" json:"port"`
 	Threat      string  `xml:"threat" json:"threat"`
 	Severity    string  `xml:"severity" json:"severity"`
-	Qod         string  `xml:"qod" json:"qod"`
+	Qod         GVMDQoD `xml:"qod" json:"qod"`
 	Description string  `xml:"description" json:"description"`
 	NVT         GVMDNVT `xml:"nvt" json:"nvt"`
 }
 
+// GVMDHost is the host of a result: the address gvmd scanned and, when it resolved
+// one, the name of the host. Only the address is kept in JSON, the name goes to the
+// hostname of the result.
+type GVMDHost struct {
+	IP       string `xml:",chardata"`
+	Hostname string `xml:"hostname"`
+}
+
+func (h GVMDHost) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.TrimSpace(h.IP))
+}
+
+func (h *GVMDHost) UnmarshalJSON(data []byte) error {
+	*h = GVMDHost{}
+	return json.Unmarshal(data, &h.IP)
+}
+
+// hostName returns the hostname of a result, or else its address
+func (r GVMDResult) hostName() string {
+	if name := strings.TrimSpace(r.Hostname); name != "" {
+		return name
+	}
+	return strings.TrimSpace(r.Host.IP)
+}
+
 type GVMDNVT struct {
 	OID      string `xml:"oid,attr" json:"oid"`
 	Name     string `xml:"name" json:"name"`
 	Family   string `xml:"family" json:"family"`
 	CVSSBase string `xml:"cvss_base" json:"cvss_base"`
 	Tags     string `xml:"tags" json:"tags"`
+	// Refs holds the CVE, CERT-Bund and URL references of the NVT
+	Refs []GVMDRef `xml:"refs>ref" json:"refs,omitempty"`
+}
+
+// GVMDQoD is the quality of detection of a result: how sure the NVT is, in percent
+type GVMDQoD struct {
+	Value string `xml:"value" json:"value"`
+	// Type is how the NVT detected the issue, "exploit" when it exploited it
+	Type string `xml:"type" json:"type,omitempty"`
+}
+
+// UnmarshalJSON also reads the plain string stored for results saved before the
+// QoD was parsed
+func (q *GVMDQoD) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*q = GVMDQoD{Value: strings.TrimSpace(s)}
+		return nil
+	}
+	type plain GVMDQoD
+	return json.Unmarshal(data, (*plain)(q))
+}
+
+type GVMDRef struct {
+	Type string `xml:"type,attr" json:"type"`
+	ID   string `xml:"id,attr" json:"id"`
+}
+
+// GMP commands. They are built with encoding/xml so names and IDs are escaped.
+
+type gmpCreateTarget struct {
+	XMLName  xml.Name `xml:"create_target"`
+	Name     string   `xml:"name"`
+	Hosts    string   `xml:"hosts"`
+	PortList gmpRef   `xml:"port_list"`
+}
+
+type gmpCreateTask struct {
+	XMLName xml.Name `xml:"create_task"`
+	Name    string   `xml:"name"`
+	Target  gmpRef   `xml:"target"`
+	Config  gmpRef   `xml:"config"`
+	Scanner gmpRef   `xml:"scanner"`
+}
+
+type gmpRef struct {
+	ID string `xml:"id,attr"`
+}
+
+// gmpTaskCommand is <start_task>, <stop_task> and <get_tasks>, which address a task
+type gmpTaskCommand struct {
+	XMLName xml.Name
+	TaskID  string `xml:"task_id,attr"`
+	Details string `xml:"details,attr,omitempty"`
+}
+
+type gmpGetReports struct {
+	XMLName  xml.Name `xml:"get_reports"`
+	ReportID string   `xml:"report_id,attr"`
+	FormatID string   `xml:"format_id,attr"`
+	Details  string   `xml:"details,attr"`
+}
+
+func gmpTask(command, taskID string) gmpTaskCommand {
+	return gmpTaskCommand{XMLName: xml.Name{Local: command}, TaskID: taskID}
+}
+
+// gmpXML encodes a GMP command for gvm-cli --xml
+func gmpXML(command interface{}) (string, error) {
+	b, err := xml.Marshal(command)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode GMP command: %w", err)
+	}
+	return string(b), nil
 }
 
 type OpenVASService struct{}
@@ -181,7 +286,7 @@ func (s *OpenVASService) RunGVMCLI(ctx context.Context, xmlCommand string) ([]by
 	if err != nil {
 		return nil, err
 	}
-	
+
 	cmd := exec.CommandContext(ctx,
 		"docker", "compose", "-f", composePath,
 		"run", "--rm", "gvm-tools",
@@ -215,7 +320,7 @@ func (s *OpenVASService) extractIDFromXML(xmlResponse string) string {
 	if startIdx == -1 {
 		return ""
 	}
-	
+
 	endIdx := -1
 	for i := startIdx; i < len(cleanXML); i++ {
 		if cleanXML[i] == '"' {
@@ -237,42 +342,54 @@ func (s *OpenVASService) GetVersion(ctx context.Context) (string, error) {
 	return s.extractCleanXML(string(output)), nil
 }
 
-func (s *OpenVASService) StartScan(ctx context.Context, target string) (map[string]interface{}, error) {
-	targetName := "Scan-" + target + "-" + time.Now().Format("20060102-150405")
-	
+func (s *OpenVASService) StartScan(ctx context.Context, target *Target) (map[string]interface{}, error) {
+	targetName := "Scan-" + target.Hosts() + "-" + time.Now().Format("20060102-150405")
+
 	// 1. Create Target
 	portListID := "33d0cd82-57c6-11e1-8ed1-406186ea4fc5" // All IANA configured TCP
-	createTargetXML := fmt.Sprintf(`<create_target><name>%s</name><hosts>%s</hosts><port_list id="%s"/></create_target>`, 
-		targetName, target, portListID)
-	
+	createTargetXML, err := gmpXML(gmpCreateTarget{Name: targetName, Hosts: target.Hosts(), PortList: gmpRef{ID: portListID}})
+	if err != nil {
+		return nil, err
+	}
+
 	out, err := s.RunGVMCLI(ctx, createTargetXML)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create target: %w, output: %s", err, string(out))
 	}
-	
+
 	targetID := s.extractIDFromXML(string(out))
 	if targetID == "" {
 		return nil, fmt.Errorf("failed to extract target ID, output: %s", string(out))
 	}
 
 	// 2. Create Task
-	configID := "daba56c8-73ec-11df-a475-002264764cea" // Full and fast
+	configID := "daba56c8-73ec-11df-a475-002264764cea"  // Full and fast
 	scannerID := "08b69003-5fc2-4037-a479-93b440211c73" // OpenVAS Default
-	createTaskXML := fmt.Sprintf(`<create_task><name>%s</name><target id="%s"/><config id="%s"/><scanner id="%s"/></create_task>`,
-		targetName, targetID, configID, scannerID)
-		
+	createTaskXML, err := gmpXML(gmpCreateTask{
+		Name:    targetName,
+		Target:  gmpRef{ID: targetID},
+		Config:  gmpRef{ID: configID},
+		Scanner: gmpRef{ID: scannerID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	out, err = s.RunGVMCLI(ctx, createTaskXML)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create task: %w, output: %s", err, string(out))
 	}
-	
+
 	taskID := s.extractIDFromXML(string(out))
 	if taskID == "" {
 		return nil, fmt.Errorf("failed to extract task ID, output: %s", string(out))
 	}
 
 	// 3. Start Task
-	startTaskXML := fmt.Sprintf(`<start_task task_id="%s"/>`, taskID)
+	startTaskXML, err := gmpXML(gmpTask("start_task", taskID))
+	if err != nil {
+		return nil, err
+	}
 	out, err = s.RunGVMCLI(ctx, startTaskXML)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start task: %w, output: %s", err, string(out))
@@ -280,7 +397,7 @@ func (s *OpenVASService) StartScan(ctx context.Context, target string) (map[stri
 
 	return map[string]interface{}{
 		"message":  "OpenVAS scan started successfully",
-		"target":   target,
+		"target":   target.Hosts(),
 		"targetID": targetID,
 		"taskID":   taskID,
 		"scanName": targetName,
@@ -289,7 +406,12 @@ func (s *OpenVASService) StartScan(ctx context.Context, target string) (map[stri
 }
 
 func (s *OpenVASService) GetTaskStatus(ctx context.Context, taskID string) (*GVMDTask, error) {
-	xmlCmd := fmt.Sprintf(`<get_tasks task_id="%s" details="1"/>`, taskID)
+	cmd := gmpTask("get_tasks", taskID)
+	cmd.Details = "1"
+	xmlCmd, err := gmpXML(cmd)
+	if err != nil {
+		return nil, err
+	}
 	out, err := s.RunGVMCLI(ctx, xmlCmd)
 	if err != nil {
 		return nil, err
@@ -307,23 +429,215 @@ func (s *OpenVASService) GetTaskStatus(ctx context.Context, taskID string) (*GVM
 	if p < 0 {
 		resp.Task.Progress = "0"
 	}
-	
+
 	return &resp.Task, nil
 }
 
 func (s *OpenVASService) GetScanReport(ctx context.Context, reportID string) (*GVMDReportContent, error) {
 	// Use XML format
-	xmlCmd := fmt.Sprintf(`<get_reports report_id="%s" format_id="a994b278-1f62-11e1-96ac-406186ea4fc5" details="1"/>`, reportID)
+	xmlCmd, err := gmpXML(gmpGetReports{ReportID: reportID, FormatID: "a994b278-1f62-11e1-96ac-406186ea4fc5", Details: "1"})
+	if err != nil {
+		return nil, err
+	}
 	out, err := s.RunGVMCLI(ctx, xmlCmd)
 	if err != nil {
 		return nil, err
 	}
 
 	cleanXML := s.extractCleanXML(string(out))
+	recordOutput(ctx, "report.xml", "application/xml", []byte(cleanXML))
+	return parseGVMDReport([]byte(cleanXML))
+}
+
+// parseGVMDReport reads a <get_reports_response> of gvmd, or the <report> it wraps as
+// the XML report format of GSA downloads it
+func parseGVMDReport(data []byte) (*GVMDReportContent, error) {
+	var content *GVMDReportContent
 	var resp GVMDReportResponse
-	if err := xml.Unmarshal([]byte(cleanXML), &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse report XML: %w", err)
+	if err := xml.Unmarshal(data, &resp); err == nil {
+		content = &resp.Report.InnerReport
+	} else {
+		var report GVMDReportWrapper
+		if err := xml.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to parse report XML: %w", err)
+		}
+		content = &report.InnerReport
+	}
+
+	for i := range content.Results.Result {
+		r := &content.Results.Result[i]
+		r.Hostname = strings.TrimSpace(r.Host.Hostname)
+	}
+	return content, nil
+}
+
+// RunJob creates and starts an OpenVAS task, polls it until it is done and returns the report.
+// A job resumed after a restart re-attaches to the task stored in its refs instead.
+func (s *OpenVASService) RunJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 6*time.Hour)
+	defer cancel()
+
+	taskID := run.Ref("task_id")
+	if taskID == "" {
+		startCtx, startCancel := context.WithTimeout(ctx, 60*time.Second)
+		target, err := ParseTarget(run.Target())
+		if err != nil {
+			startCancel()
+			return nil, err
+		}
+		started, err := s.StartScan(startCtx, target)
+		startCancel()
+		if err != nil {
+			return nil, err
+		}
+
+		taskID = fmt.Sprint(started["taskID"])
+		run.SetRef("task_id", taskID)
+		run.SetRef("target_id", fmt.Sprint(started["targetID"]))
+	}
+
+	reportID := run.Ref("report_id")
+	if reportID == "" {
+		var err error
+		if reportID, err = s.waitForTask(ctx, run, taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	reportCtx, reportCancel := context.WithTimeout(ctx, 120*time.Second)
+	defer reportCancel()
+	return s.GetScanReport(reportCtx, reportID)
+}
+
+// waitForTask polls the task status until gvmd reports it as done and returns its report ID
+func (s *OpenVASService) waitForTask(ctx context.Context, run *JobRun, taskID string) (reportID string, err error) {
+	// A cancelled job most likely interrupts a gvm-cli status call, so the task is
+	// stopped on any error once the job context is done
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			s.stopTask(taskID)
+			err = ctx.Err()
+		}
+	}()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+			statusCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			task, err := s.GetTaskStatus(statusCtx, taskID)
+			cancel()
+			if err != nil {
+				return "", fmt.Errorf("failed to get task status: %w", err)
+			}
+
+			progress, _ := strconv.Atoi(task.Progress)
+			run.SetProgress(progress, "OpenVAS task "+task.Status)
+
+			switch strings.ToLower(task.Status) {
+			case "done":
+				if task.LastReport.Report.ID == "" {
+					return "", fmt.Errorf("task %s finished without a report", taskID)
+				}
+				run.SetRef("report_id", task.LastReport.Report.ID)
+				return task.LastReport.Report.ID, nil
+			case "stopped", "interrupted":
+				return "", fmt.Errorf("OpenVAS task %s", strings.ToLower(task.Status))
+			}
+		}
+	}
+}
+
+// stopTask sends <stop_task> for a task whose job was cancelled or timed out
+func (s *OpenVASService) stopTask(taskID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	xmlCmd, err := gmpXML(gmpTask("stop_task", taskID))
+	if err != nil {
+		log.Printf("Failed to stop OpenVAS task %s: %v", taskID, err)
+		return
+	}
+	out, err := s.RunGVMCLI(ctx, xmlCmd)
+	if err != nil {
+		log.Printf("Failed to stop OpenVAS task %s: %v, output: %s", taskID, err, string(out))
 	}
+}
 
-	return &resp.Report.InnerReport, nil
+// normalizeOpenVASResult turns the results of an OpenVAS report into findings
+func normalizeOpenVASResult(result interface{}) ([]models.Finding, error) {
+	var report GVMDReportContent
+	if err := decodeJobResult(result, &report); err != nil {
+		return nil, err
+	}
+
+	findings := make([]models.Finding, 0, len(report.Results.Result))
+	for i, r := range report.Results.Result {
+		tags := parseNVTTags(r.NVT.Tags)
+		f := models.Finding{
+			RuleID:      r.NVT.OID,
+			Title:       strings.TrimSpace(r.Name),
+			Description: strings.TrimSpace(r.Description),
+			CVSSVector:  tags["cvss_base_vector"],
+			Host:        r.hostName(),
+			RawRef:      fmt.Sprintf("results.result[%d]", i),
+		}
+		if f.Title == "" {
+			f.Title = r.NVT.Name
+		}
+		// The result description is what the NVT detected, the summary what the issue is
+		if summary := tags["summary"]; summary != "" {
+			f.Evidence = f.Description
+			f.Description = summary
+		}
+
+		// gvmd has no "Critical" threat level, the CVSS score tells it apart. The result
+		// severity includes overrides, the NVT base score is the fallback.
+		if score, err := strconv.ParseFloat(strings.TrimSpace(r.Severity), 64); err == nil {
+			f.CVSS = score
+			f.Severity = cvssSeverity(score)
+		} else if score, err := strconv.ParseFloat(strings.TrimSpace(r.NVT.CVSSBase), 64); err == nil {
+			f.CVSS = score
+			f.Severity = cvssSeverity(score)
+		} else {
+			f.Severity = parseSeverity(r.Threat)
+		}
+
+		if qod, err := strconv.Atoi(strings.TrimSpace(r.Qod.Value)); err == nil {
+			f.QoD = qod
+		}
+		f.KnownExploit = strings.EqualFold(strings.TrimSpace(r.Qod.Type), "exploit")
+
+		// Ports look like "443/tcp", or "general/tcp" for host-wide results
+		if port, _, ok := strings.Cut(strings.TrimSpace(r.Port), "/"); ok {
+			if _, err := strconv.Atoi(port); err == nil {
+				f.Port = port
+			}
+		}
+
+		for _, ref := range r.NVT.Refs {
+			if strings.EqualFold(ref.Type, "cve") {
+				f.CVEs = append(f.CVEs, strings.ToUpper(ref.ID))
+			}
+		}
+
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// parseNVTTags splits the tags of an NVT, "cvss_base_vector=AV:N/...|summary=...|
+// solution_type=VendorFix", into a map
+func parseNVTTags(tags string) map[string]string {
+	parsed := make(map[string]string)
+	for _, tag := range strings.Split(tags, "|") {
+		if key, value, ok := strings.Cut(tag, "="); ok {
+			parsed[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return parsed
 }
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"napscan-be/internal/models"
+)
+
+// Follow-up tools of a pipeline scan, by the kind of service they need
+var (
+	pipelineWebTools = []string{models.ToolNuclei, models.ToolFfuf, models.ToolZap}
+	pipelineTLSTools = []string{models.ToolSslyze}
+)
+
+// isPipelineTool reports whether tool can be started from nmap results
+func isPipelineTool(tool string) bool {
+	for _, t := range append(pipelineWebTools, pipelineTLSTools...) {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceEndpoint is an open TCP service found by nmap
+type serviceEndpoint struct {
+	Host string
+	Port string
+	Web  bool
+	TLS  bool
+}
+
+// URL builds the base URL of a web service, leaving out the default port of the scheme
+func (e serviceEndpoint) URL() string {
+	scheme, defaultPort := "http", "80"
+	if e.TLS {
+		scheme, defaultPort = "https", "443"
+	}
+	host := e.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if e.Port == defaultPort {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + host + ":" + e.Port
+}
+
+// HostPort is the host:port target sslyze expects
+func (e serviceEndpoint) HostPort() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
+
+// tlsServiceNames are services that speak TLS from the first byte
+var tlsServiceNames = map[string]bool{
+	"https": true, "https-alt": true, "ssl": true, "imaps": true, "pop3s": true,
+	"smtps": true, "submissions": true, "ldaps": true, "ftps": true, "ircs": true,
+}
+
+// discoverEndpoints extracts the open web and TLS services from an nmap run
+func discoverEndpoints(run *models.NmapRun) []serviceEndpoint {
+	if run == nil {
+		return nil
+	}
+
+	var endpoints []serviceEndpoint
+	for _, host := range run.Hosts {
+		name := hostTarget(host)
+		if name == "" {
+			continue
+		}
+		for _, port := range host.Ports.Ports {
+			if port.State.State != "open" || (port.Proto != "" && port.Proto != "tcp") {
+				continue
+			}
+			service := strings.ToLower(port.Service.Name)
+			// Normal output writes TLS-wrapped services as "ssl/http"
+			tls := port.Service.Tunnel == "ssl" || strings.HasPrefix(service, "ssl/") || tlsServiceNames[service]
+			service = strings.TrimPrefix(service, "ssl/")
+			web := strings.HasPrefix(service, "http")
+
+			if web || tls {
+				endpoints = append(endpoints, serviceEndpoint{Host: name, Port: port.PortID, Web: web, TLS: tls})
+			}
+		}
+	}
+	return endpoints
+}
+
+// hostTarget prefers the name the user scanned, so virtual hosts and SNI keep working
+func hostTarget(host models.Host) string {
+	for _, h := range host.Hostnames {
+		if h.Type == "user" && h.Name != "" {
+			return h.Name
+		}
+	}
+	for _, a := range host.Addresses {
+		if a.AddrType != "mac" && a.Addr != "" {
+			return a.Addr
+		}
+	}
+	return ""
+}
+
+// followUpJobs turns the services nmap found into jobs for the requested tools
+func followUpJobs(endpoints []serviceEndpoint, tools []string) []models.JobRequest {
+	wanted := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		wanted[tool] = true
+	}
+
+	var reqs []models.JobRequest
+	seen := make(map[string]bool)
+	add := func(tool, target string) {
+		key := tool + " " + target
+		if !wanted[tool] || seen[key] {
+			return
+		}
+		seen[key] = true
+		reqs = append(reqs, models.JobRequest{Tool: tool, Target: target})
+	}
+
+	for _, e := range endpoints {
+		if e.Web {
+			for _, tool := range pipelineWebTools {
+				add(tool, e.URL())
+			}
+		}
+		if e.TLS {
+			for _, tool := range pipelineTLSTools {
+				add(tool, e.HostPort())
+			}
+		}
+	}
+	return reqs
+}
+
+// decodeNmapResult reads the result of an nmap job, whether it is still the Go value
+// returned by the runner or JSON loaded from the repository
+func decodeNmapResult(result interface{}) (*CombinedScanResponse, error) {
+	var res CombinedScanResponse
+	if err := decodeJobResult(result, &res); err != nil {
+		return nil, fmt.Errorf("invalid nmap result: %w", err)
+	}
+	return &res, nil
+}
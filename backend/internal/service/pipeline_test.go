@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineQueuesFollowUpsForDiscoveredServices(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	jobs.Register(models.ToolNmap, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return CombinedScanResponse{TCP: &models.NmapRun{Hosts: []models.Host{{
+			Addresses: []models.Address{{Addr: "10.0.0.5", AddrType: "ipv4"}},
+			Hostnames: []models.Hostname{{Name: "app.example.com", Type: "user"}},
+			Ports: models.Ports{Ports: []models.Port{
+				{PortID: "22", Proto: "tcp", State: models.State{State: "open"}, Service: models.Service{Name: "ssh"}},
+				{PortID: "443", Proto: "tcp", State: models.State{State: "open"}, Service: models.Service{Name: "http", Tunnel: "ssl"}},
+				{PortID: "8080", Proto: "tcp", State: models.State{State: "open"}, Service: models.Service{Name: "http-proxy"}},
+				{PortID: "8443", Proto: "tcp", State: models.State{State: "filtered"}, Service: models.Service{Name: "https-alt"}},
+			}},
+		}}}}, nil
+	})
+
+	var mu sync.Mutex
+	var targets []string
+	for _, tool := range []string{models.ToolNuclei, models.ToolFfuf, models.ToolZap, models.ToolSslyze} {
+		tool := tool
+		jobs.Register(tool, func(ctx context.Context, run *JobRun) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			targets = append(targets, tool+" "+run.Target())
+			return nil, nil
+		})
+	}
+	enrichment := NewEnrichmentService(t.TempDir())
+	s := NewScanService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), enrichment, NewTriageService(repo, jobs, enrichment), NewSuppressionService(repo, jobs))
+
+	_, err = s.Create(models.ScanRequest{Target: "app.example.com", Tools: []string{models.ToolOpenVAS}, Pipeline: true})
+	assert.ErrorIs(t, err, ErrInvalidScan)
+
+	scan, err := s.Create(models.ScanRequest{
+		Target:   "app.example.com",
+		Tools:    []string{models.ToolNuclei, models.ToolSslyze},
+		Pipeline: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.ToolNmap, models.ToolNuclei, models.ToolSslyze}, scan.Tools)
+
+	require.Eventually(t, func() bool {
+		scan, err = s.Get(scan.ID)
+		return err == nil && scan.Status == models.ScanStatusCompleted
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.ElementsMatch(t, []string{
+		"nuclei https://app.example.com",
+		"nuclei http://app.example.com:8080",
+		"sslyze app.example.com:443",
+	}, targets)
+	mu.Unlock()
+
+	require.Len(t, scan.Tree, 1)
+	assert.Equal(t, models.ToolNmap, scan.Tree[0].Tool)
+	assert.Len(t, scan.Tree[0].Children, 3)
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"napscan-be/internal/models"
+)
+
+// RiskConfig holds the weights of the risk scoring
+type RiskConfig struct {
+	// SeverityScores is the 0-10 base score of findings without a CVSS score
+	SeverityScores map[models.Severity]float64
+	// QoDWeight is how much a low quality of detection lowers a score: 0 ignores it,
+	// 1 scales the score by the QoD
+	QoDWeight float64
+	// ExploitMultiplier scales findings with a known exploit
+	ExploitMultiplier float64
+	// Criticality scales all findings on an asset by its criticality
+	Criticality map[models.AssetCriticality]float64
+	// Saturation is how many points the other findings of a host (or the other hosts
+	// of a scan) must add up to to close about 63% of the gap to 100
+	Saturation float64
+}
+
+func DefaultRiskConfig() RiskConfig {
+	return RiskConfig{
+		SeverityScores: map[models.Severity]float64{
+			models.SeverityCritical: 9.5,
+			models.SeverityHigh:     7.5,
+			models.SeverityMedium:   5,
+			models.SeverityLow:      2.5,
+			models.SeverityInfo:     0,
+		},
+		QoDWeight:         0.5,
+		ExploitMultiplier: 1.5,
+		Criticality: map[models.AssetCriticality]float64{
+			models.AssetCriticalityLow:      0.5,
+			models.AssetCriticalityMedium:   1,
+			models.AssetCriticalityHigh:     1.5,
+			models.AssetCriticalityCritical: 2,
+		},
+		Saturation: 100,
+	}
+}
+
+// RiskConfigFromEnv reads NAPSCAN_RISK_QOD_WEIGHT, NAPSCAN_RISK_EXPLOIT_MULTIPLIER,
+// NAPSCAN_RISK_SATURATION, NAPSCAN_RISK_SEVERITY_SCORES (e.g. "critical=10,high=8")
+// and NAPSCAN_RISK_CRITICALITY (e.g. "low=0.25,critical=3") on top of DefaultRiskConfig
+func RiskConfigFromEnv() RiskConfig {
+	cfg := DefaultRiskConfig()
+
+	weights := map[string]*float64{
+		"NAPSCAN_RISK_QOD_WEIGHT":         &cfg.QoDWeight,
+		"NAPSCAN_RISK_EXPLOIT_MULTIPLIER": &cfg.ExploitMultiplier,
+		"NAPSCAN_RISK_SATURATION":         &cfg.Saturation,
+	}
+	for name, target := range weights {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				log.Printf("Ignoring invalid %s %q", name, v)
+				continue
+			}
+			*target = f
+		}
+	}
+	if cfg.QoDWeight > 1 {
+		cfg.QoDWeight = 1
+	}
+	if cfg.Saturation == 0 {
+		cfg.Saturation = DefaultRiskConfig().Saturation
+	}
+
+	for key, f := range parseWeights("NAPSCAN_RISK_SEVERITY_SCORES") {
+		sev := models.Severity(key)
+		if _, ok := cfg.SeverityScores[sev]; !ok || f > 10 {
+			log.Printf("Ignoring invalid severity score %s=%v", key, f)
+			continue
+		}
+		cfg.SeverityScores[sev] = f
+	}
+	for key, f := range parseWeights("NAPSCAN_RISK_CRITICALITY") {
+		crit := models.AssetCriticality(key)
+		if _, ok := cfg.Criticality[crit]; !ok {
+			log.Printf("Ignoring invalid asset criticality %q", key)
+			continue
+		}
+		cfg.Criticality[crit] = f
+	}
+
+	return cfg
+}
+
+// parseWeights reads "key=number" pairs from an environment variable
+func parseWeights(name string) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(os.Getenv(name), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || f < 0 {
+			log.Printf("Ignoring invalid %s entry %q", name, pair)
+			continue
+		}
+		weights[strings.ToLower(strings.TrimSpace(key))] = f
+	}
+	return weights
+}
+
+// RiskScorer scores findings, hosts and scans from 0 to 100. Every score explains
+// how it was derived so the numbers can be checked and the weights tuned.
+type RiskScorer struct {
+	cfg RiskConfig
+}
+
+func NewRiskScorer(cfg RiskConfig) *RiskScorer {
+	return &RiskScorer{cfg: cfg}
+}
+
+// ValidCriticality reports whether c is empty (medium) or a configured criticality
+func (r *RiskScorer) ValidCriticality(c models.AssetCriticality) bool {
+	_, ok := r.cfg.Criticality[c]
+	return c == "" || ok
+}
+
+// ScoreFinding scores one finding on an asset of the given criticality. The base is
+// the CVSS score, or the configured score of its severity, times ten; it is then
+// scaled by the quality of detection, a known exploit and the asset criticality.
+// Findings triaged as false positives or accepted risks score 0.
+func (r *RiskScorer) ScoreFinding(f models.Finding, criticality models.AssetCriticality) models.RiskScore {
+	if f.Triage.Suppressed(time.Now()) {
+		return models.RiskScore{Explanation: []string{fmt.Sprintf("0 while triaged as %s", f.Triage.Status)}}
+	}
+
+	var steps []string
+	base := f.CVSS
+	if base > 0 {
+		steps = append(steps, fmt.Sprintf("CVSS base score %.1f", base))
+	} else {
+		base = r.cfg.SeverityScores[f.Severity]
+		steps = append(steps, fmt.Sprintf("no CVSS score, %s severity counts as %.1f", f.Severity, base))
+	}
+	score := base * 10
+
+	if f.QoD > 0 && f.QoD < 100 && r.cfg.QoDWeight > 0 {
+		factor := 1 - r.cfg.QoDWeight*(1-float64(f.QoD)/100)
+		score *= factor
+		steps = append(steps, fmt.Sprintf("x%.2f for a quality of detection of %d%%", factor, f.QoD))
+	}
+	if f.KnownExploit && r.cfg.ExploitMultiplier != 1 {
+		score *= r.cfg.ExploitMultiplier
+		steps = append(steps, fmt.Sprintf("x%.2f for a known exploit", r.cfg.ExploitMultiplier))
+	}
+	if factor := r.criticalityFactor(criticality); factor != 1 {
+		score *= factor
+		steps = append(steps, fmt.Sprintf("x%.2f for %s asset criticality", factor, criticalityName(criticality)))
+	}
+	if score > 100 {
+		score = 100
+		steps = append(steps, "capped at 100")
+	}
+
+	return models.RiskScore{Score: round1(score), Explanation: steps}
+}
+
+// ScoreFindings scores every finding in place and combines them per host and for
+// the whole scan
+func (r *RiskScorer) ScoreFindings(findings []models.Finding, criticality models.AssetCriticality) models.ScanRisk {
+	hostScores := make(map[string][]float64)
+	for i := range findings {
+		risk := r.ScoreFinding(findings[i], criticality)
+		findings[i].Risk = &risk
+		hostScores[findings[i].Host] = append(hostScores[findings[i].Host], risk.Score)
+	}
+
+	risk := models.ScanRisk{AssetCriticality: criticality, Hosts: []models.HostRisk{}}
+	if risk.AssetCriticality == "" {
+		risk.AssetCriticality = models.AssetCriticalityMedium
+	}
+	scores := make([]float64, 0, len(hostScores))
+	for host, s := range hostScores {
+		hostRisk := models.HostRisk{Host: host, Findings: len(s), Risk: r.combine(s, "finding")}
+		risk.Hosts = append(risk.Hosts, hostRisk)
+		scores = append(scores, hostRisk.Risk.Score)
+	}
+	sort.Slice(risk.Hosts, func(i, j int) bool {
+		if risk.Hosts[i].Risk.Score != risk.Hosts[j].Risk.Score {
+			return risk.Hosts[i].Risk.Score > risk.Hosts[j].Risk.Score
+		}
+		return risk.Hosts[i].Host < risk.Hosts[j].Host
+	})
+	risk.Risk = r.combine(scores, "host")
+	return risk
+}
+
+// combine starts from the highest score and lets the others close part of the gap
+// to 100, so many small issues add up without ever outweighing one severe issue
+func (r *RiskScorer) combine(scores []float64, unit string) models.RiskScore {
+	if len(scores) == 0 {
+		return models.RiskScore{Explanation: []string{"no " + unit + "s"}}
+	}
+	sorted := append([]float64(nil), scores...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+
+	top := sorted[0]
+	steps := []string{fmt.Sprintf("highest %s scores %.1f", unit, top)}
+	var rest float64
+	for _, s := range sorted[1:] {
+		rest += s
+	}
+	if rest == 0 {
+		return models.RiskScore{Score: top, Explanation: steps}
+	}
+
+	added := (100 - top) * (1 - math.Exp(-rest/r.cfg.Saturation))
+	steps = append(steps, fmt.Sprintf("+%.1f from %d other %ss scoring %.1f together (saturation %.0f)",
+		added, len(sorted)-1, unit, rest, r.cfg.Saturation))
+	return models.RiskScore{Score: round1(top + added), Explanation: steps}
+}
+
+func (r *RiskScorer) criticalityFactor(c models.AssetCriticality) float64 {
+	if c == "" {
+		c = models.AssetCriticalityMedium
+	}
+	if factor, ok := r.cfg.Criticality[c]; ok {
+		return factor
+	}
+	return 1
+}
+
+func criticalityName(c models.AssetCriticality) string {
+	if c == "" {
+		return string(models.AssetCriticalityMedium)
+	}
+	return string(c)
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskScoring(t *testing.T) {
+	r := NewRiskScorer(DefaultRiskConfig())
+
+	findings := []models.Finding{
+		// OpenVAS exploited it, but only with a QoD of 70%
+		{Host: "10.0.0.5", Severity: models.SeverityHigh, CVSS: 7.5, QoD: 70, KnownExploit: true},
+		{Host: "10.0.0.5", Severity: models.SeverityMedium},
+		{Host: "10.0.0.6", Severity: models.SeverityLow, CVSS: 3.1},
+		{Host: "10.0.0.6", Severity: models.SeverityInfo},
+	}
+	risk := r.ScoreFindings(findings, models.AssetCriticalityHigh)
+
+	require.NotNil(t, findings[0].Risk)
+	// 75 x 0.85 x 1.5 x 1.5
+	assert.Equal(t, 100.0, findings[0].Risk.Score)
+	assert.Equal(t, []string{
+		"CVSS base score 7.5",
+		"x0.85 for a quality of detection of 70%",
+		"x1.50 for a known exploit",
+		"x1.50 for high asset criticality",
+		"capped at 100",
+	}, findings[0].Risk.Explanation)
+	assert.Equal(t, 75.0, findings[1].Risk.Score)
+	assert.Equal(t, 0.0, findings[3].Risk.Score)
+
+	require.Len(t, risk.Hosts, 2)
+	assert.Equal(t, "10.0.0.5", risk.Hosts[0].Host)
+	assert.Equal(t, 100.0, risk.Hosts[0].Risk.Score)
+	assert.Equal(t, "10.0.0.6", risk.Hosts[1].Host)
+	assert.Equal(t, 2, risk.Hosts[1].Findings)
+	assert.Equal(t, 46.5, risk.Hosts[1].Risk.Score)
+	assert.Equal(t, 100.0, risk.Risk.Score)
+
+	// Medium criticality leaves the base score alone
+	low := r.ScoreFinding(models.Finding{Severity: models.SeverityLow, CVSS: 3.1}, "")
+	assert.Equal(t, models.RiskScore{Score: 31, Explanation: []string{"CVSS base score 3.1"}}, low)
+
+	assert.True(t, r.ValidCriticality(""))
+	assert.False(t, r.ValidCriticality("extreme"))
+}
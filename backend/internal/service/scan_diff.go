@@ -0,0 +1,221 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"napscan-be/internal/models"
+)
+
+// Diff compares a scan with an earlier scan: which findings are new, resolved or
+// persisting, and which ports nmap saw open or closed since. Suppressed findings
+// are left out on both sides.
+func (s *ScanService) Diff(id, againstID string) (*models.ScanDiff, error) {
+	if againstID == "" {
+		return nil, fmt.Errorf("%w: scan to compare against is required", ErrInvalidScan)
+	}
+	scan, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	against, err := s.Get(againstID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := unsuppressed(dedupFindings(s.scanFindings(scan)))
+	previous := unsuppressed(dedupFindings(s.scanFindings(against)))
+	diff := diffFindings(current, previous, completedTools(scan))
+	diff.ScanID, diff.AgainstID = scan.ID, against.ID
+	diff.Nmap = diffNmap(nmapHosts(against), nmapHosts(scan))
+	return diff, nil
+}
+
+// diffFindings matches the findings of two scans on their fingerprint. Findings of
+// the earlier scan only count as resolved when one of the tools that reported them
+// completed again in the scan.
+func diffFindings(current, previous []models.Finding, rescanned map[string]bool) *models.ScanDiff {
+	diff := &models.ScanDiff{
+		New:        []models.Finding{},
+		Resolved:   []models.Finding{},
+		Persisting: []models.Finding{},
+	}
+
+	seen := make(map[string]bool, len(previous))
+	for _, f := range previous {
+		seen[f.Fingerprint] = true
+	}
+	found := make(map[string]bool, len(current))
+	for _, f := range current {
+		found[f.Fingerprint] = true
+		if seen[f.Fingerprint] {
+			diff.Persisting = append(diff.Persisting, f)
+		} else {
+			diff.New = append(diff.New, f)
+		}
+	}
+
+	for _, f := range previous {
+		if found[f.Fingerprint] {
+			continue
+		}
+		checked := false
+		for _, src := range f.Sources {
+			checked = checked || rescanned[src.Tool]
+		}
+		if checked {
+			diff.Resolved = append(diff.Resolved, f)
+		} else {
+			diff.NotRescanned = append(diff.NotRescanned, f)
+		}
+	}
+
+	for _, list := range [][]models.Finding{diff.New, diff.Resolved, diff.Persisting, diff.NotRescanned} {
+		sortFindings(list)
+	}
+	return diff
+}
+
+// completedTools returns the tools with at least one completed job in the scan
+func completedTools(scan *models.Scan) map[string]bool {
+	tools := make(map[string]bool, len(scan.Jobs))
+	for _, job := range scan.Jobs {
+		if job.Status == models.JobStatusCompleted {
+			tools[job.Tool] = true
+		}
+	}
+	return tools
+}
+
+// nmapHosts collects the ports of the completed nmap jobs of a scan per host, keyed
+// by "port/protocol". It is nil when no nmap job completed.
+func nmapHosts(scan *models.Scan) map[string]map[string]models.Port {
+	var hosts map[string]map[string]models.Port
+	for _, job := range scan.Jobs {
+		if job.Tool != models.ToolNmap || job.Status != models.JobStatusCompleted {
+			continue
+		}
+		res, err := decodeNmapResult(job.Result)
+		if err != nil {
+			continue
+		}
+		if hosts == nil {
+			hosts = make(map[string]map[string]models.Port)
+		}
+		for _, run := range []*models.NmapRun{res.TCP, res.UDP} {
+			if run == nil {
+				continue
+			}
+			for _, host := range run.Hosts {
+				// Down hosts are only listed with -v or in ping sweeps
+				if host.Status.State == "down" {
+					continue
+				}
+				name := hostTarget(host)
+				if hosts[name] == nil {
+					hosts[name] = make(map[string]models.Port)
+				}
+				for _, port := range host.Ports.Ports {
+					hosts[name][port.PortID+"/"+port.Proto] = port
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// diffNmap compares the nmap hosts of two scans. Without nmap results on both sides
+// there is nothing to compare.
+func diffNmap(before, after map[string]map[string]models.Port) models.NmapDiff {
+	var diff models.NmapDiff
+	if before == nil || after == nil {
+		return diff
+	}
+
+	for host := range after {
+		if _, ok := before[host]; !ok {
+			diff.HostsUp = append(diff.HostsUp, host)
+		}
+	}
+	for host, oldPorts := range before {
+		newPorts, ok := after[host]
+		if !ok {
+			diff.HostsDown = append(diff.HostsDown, host)
+			continue
+		}
+		diff.Ports = append(diff.Ports, diffPorts(host, oldPorts, newPorts)...)
+	}
+
+	sort.Strings(diff.HostsUp)
+	sort.Strings(diff.HostsDown)
+	sort.Slice(diff.Ports, func(i, j int) bool {
+		a, b := diff.Ports[i], diff.Ports[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		pa, _ := strconv.Atoi(a.Port)
+		pb, _ := strconv.Atoi(b.Port)
+		return pa < pb
+	})
+	return diff
+}
+
+// diffPorts reports the ports of a host that opened, closed or changed service.
+// Changes between closed and filtered are not reported.
+func diffPorts(host string, before, after map[string]models.Port) []models.PortChange {
+	keys := make(map[string]bool, len(before)+len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var changes []models.PortChange
+	for key := range keys {
+		old, hadOld := before[key]
+		cur, hasCur := after[key]
+		wasOpen := hadOld && old.State.State == "open"
+		isOpen := hasCur && cur.State.State == "open"
+
+		var change models.PortChangeType
+		switch {
+		case isOpen && !wasOpen:
+			change = models.PortOpened
+		case wasOpen && !isOpen:
+			change = models.PortClosed
+		case isOpen && serviceLabel(old.Service) != serviceLabel(cur.Service):
+			change = models.PortServiceChanged
+		default:
+			continue
+		}
+
+		port := cur
+		if !hasCur {
+			port = old
+		}
+		changes = append(changes, models.PortChange{
+			Host:       host,
+			Port:       port.PortID,
+			Protocol:   port.Proto,
+			Change:     change,
+			OldState:   old.State.State,
+			NewState:   cur.State.State,
+			OldService: serviceLabel(old.Service),
+			NewService: serviceLabel(cur.Service),
+		})
+	}
+	return changes
+}
+
+// serviceLabel names a service the way nmap prints it, e.g. "ssl/http"
+func serviceLabel(svc models.Service) string {
+	if svc.Tunnel != "" && svc.Name != "" {
+		return svc.Tunnel + "/" + svc.Name
+	}
+	return svc.Name
+}
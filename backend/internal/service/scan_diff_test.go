@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffScans(t *testing.T) {
+	finding := func(fp, tool string) models.Finding {
+		return models.Finding{Fingerprint: fp, Tool: tool, Sources: []models.FindingSource{{Tool: tool}}}
+	}
+	previous := []models.Finding{finding("a", models.ToolNuclei), finding("b", models.ToolNuclei), finding("c", models.ToolZap)}
+	current := []models.Finding{finding("b", models.ToolNuclei), finding("d", models.ToolNuclei)}
+
+	diff := diffFindings(current, previous, map[string]bool{models.ToolNuclei: true})
+	assert.Equal(t, []models.Finding{current[1]}, diff.New)
+	assert.Equal(t, []models.Finding{current[0]}, diff.Persisting)
+	assert.Equal(t, []models.Finding{previous[0]}, diff.Resolved)
+	// zap did not run again, so its finding may still be there
+	assert.Equal(t, []models.Finding{previous[2]}, diff.NotRescanned)
+
+	port := func(id, state, service string) models.Port {
+		return models.Port{PortID: id, Proto: "tcp", State: models.State{State: state}, Service: models.Service{Name: service}}
+	}
+	before := map[string]map[string]models.Port{
+		"10.0.0.5": {"22/tcp": port("22", "open", "ssh"), "80/tcp": port("80", "open", "http"), "8080/tcp": port("8080", "open", "http-proxy")},
+		"10.0.0.6": {"22/tcp": port("22", "open", "ssh")},
+	}
+	after := map[string]map[string]models.Port{
+		"10.0.0.5": {"22/tcp": port("22", "open", "ssh"), "80/tcp": port("80", "open", "nginx"), "443/tcp": port("443", "open", "https"), "8080/tcp": port("8080", "filtered", "http-proxy")},
+		"10.0.0.7": {},
+	}
+
+	nmap := diffNmap(before, after)
+	assert.Equal(t, []string{"10.0.0.7"}, nmap.HostsUp)
+	assert.Equal(t, []string{"10.0.0.6"}, nmap.HostsDown)
+	assert.Equal(t, []models.PortChange{
+		{Host: "10.0.0.5", Port: "80", Protocol: "tcp", Change: models.PortServiceChanged, OldState: "open", NewState: "open", OldService: "http", NewService: "nginx"},
+		{Host: "10.0.0.5", Port: "443", Protocol: "tcp", Change: models.PortOpened, NewState: "open", NewService: "https"},
+		{Host: "10.0.0.5", Port: "8080", Protocol: "tcp", Change: models.PortClosed, OldState: "open", NewState: "filtered", OldService: "http-proxy", NewService: "http-proxy"},
+	}, nmap.Ports)
+
+	assert.Empty(t, diffNmap(nil, after).Ports)
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"napscan-be/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnknownImportFormat is returned for files that are not a report of a supported tool
+var ErrUnknownImportFormat = errors.New("not an nmap, OpenVAS, nuclei, ZAP, sslyze or ffuf report")
+
+// ImportFile is a native output of a tool uploaded to be imported
+type ImportFile struct {
+	Name    string
+	Content []byte
+}
+
+// importedReport is an imported file read into the result its tool's job returns
+type importedReport struct {
+	tool   string
+	result interface{}
+	// hosts are the hosts the report is about
+	hosts       []string
+	output      string
+	contentType string
+	// startedAt and finishedAt are when the tool ran, when the report says so
+	startedAt, finishedAt time.Time
+}
+
+// Import stores native tool outputs produced elsewhere as a completed scan with one
+// job per file. The files are read with the parsers of the jobs, so their findings
+// are normalized, enriched, triaged and scored like those of a scan run here. The
+// target is taken from the files when they are all about the same host.
+func (s *ScanService) Import(req models.ScanImport, files []ImportFile) (*models.Scan, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: at least one file is required", ErrInvalidScan)
+	}
+	if !s.risk.ValidCriticality(req.AssetCriticality) {
+		return nil, fmt.Errorf("%w: unknown asset criticality %q", ErrInvalidScan, req.AssetCriticality)
+	}
+	target := strings.TrimSpace(req.Target)
+	if target != "" {
+		if _, err := ParseTarget(target); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidScan, err)
+		}
+	}
+
+	// Read every file before anything is stored, so a bad file does not leave half a scan
+	reports := make([]*importedReport, len(files))
+	for i, file := range files {
+		report, err := readImport(file.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidScan, file.Name, err)
+		}
+		reports[i] = report
+	}
+	if target == "" {
+		var err error
+		if target, err = importTarget(reports); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidScan, err)
+		}
+	}
+
+	var tools []string
+	seen := make(map[string]bool)
+	for _, report := range reports {
+		if !seen[report.tool] {
+			seen[report.tool] = true
+			tools = append(tools, report.tool)
+		}
+	}
+
+	now := time.Now()
+	scan := &models.Scan{
+		ID:               uuid.NewString(),
+		Name:             strings.TrimSpace(req.Name),
+		Target:           target,
+		Tools:            tools,
+		AssetCriticality: req.AssetCriticality,
+		Status:           models.ScanStatusQueued,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if scan.Name == "" {
+		scan.Name = "Import " + target
+	}
+	imports := make([]JobImport, len(reports))
+	for i, report := range reports {
+		jobTarget := target
+		if len(report.hosts) == 1 {
+			jobTarget = report.hosts[0]
+		}
+		// The nuclei and ZAP jobs name their target in the result
+		if res, ok := report.result.(map[string]interface{}); ok {
+			if _, ok := res["target"]; ok {
+				res["target"] = jobTarget
+			}
+		}
+		if report.startedAt.IsZero() {
+			report.startedAt = now
+		}
+		if report.finishedAt.IsZero() {
+			report.finishedAt = now
+		}
+		imports[i] = JobImport{
+			Request: models.JobRequest{Tool: report.tool, Target: jobTarget, ScanID: scan.ID},
+			Result:  report.result,
+			Output: &models.ToolOutput{
+				Name:        report.output,
+				ContentType: report.contentType,
+				Content:     files[i].Content,
+			},
+			StartedAt:  report.startedAt,
+			FinishedAt: report.finishedAt,
+		}
+	}
+
+	ctx := context.Background()
+	if err := s.repo.SaveScan(ctx, scan); err != nil {
+		return nil, fmt.Errorf("failed to save scan: %w", err)
+	}
+	if _, err := s.jobs.Import(imports); err != nil {
+		// Take the scan back rather than leave the jobs stored so far as a smaller scan
+		if delErr := s.repo.DeleteScan(ctx, scan.ID); delErr != nil {
+			log.Printf("Failed to delete scan %s of a failed import: %v", scan.ID, delErr)
+		}
+		return nil, fmt.Errorf("failed to import: %w", err)
+	}
+
+	return s.Get(scan.ID)
+}
+
+// importTarget returns the one host all reports are about
+func importTarget(reports []*importedReport) (string, error) {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, report := range reports {
+		for _, host := range report.hosts {
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	if len(hosts) != 1 {
+		return "", fmt.Errorf("the files are about %d hosts, target is required", len(hosts))
+	}
+	if _, err := ParseTarget(hosts[0]); err != nil {
+		return "", fmt.Errorf("target is required: %v", err)
+	}
+	return hosts[0], nil
+}
+
+// readImport detects the tool a native output comes from and reads it
+func readImport(content []byte) (*importedReport, error) {
+	data := bytes.TrimSpace(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")))
+	if len(data) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	var (
+		report *importedReport
+		err    error
+	)
+	switch data[0] {
+	case '<':
+		report, err = readXMLImport(data)
+	case '{', '[':
+		report, err = readJSONImport(data)
+	default:
+		return nil, ErrUnknownImportFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if report.hosts == nil {
+		findings, err := normalizeResult(report.tool, report.result)
+		if err != nil {
+			return nil, err
+		}
+		report.hosts = findingHosts(findings)
+	}
+	return report, nil
+}
+
+// readXMLImport reads an nmap XML report or an OpenVAS XML report
+func readXMLImport(data []byte) (*importedReport, error) {
+	switch xmlRoot(data) {
+	case "nmaprun":
+		run, err := parseNmapXML(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse nmap xml: %w", err)
+		}
+		report := &importedReport{
+			tool:        models.ToolNmap,
+			hosts:       nmapRunHosts(run),
+			contentType: "application/xml",
+		}
+		// Ports of a UDP-only run go where the UDP half of a live scan puts them
+		res := &CombinedScanResponse{TCP: &run}
+		report.output = "nmap_tcp.xml"
+		if len(run.ScanInfo) > 0 && nmapUDPOnly(run.ScanInfo) {
+			res = &CombinedScanResponse{UDP: &run}
+			report.output = "nmap_udp.xml"
+		}
+		report.result = res
+		if run.Start > 0 {
+			report.startedAt = time.Unix(run.Start, 0)
+		}
+		if run.RunStats.Finished.Time > 0 {
+			report.finishedAt = time.Unix(run.RunStats.Finished.Time, 0)
+		}
+		return report, nil
+	case "get_reports_response", "report":
+		content, err := parseGVMDReport(data)
+		if err != nil {
+			return nil, err
+		}
+		return &importedReport{
+			tool:        models.ToolOpenVAS,
+			result:      content,
+			output:      "report.xml",
+			contentType: "application/xml",
+		}, nil
+	}
+	return nil, ErrUnknownImportFormat
+}
+
+// readJSONImport reads the JSON reports of nuclei, ZAP, sslyze and ffuf
+func readJSONImport(data []byte) (*importedReport, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		// nuclei -jsonl writes one object per line, which is not one JSON document
+		results, err := parseNucleiJSONL(data)
+		if err != nil || len(results) == 0 || results[0]["template-id"] == nil {
+			return nil, ErrUnknownImportFormat
+		}
+		return nucleiImport(results, "nuclei.jsonl", "application/x-ndjson"), nil
+	}
+
+	switch {
+	case jsonField(doc, "server_scan_results") != nil:
+		result, err := parseSslyzeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		// The servers are listed whether or not they have issues
+		hosts := []string{}
+		for _, server := range jsonList(jsonField(result, "server_scan_results")) {
+			if host := jsonString(jsonField(server, "server_location", "hostname")); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		return &importedReport{tool: models.ToolSslyze, result: result, hosts: hosts, output: "sslyze.json", contentType: "application/json"}, nil
+	case jsonField(doc, "results") != nil && (jsonField(doc, "commandline") != nil || jsonField(doc, "config") != nil):
+		result, err := parseFfufJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		return &importedReport{tool: models.ToolFfuf, result: result, output: "ffuf.json", contentType: "application/json"}, nil
+	case jsonField(doc, "alerts") != nil || jsonField(doc, "site") != nil:
+		alerts, err := parseZapAlerts(data)
+		if err != nil {
+			return nil, err
+		}
+		return &importedReport{
+			tool:        models.ToolZap,
+			result:      map[string]interface{}{"target": "", "alertsRaw": alerts},
+			output:      "alerts.json",
+			contentType: "application/json",
+		}, nil
+	case jsonField(doc, "template-id") != nil:
+		// A single line of nuclei -jsonl
+		results, err := parseNucleiJSONL(data)
+		if err != nil {
+			return nil, err
+		}
+		return nucleiImport(results, "nuclei.jsonl", "application/x-ndjson"), nil
+	}
+
+	// nuclei -json-export writes the results as one array
+	if list, ok := doc.([]interface{}); ok {
+		results := make([]map[string]interface{}, 0, len(list))
+		for _, item := range list {
+			obj, ok := item.(map[string]interface{})
+			if !ok || obj["template-id"] == nil {
+				return nil, ErrUnknownImportFormat
+			}
+			results = append(results, obj)
+		}
+		return nucleiImport(results, "nuclei.json", "application/json"), nil
+	}
+	return nil, ErrUnknownImportFormat
+}
+
+// nucleiImport wraps nuclei results like the nuclei job does. The run finished with
+// its last result.
+func nucleiImport(results []map[string]interface{}, output, contentType string) *importedReport {
+	report := &importedReport{
+		tool:        models.ToolNuclei,
+		result:      map[string]interface{}{"target": "", "results": results},
+		output:      output,
+		contentType: contentType,
+	}
+	for _, r := range results {
+		if ts, err := time.Parse(time.RFC3339Nano, jsonString(r["timestamp"])); err == nil && ts.After(report.finishedAt) {
+			report.finishedAt = ts
+		}
+	}
+	return report
+}
+
+// xmlRoot returns the name of the root element of an XML document
+func xmlRoot(data []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			return el.Name.Local
+		}
+	}
+}
+
+// nmapUDPOnly reports whether every scan of a run is a UDP scan
+func nmapUDPOnly(info []models.ScanInfo) bool {
+	for _, i := range info {
+		if i.Protocol != "udp" {
+			return false
+		}
+	}
+	return true
+}
+
+// nmapRunHosts returns the network an nmap run swept when its last argument is
+// one, otherwise the hosts it found up
+func nmapRunHosts(run models.NmapRun) []string {
+	if args := strings.Fields(run.Args); len(args) > 0 {
+		if target, err := ParseTarget(args[len(args)-1]); err == nil && target.Kind == TargetCIDR {
+			return []string{target.String()}
+		}
+	}
+	hosts := []string{}
+	for _, host := range run.Hosts {
+		if host.Status.State != "down" {
+			if name := hostTarget(host); name != "" {
+				hosts = append(hosts, name)
+			}
+		}
+	}
+	return hosts
+}
+
+// findingHosts returns the distinct hosts of findings in the order they appear
+func findingHosts(findings []models.Finding) []string {
+	hosts := []string{}
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		if f.Host != "" && !seen[f.Host] {
+			seen[f.Host] = true
+			hosts = append(hosts, f.Host)
+		}
+	}
+	return hosts
+}
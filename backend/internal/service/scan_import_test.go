@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importNmapXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<?xml-stylesheet href="file:///usr/share/nmap/nmap.xsl" type="text/xsl"?>
+<nmaprun scanner="nmap" args="nmap -sV -oX scan.xml 10.0.0.5" start="1700000000" version="7.94">
+<scaninfo type="syn" protocol="tcp" numservices="1000" services="1-1000"/>
+<host><status state="up"/><address addr="10.0.0.5" addrtype="ipv4"/>
+<ports><port protocol="tcp" portid="22"><state state="open"/><service name="ssh" product="OpenSSH" version="8.2p1"/></port></ports></host>
+<runstats><finished time="1700000060" exit="success"/><hosts up="1" down="0" total="1"/></runstats>
+</nmaprun>`
+
+const importNucleiJSONL = `{"template-id":"git-config","info":{"name":"Git Config Disclosure","severity":"medium"},"host":"https://10.0.0.5","matched-at":"https://10.0.0.5/.git/config","timestamp":"2023-11-14T22:15:00.5Z"}
+{"template-id":"tech-detect","info":{"name":"Wappalyzer Technology Detection","severity":"info"},"host":"https://10.0.0.5","matched-at":"https://10.0.0.5/","matcher-name":"nginx","timestamp":"2023-11-14T22:14:00Z"}
+`
+
+const importZapReport = `{"@programName":"ZAP","@version":"2.14.0","site":[{"@name":"https://10.0.0.5","alerts":[
+{"pluginid":"10038","alert":"Content Security Policy (CSP) Header Not Set","name":"Content Security Policy (CSP) Header Not Set","riskdesc":"Medium (High)","desc":"<p>CSP is an added layer of security.</p>","cweid":"693",
+ "instances":[{"uri":"https://10.0.0.5/","method":"GET"},{"uri":"https://10.0.0.5/login","method":"GET","evidence":"none"}]}]}]}`
+
+const importOpenVASReport = `<report id="r1" format_id="a994b278-1f62-11e1-96ac-406186ea4fc5" extension="xml" content_type="text/xml">
+<owner><name>admin</name></owner><name>2023-11-14T22:00:00Z</name>
+<report id="r1"><scan_run_status>Done</scan_run_status><results>
+<result id="x"><name>OpenSSH Multiple Vulnerabilities</name><host>10.0.0.9</host><port>22/tcp</port><threat>High</threat><severity>7.5</severity>
+<nvt oid="1.3.6.1.4.1.25623.1.0.1"><name>OpenSSH Multiple Vulnerabilities</name><refs><ref type="cve" id="CVE-2023-38408"/></refs></nvt></result>
+</results></report></report>`
+
+func TestImportScan(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	enrichment := NewEnrichmentService(t.TempDir())
+	s := NewScanService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), enrichment, NewTriageService(repo, jobs, enrichment), NewSuppressionService(repo, jobs))
+
+	scan, err := s.Import(models.ScanImport{}, []ImportFile{
+		{Name: "scan.xml", Content: []byte(importNmapXML)},
+		{Name: "nuclei.jsonl", Content: []byte(importNucleiJSONL)},
+		{Name: "zap.json", Content: []byte(importZapReport)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", scan.Target, "the files are all about one host")
+	assert.Equal(t, "Import 10.0.0.5", scan.Name)
+	assert.Equal(t, []string{models.ToolNmap, models.ToolNuclei, models.ToolZap}, scan.Tools)
+	assert.Equal(t, models.ScanStatusCompleted, scan.Status)
+	require.Len(t, scan.Jobs, 3)
+	// The jobs finished when the tools did
+	assert.Equal(t, time.Unix(1700000060, 0).UTC(), scan.Jobs[0].FinishedAt.UTC())
+	assert.Equal(t, time.Date(2023, 11, 14, 22, 15, 0, 500000000, time.UTC), scan.Jobs[1].FinishedAt.UTC())
+
+	outputs, err := jobs.Outputs(scan.Jobs[0].ID)
+	require.NoError(t, err)
+	require.Len(t, outputs, 1)
+	assert.Equal(t, "nmap_tcp.xml", outputs[0].Name)
+	output, err := jobs.Output(scan.Jobs[0].ID, "nmap_tcp.xml")
+	require.NoError(t, err)
+	assert.Equal(t, importNmapXML, string(output.Content))
+
+	findings, err := s.Findings(scan.ID, FindingsFilter{})
+	require.NoError(t, err)
+	rules := make(map[string]int)
+	for _, f := range findings {
+		assert.Equal(t, "10.0.0.5", f.Host)
+		rules[f.Tool+" "+f.RuleID]++
+	}
+	assert.Equal(t, map[string]int{
+		"nmap open-port":           1,
+		"nuclei git-config":        1,
+		"nuclei tech-detect:nginx": 1,
+		"zap 10038":                2,
+	}, rules)
+
+	// A report in the GSA download format, for a host given explicitly
+	scan, err = s.Import(models.ScanImport{Name: "Isolated lab", Target: "10.0.0.0/24"}, []ImportFile{{Name: "report.xml", Content: []byte(importOpenVASReport)}})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/24", scan.Target)
+	assert.Equal(t, "10.0.0.9", scan.Jobs[0].Target)
+	findings, err = s.Findings(scan.ID, FindingsFilter{})
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, []string{"CVE-2023-38408"}, findings[0].CVEs)
+	assert.Equal(t, models.SeverityHigh, findings[0].Severity)
+
+	_, err = s.Import(models.ScanImport{}, []ImportFile{{Name: "notes.txt", Content: []byte("nothing to see")}})
+	assert.ErrorIs(t, err, ErrInvalidScan)
+	_, err = s.Import(models.ScanImport{}, []ImportFile{{Name: "other.json", Content: []byte(`{"hello": "world"}`)}})
+	assert.ErrorIs(t, err, ErrInvalidScan)
+	// Without a target, files about different hosts cannot be told apart
+	_, err = s.Import(models.ScanImport{}, []ImportFile{
+		{Name: "scan.xml", Content: []byte(importNmapXML)},
+		{Name: "report.xml", Content: []byte(importOpenVASReport)},
+	})
+	assert.ErrorIs(t, err, ErrInvalidScan)
+
+	scans, err := s.List(10)
+	require.NoError(t, err)
+	assert.Len(t, scans, 2, "rejected imports store nothing")
+}
+
+// failingOutputs fails to store the output of the second job it is asked to
+type failingOutputs struct {
+	*repository.SQLiteRepository
+	saved int
+}
+
+func (r *failingOutputs) SaveOutput(ctx context.Context, output *models.ToolOutput) error {
+	if r.saved++; r.saved == 2 {
+		return errors.New("disk full")
+	}
+	return r.SQLiteRepository.SaveOutput(ctx, output)
+}
+
+func TestImportScanLeavesNothingBehindWhenAFileFailsToStore(t *testing.T) {
+	sqlite, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlite.Close() })
+	repo := &failingOutputs{SQLiteRepository: sqlite}
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	enrichment := NewEnrichmentService(t.TempDir())
+	s := NewScanService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), enrichment, NewTriageService(repo, jobs, enrichment), NewSuppressionService(repo, jobs))
+	finished := 0
+	jobs.OnFinish(func(job *models.Job) { finished++ })
+
+	_, err = s.Import(models.ScanImport{}, []ImportFile{
+		{Name: "scan.xml", Content: []byte(importNmapXML)},
+		{Name: "nuclei.jsonl", Content: []byte(importNucleiJSONL)},
+	})
+	require.Error(t, err)
+	assert.Zero(t, finished, "no job of the import was announced as finished")
+
+	scans, err := s.List(10)
+	require.NoError(t, err)
+	assert.Empty(t, scans)
+	stored, err := jobs.List(repository.JobFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, stored, "the job stored before the failure went with the scan")
+}
@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScanNotFound = errors.New("scan not found")
+	ErrInvalidScan  = errors.New("invalid scan")
+)
+
+// ScanService fans a target out to several tools. Each tool runs as a child job of
+// the scan, so the orchestration keeps going when the browser that started it goes away.
+type ScanService struct {
+	// mu serializes status updates of the same scan coming from several finished jobs
+	mu          sync.Mutex
+	repo        repository.ScanRepository
+	jobs        *JobService
+	risk        *RiskScorer
+	enrichment  *EnrichmentService
+	triage      *TriageService
+	suppression *SuppressionService
+}
+
+func NewScanService(repo repository.ScanRepository, jobs *JobService, risk *RiskScorer, enrichment *EnrichmentService, triage *TriageService, suppression *SuppressionService) *ScanService {
+	s := &ScanService{repo: repo, jobs: jobs, risk: risk, enrichment: enrichment, triage: triage, suppression: suppression}
+	jobs.OnFinish(s.jobFinished)
+	return s
+}
+
+// Create stores the scan and queues one child job per tool. A pipeline scan queues
+// nmap only; the follow-up tools are queued once nmap has found services for them.
+func (s *ScanService) Create(req models.ScanRequest) (*models.Scan, error) {
+	req.Target = strings.TrimSpace(req.Target)
+	if _, err := ParseTarget(req.Target); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScan, err)
+	}
+	if len(req.Tools) == 0 && !req.Pipeline {
+		return nil, fmt.Errorf("%w: at least one tool is required", ErrInvalidScan)
+	}
+	if !s.risk.ValidCriticality(req.AssetCriticality) {
+		return nil, fmt.Errorf("%w: unknown asset criticality %q", ErrInvalidScan, req.AssetCriticality)
+	}
+	if req.Pipeline && len(req.Tools) == 0 {
+		req.Tools = append(append([]string{}, pipelineWebTools...), pipelineTLSTools...)
+	}
+	seen := make(map[string]bool, len(req.Tools))
+	tools := make([]string, 0, len(req.Tools))
+	for _, tool := range req.Tools {
+		if !s.jobs.HasTool(tool) {
+			return nil, fmt.Errorf("%w: unknown tool %q", ErrInvalidScan, tool)
+		}
+		if req.Pipeline && !isPipelineTool(tool) {
+			if tool == models.ToolNmap {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s cannot follow nmap in a pipeline", ErrInvalidScan, tool)
+		}
+		if err := s.jobs.ValidateOptions(tool, req.Options[tool]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidScan, err)
+		}
+		if !seen[tool] {
+			seen[tool] = true
+			tools = append(tools, tool)
+		}
+	}
+	if req.Pipeline {
+		if err := s.jobs.ValidateOptions(models.ToolNmap, req.Options[models.ToolNmap]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidScan, err)
+		}
+	}
+
+	// The follow-ups are listed after nmap, in the order they will be queued
+	initial := tools
+	if req.Pipeline {
+		initial = []string{models.ToolNmap}
+		tools = append(initial, tools...)
+	}
+
+	now := time.Now()
+	scan := &models.Scan{
+		ID:               uuid.NewString(),
+		Name:             strings.TrimSpace(req.Name),
+		Target:           req.Target,
+		Tools:            tools,
+		Options:          req.Options,
+		Pipeline:         req.Pipeline,
+		AssetCriticality: req.AssetCriticality,
+		Status:           models.ScanStatusQueued,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if scan.Name == "" {
+		scan.Name = "Scan " + req.Target
+	}
+	if err := s.repo.SaveScan(context.Background(), scan); err != nil {
+		return nil, fmt.Errorf("failed to save scan: %w", err)
+	}
+
+	for _, tool := range initial {
+		_, err := s.jobs.Submit(models.JobRequest{
+			Tool:    tool,
+			Target:  req.Target,
+			Options: req.Options[tool],
+			ScanID:  scan.ID,
+		})
+		if err != nil {
+			// The jobs queued so far keep running and the scan reports what it got
+			log.Printf("Failed to queue %s job of scan %s: %v", tool, scan.ID, err)
+		}
+	}
+
+	return s.Get(scan.ID)
+}
+
+// Get returns the scan with its jobs and their combined status
+func (s *ScanService) Get(id string) (*models.Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refresh(id)
+}
+
+// List returns the scans, newest first, without their jobs
+func (s *ScanService) List(limit int) ([]*models.Scan, error) {
+	return s.repo.ListScans(context.Background(), limit)
+}
+
+// FindingsFilter selects the findings of a scan
+type FindingsFilter struct {
+	// Dedup merges the reports of the same issue by several tools or jobs into one finding
+	Dedup bool
+	// Statuses keeps the findings in one of these triage statuses, all when empty
+	Statuses []models.TriageStatus
+	// Suppressed includes the findings hidden by a suppression rule
+	Suppressed bool
+}
+
+// Findings returns the normalized, triaged and scored findings of the completed jobs
+// of the scan, most severe first. Jobs whose result cannot be read are skipped.
+func (s *ScanService) Findings(id string, filter FindingsFilter) ([]models.Finding, error) {
+	scan, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := s.scanFindings(scan)
+	if filter.Dedup {
+		findings = dedupFindings(findings)
+	}
+	if !filter.Suppressed {
+		findings = unsuppressed(findings)
+	}
+	if err := s.triage.Attach(findings); err != nil {
+		return nil, err
+	}
+	findings = FilterByTriage(findings, filter.Statuses)
+	s.risk.ScoreFindings(findings, scan.AssetCriticality)
+	sortFindings(findings)
+	return findings, nil
+}
+
+// Risk scores the deduplicated findings of the scan and combines them per host and
+// for the whole scan. Suppressed and dismissed findings do not add to it.
+func (s *ScanService) Risk(id string) (*models.ScanRisk, error) {
+	scan, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := unsuppressed(dedupFindings(s.scanFindings(scan)))
+	if err := s.triage.Attach(findings); err != nil {
+		return nil, err
+	}
+	risk := s.risk.ScoreFindings(findings, scan.AssetCriticality)
+	risk.ScanID = scan.ID
+	return &risk, nil
+}
+
+// scanFindings returns the findings of the completed jobs of a scan, as reported and
+// enriched with what the local feeds know about their CVEs. Findings the suppression
+// rules hid when their job finished are marked, not dropped.
+func (s *ScanService) scanFindings(scan *models.Scan) []models.Finding {
+	findings := []models.Finding{}
+	for _, job := range scan.Jobs {
+		if job.Status != models.JobStatusCompleted {
+			continue
+		}
+		jobFindings, err := s.jobFindings(job)
+		if err != nil {
+			log.Printf("Failed to read findings of job %s: %v", job.ID, err)
+			continue
+		}
+		applyStamped(job, jobFindings)
+		findings = append(findings, jobFindings...)
+	}
+	s.enrichment.Enrich(findings)
+	return findings
+}
+
+// jobFindings returns the findings a job reported, and those of the CVEs known to
+// affect the product versions nmap identified
+func (s *ScanService) jobFindings(job *models.Job) ([]models.Finding, error) {
+	findings, err := NormalizeFindings(job)
+	if err != nil {
+		return nil, err
+	}
+	return append(findings, s.enrichment.VersionFindings(job)...), nil
+}
+
+// unsuppressed returns the findings no suppression rule hides
+func unsuppressed(findings []models.Finding) []models.Finding {
+	visible := make([]models.Finding, 0, len(findings))
+	for _, f := range findings {
+		if f.SuppressedBy == "" {
+			visible = append(visible, f)
+		}
+	}
+	return visible
+}
+
+// Cancel cancels every job of the scan that has not finished yet
+func (s *ScanService) Cancel(id string) (*models.Scan, error) {
+	scan, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range scan.Jobs {
+		if job.Status.IsTerminal() {
+			continue
+		}
+		if _, err := s.jobs.Cancel(job.ID); err != nil && !errors.Is(err, ErrJobFinished) {
+			return nil, err
+		}
+	}
+	return s.Get(id)
+}
+
+func (s *ScanService) jobFinished(job *models.Job) {
+	if job.ScanID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.Status == models.JobStatusCompleted {
+		// The rules in place now decide which findings of the scan stay hidden
+		if findings, err := s.jobFindings(job); err == nil {
+			if err := s.suppression.Stamp(job, findings); err != nil {
+				log.Printf("Failed to store the suppressed findings of job %s: %v", job.ID, err)
+			}
+		}
+	}
+	if job.Tool == models.ToolNmap && job.ParentID == "" && job.Status == models.JobStatusCompleted {
+		if err := s.startFollowUps(job); err != nil {
+			log.Printf("Failed to start follow-up jobs of scan %s: %v", job.ScanID, err)
+		}
+	}
+	if _, err := s.refresh(job.ScanID); err != nil {
+		log.Printf("Failed to update scan %s: %v", job.ScanID, err)
+	}
+}
+
+// startFollowUps queues the pipeline tools against the services the nmap job found.
+// The caller must hold s.mu.
+func (s *ScanService) startFollowUps(nmapJob *models.Job) error {
+	scan, err := s.repo.GetScan(context.Background(), nmapJob.ScanID)
+	if err != nil {
+		return err
+	}
+	if !scan.Pipeline {
+		return nil
+	}
+
+	// The hook runs again when a finished job is replayed, so check before queueing twice
+	jobs, err := s.jobs.List(repository.JobFilter{ScanID: scan.ID})
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.ParentID == nmapJob.ID {
+			return nil
+		}
+	}
+
+	res, err := decodeNmapResult(nmapJob.Result)
+	if err != nil {
+		return err
+	}
+	reqs := followUpJobs(discoverEndpoints(res.TCP), scan.Tools)
+	if len(reqs) == 0 {
+		log.Printf("Scan %s: nmap found no web or TLS services, no follow-up jobs", scan.ID)
+	}
+	for _, req := range reqs {
+		req.Options = scan.Options[req.Tool]
+		req.ScanID = scan.ID
+		req.ParentID = nmapJob.ID
+		if _, err := s.jobs.Submit(req); err != nil {
+			log.Printf("Failed to queue %s job of scan %s: %v", req.Tool, scan.ID, err)
+		}
+	}
+	return nil
+}
+
+// refresh loads the scan and its jobs, and stores the combined status when it changed.
+// The caller must hold s.mu.
+func (s *ScanService) refresh(id string) (*models.Scan, error) {
+	ctx := context.Background()
+	scan, err := s.repo.GetScan(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrScanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.jobs.List(repository.JobFilter{ScanID: id})
+	if err != nil {
+		return nil, err
+	}
+	// Oldest first, in the order the jobs were queued
+	for i, j := 0, len(jobs)-1; i < j; i, j = i+1, j-1 {
+		jobs[i], jobs[j] = jobs[j], jobs[i]
+	}
+	scan.Jobs = jobs
+	scan.Tree = buildJobTree(jobs)
+
+	status, progress := aggregateJobs(jobs)
+	if status != scan.Status || progress != scan.Progress {
+		now := time.Now()
+		scan.Status = status
+		scan.Progress = progress
+		scan.UpdatedAt = now
+		scan.FinishedAt = nil
+		if status.IsTerminal() {
+			scan.FinishedAt = &now
+		}
+		if err := s.repo.SaveScan(ctx, scan); err != nil {
+			return nil, fmt.Errorf("failed to save scan: %w", err)
+		}
+	}
+	return scan, nil
+}
+
+// aggregateJobs combines the states of the child jobs into the state of the scan
+func aggregateJobs(jobs []*models.Job) (models.ScanStatus, int) {
+	if len(jobs) == 0 {
+		return models.ScanStatusFailed, 0
+	}
+
+	var queued, active, completed, cancelled, progress int
+	for _, job := range jobs {
+		progress += job.Progress
+		switch job.Status {
+		case models.JobStatusQueued:
+			queued++
+		case models.JobStatusRunning:
+			active++
+		case models.JobStatusCompleted:
+			completed++
+		case models.JobStatusCancelled:
+			cancelled++
+		}
+	}
+	progress /= len(jobs)
+
+	switch {
+	case queued == len(jobs):
+		return models.ScanStatusQueued, progress
+	case queued+active > 0:
+		return models.ScanStatusRunning, progress
+	case completed == len(jobs):
+		return models.ScanStatusCompleted, 100
+	case cancelled == len(jobs):
+		return models.ScanStatusCancelled, progress
+	case completed > 0:
+		return models.ScanStatusPartial, 100
+	}
+	return models.ScanStatusFailed, 100
+}
+
+// buildJobTree nests every job under the job that queued it
+func buildJobTree(jobs []*models.Job) []*models.JobNode {
+	nodes := make(map[string]*models.JobNode, len(jobs))
+	for _, job := range jobs {
+		nodes[job.ID] = &models.JobNode{
+			ID:       job.ID,
+			Tool:     job.Tool,
+			Target:   job.Target,
+			Status:   job.Status,
+			Progress: job.Progress,
+		}
+	}
+
+	var roots []*models.JobNode
+	for _, job := range jobs {
+		node := nodes[job.ID]
+		if parent, ok := nodes[job.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
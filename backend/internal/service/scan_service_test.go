@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateScanQueuesOneJobPerTool(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	enrichment := NewEnrichmentService(t.TempDir())
+	s := NewScanService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), enrichment, NewTriageService(repo, jobs, enrichment), NewSuppressionService(repo, jobs))
+
+	release := make(chan struct{})
+	run := func(ctx context.Context, run *JobRun) (interface{}, error) {
+		<-release
+		if run.Target() == "down.example.com" {
+			return nil, errors.New("host unreachable")
+		}
+		return map[string]interface{}{"target": run.Target(), "results": []interface{}{}}, nil
+	}
+	jobs.Register(models.ToolNuclei, run)
+	jobs.Register(models.ToolSslyze, run)
+
+	for _, req := range []models.ScanRequest{
+		{Target: "-iL /etc/passwd", Tools: []string{models.ToolNuclei}},
+		{Target: "app.example.com"},
+		{Target: "app.example.com", Tools: []string{"unknown"}},
+		{Target: "app.example.com", Tools: []string{models.ToolNuclei}, AssetCriticality: "vital"},
+	} {
+		_, err := s.Create(req)
+		assert.ErrorIs(t, err, ErrInvalidScan, "%+v", req)
+	}
+
+	scan, err := s.Create(models.ScanRequest{Target: " app.example.com ", Tools: []string{models.ToolNuclei, models.ToolSslyze, models.ToolNuclei}})
+	require.NoError(t, err)
+	assert.Equal(t, "Scan app.example.com", scan.Name)
+	assert.Equal(t, []string{models.ToolNuclei, models.ToolSslyze}, scan.Tools, "a tool listed twice runs once")
+	require.Len(t, scan.Jobs, 2)
+	for _, job := range scan.Jobs {
+		assert.Equal(t, scan.ID, job.ScanID)
+		assert.Equal(t, "app.example.com", job.Target)
+	}
+	assert.Contains(t, []models.ScanStatus{models.ScanStatusQueued, models.ScanStatusRunning}, scan.Status)
+
+	down, err := s.Create(models.ScanRequest{Target: "down.example.com", Tools: []string{models.ToolNuclei}})
+	require.NoError(t, err)
+
+	close(release)
+	waitForStatus(t, jobs, scan.Jobs[0].ID, models.JobStatusCompleted)
+	waitForStatus(t, jobs, scan.Jobs[1].ID, models.JobStatusCompleted)
+	waitForStatus(t, jobs, down.Jobs[0].ID, models.JobStatusFailed)
+
+	scan, err = s.Get(scan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanStatusCompleted, scan.Status)
+	assert.Equal(t, 100, scan.Progress)
+	assert.NotNil(t, scan.FinishedAt)
+	down, err = s.Get(down.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanStatusFailed, down.Status)
+
+	_, err = s.Get("missing")
+	assert.ErrorIs(t, err, ErrScanNotFound)
+}
+
+func TestAggregateJobs(t *testing.T) {
+	job := func(status models.JobStatus, progress int) *models.Job {
+		return &models.Job{Status: status, Progress: progress}
+	}
+	for _, tt := range []struct {
+		name     string
+		jobs     []*models.Job
+		status   models.ScanStatus
+		progress int
+	}{
+		{"no jobs", nil, models.ScanStatusFailed, 0},
+		{"all queued", []*models.Job{job(models.JobStatusQueued, 0), job(models.JobStatusQueued, 0)}, models.ScanStatusQueued, 0},
+		{"one running", []*models.Job{job(models.JobStatusRunning, 50), job(models.JobStatusQueued, 0)}, models.ScanStatusRunning, 25},
+		{"one left to run", []*models.Job{job(models.JobStatusCompleted, 100), job(models.JobStatusQueued, 0)}, models.ScanStatusRunning, 50},
+		{"all completed", []*models.Job{job(models.JobStatusCompleted, 100), job(models.JobStatusCompleted, 100)}, models.ScanStatusCompleted, 100},
+		{"completed and failed", []*models.Job{job(models.JobStatusCompleted, 100), job(models.JobStatusFailed, 30)}, models.ScanStatusPartial, 100},
+		{"completed and cancelled", []*models.Job{job(models.JobStatusCompleted, 100), job(models.JobStatusCancelled, 10)}, models.ScanStatusPartial, 100},
+		{"completed and interrupted", []*models.Job{job(models.JobStatusCompleted, 100), job(models.JobStatusInterrupted, 40)}, models.ScanStatusPartial, 100},
+		{"all cancelled", []*models.Job{job(models.JobStatusCancelled, 20), job(models.JobStatusCancelled, 0)}, models.ScanStatusCancelled, 10},
+		{"all failed", []*models.Job{job(models.JobStatusFailed, 0), job(models.JobStatusFailed, 60)}, models.ScanStatusFailed, 100},
+		{"failed and cancelled", []*models.Job{job(models.JobStatusFailed, 0), job(models.JobStatusCancelled, 0)}, models.ScanStatusFailed, 100},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			status, progress := aggregateJobs(tt.jobs)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.progress, progress)
+		})
+	}
+}
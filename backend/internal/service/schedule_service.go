@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+// scheduleTick is how often due schedules are checked
+const scheduleTick = 30 * time.Second
+
+// ScheduleService starts a scan of the schedule's tools when its cron expression fires,
+// so every run can be diffed against the one before. A schedule never overlaps itself:
+// while the scan of the previous run is still queued or running, the next firing is
+// recorded as skipped.
+type ScheduleService struct {
+	// mu serializes the scheduler loop with edits, so a firing never works on a stale schedule
+	mu    sync.Mutex
+	repo  repository.ScheduleRepository
+	jobs  *JobService
+	scans *ScanService
+}
+
+func NewScheduleService(repo repository.ScheduleRepository, jobs *JobService, scans *ScanService) *ScheduleService {
+	return &ScheduleService{repo: repo, jobs: jobs, scans: scans}
+}
+
+// Start runs the scheduler loop until ctx is cancelled. Runs missed while the
+// backend was down are caught up once on the first tick.
+func (s *ScheduleService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(scheduleTick)
+		defer ticker.Stop()
+
+		s.tick(time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.tick(now)
+			}
+		}
+	}()
+}
+
+// List returns the schedules owned by owner
+func (s *ScheduleService) List(owner string) ([]*models.Schedule, error) {
+	return s.repo.ListSchedules(context.Background(), owner)
+}
+
+// Get returns a schedule if it belongs to owner
+func (s *ScheduleService) Get(owner, id string) (*models.Schedule, error) {
+	schedule, err := s.repo.GetSchedule(context.Background(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && schedule.Owner != owner) {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, err
+}
+
+// Create validates req and stores a new schedule for owner
+func (s *ScheduleService) Create(owner string, req models.ScheduleRequest) (*models.Schedule, error) {
+	now := time.Now()
+	schedule := &models.Schedule{
+		ID:        uuid.NewString(),
+		Owner:     owner,
+		CreatedAt: now,
+	}
+	if err := s.apply(schedule, req, now); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.repo.SaveSchedule(context.Background(), schedule); err != nil {
+		return nil, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// Update replaces a schedule of owner and recomputes its next run
+func (s *ScheduleService) Update(owner, id string, req models.ScheduleRequest) (*models.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, err := s.Get(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(schedule, req, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveSchedule(context.Background(), schedule); err != nil {
+		return nil, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// Delete removes a schedule of owner together with its run history.
+// Jobs that were already queued keep running.
+func (s *ScheduleService) Delete(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Get(owner, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteSchedule(context.Background(), id)
+}
+
+// Runs returns the run history of a schedule of owner, newest first
+func (s *ScheduleService) Runs(owner, id string, limit int) ([]*models.ScheduleRun, error) {
+	if _, err := s.Get(owner, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListScheduleRuns(context.Background(), id, repository.ScheduleRunFilter{Limit: limit})
+}
+
+// apply validates req and copies it onto schedule
+func (s *ScheduleService) apply(schedule *models.Schedule, req models.ScheduleRequest, now time.Time) error {
+	req.Cron = strings.TrimSpace(req.Cron)
+	req.Target = strings.TrimSpace(req.Target)
+
+	spec, err := cron.ParseStandard(req.Cron)
+	if err != nil {
+		return fmt.Errorf("%w: cron: %v", ErrInvalidSchedule, err)
+	}
+	if _, err := ParseTarget(req.Target); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if len(req.Tools) == 0 {
+		return fmt.Errorf("%w: at least one tool is required", ErrInvalidSchedule)
+	}
+	seen := make(map[string]bool, len(req.Tools))
+	tools := make([]string, 0, len(req.Tools))
+	for _, tool := range req.Tools {
+		if !s.jobs.HasTool(tool) {
+			return fmt.Errorf("%w: unknown tool %q", ErrInvalidSchedule, tool)
+		}
+		if err := s.jobs.ValidateOptions(tool, req.Options); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+		if !seen[tool] {
+			seen[tool] = true
+			tools = append(tools, tool)
+		}
+	}
+
+	schedule.Name = strings.TrimSpace(req.Name)
+	if schedule.Name == "" {
+		schedule.Name = "Scan " + req.Target
+	}
+	schedule.Cron = req.Cron
+	schedule.Target = req.Target
+	schedule.Tools = tools
+	schedule.Options = req.Options
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		next := spec.Next(now)
+		schedule.NextRunAt = &next
+	}
+	schedule.UpdatedAt = now
+	return nil
+}
+
+// tick fires every enabled schedule that is due and settles finished runs
+func (s *ScheduleService) tick(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := context.Background()
+	schedules, err := s.repo.ListSchedules(ctx, "")
+	if err != nil {
+		log.Printf("Scheduler: failed to list schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		busy, err := s.settleRuns(ctx, schedule.ID)
+		if err != nil {
+			log.Printf("Scheduler: failed to check runs of schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		if !schedule.Enabled || schedule.NextRunAt == nil || schedule.NextRunAt.After(now) {
+			continue
+		}
+
+		if busy {
+			finished := now
+			s.saveRun(ctx, &models.ScheduleRun{
+				ID:         uuid.NewString(),
+				ScheduleID: schedule.ID,
+				Status:     models.ScheduleRunSkipped,
+				JobIDs:     []string{},
+				Message:    "Previous run still in progress",
+				StartedAt:  now,
+				FinishedAt: &finished,
+			})
+		} else {
+			s.fire(ctx, schedule, now)
+		}
+
+		schedule.LastRunAt = &now
+		if spec, err := cron.ParseStandard(schedule.Cron); err == nil {
+			next := spec.Next(now)
+			schedule.NextRunAt = &next
+		} else {
+			schedule.NextRunAt = nil
+		}
+		if err := s.repo.SaveSchedule(ctx, schedule); err != nil {
+			log.Printf("Scheduler: failed to save schedule %s: %v", schedule.ID, err)
+		}
+	}
+}
+
+// fire starts a scan with one job per tool of the schedule and records the run
+func (s *ScheduleService) fire(ctx context.Context, schedule *models.Schedule, now time.Time) {
+	run := &models.ScheduleRun{
+		ID:         uuid.NewString(),
+		ScheduleID: schedule.ID,
+		Status:     models.ScheduleRunRunning,
+		JobIDs:     []string{},
+		StartedAt:  now,
+	}
+
+	// The options of a schedule apply to each of its tools
+	options := make(map[string]map[string]string, len(schedule.Tools))
+	for _, tool := range schedule.Tools {
+		options[tool] = schedule.Options
+	}
+	scan, err := s.scans.Create(models.ScanRequest{
+		Name:    schedule.Name,
+		Target:  schedule.Target,
+		Tools:   schedule.Tools,
+		Options: options,
+	})
+	if err != nil {
+		finished := now
+		run.Status = models.ScheduleRunFailed
+		run.Message = err.Error()
+		run.FinishedAt = &finished
+		s.saveRun(ctx, run)
+		return
+	}
+
+	run.ScanID = scan.ID
+	for _, job := range scan.Jobs {
+		run.JobIDs = append(run.JobIDs, job.ID)
+	}
+	if len(run.JobIDs) == 0 {
+		finished := now
+		run.Status = models.ScheduleRunFailed
+		run.Message = "No job could be queued"
+		run.FinishedAt = &finished
+	}
+	s.saveRun(ctx, run)
+}
+
+// settleRuns marks the running runs of a schedule finished once their scans are,
+// and reports whether a run is still in progress
+func (s *ScheduleService) settleRuns(ctx context.Context, scheduleID string) (bool, error) {
+	runs, err := s.repo.ListScheduleRuns(ctx, scheduleID, repository.ScheduleRunFilter{Status: models.ScheduleRunRunning})
+	if err != nil {
+		return false, err
+	}
+
+	busy := false
+	for _, run := range runs {
+		done, err := s.settleRun(ctx, run)
+		if err != nil {
+			return false, err
+		}
+		busy = busy || !done
+	}
+	return busy, nil
+}
+
+// settleRun records the outcome of run when its scan has finished
+func (s *ScheduleService) settleRun(ctx context.Context, run *models.ScheduleRun) (bool, error) {
+	scan, err := s.scans.Get(run.ScanID)
+	if err != nil && !errors.Is(err, ErrScanNotFound) {
+		return false, err
+	}
+	if scan != nil && !scan.Status.IsTerminal() {
+		return false, nil
+	}
+
+	now := time.Now()
+	run.FinishedAt = &now
+	switch {
+	case scan == nil:
+		run.Status = models.ScheduleRunFailed
+		run.Message = "Scan was deleted"
+	case scan.Status == models.ScanStatusCompleted:
+		run.Status = models.ScheduleRunCompleted
+	default:
+		run.Status = models.ScheduleRunFailed
+		if run.Message == "" {
+			run.Message = "Scan " + string(scan.Status)
+		}
+	}
+	s.saveRun(ctx, run)
+	return true, nil
+}
+
+func (s *ScheduleService) saveRun(ctx context.Context, run *models.ScheduleRun) {
+	if err := s.repo.SaveScheduleRun(ctx, run); err != nil {
+		log.Printf("Scheduler: failed to save run of schedule %s: %v", run.ScheduleID, err)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleSkipsOverlappingRuns(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	release := make(chan struct{})
+	jobs.Register(models.ToolNmap, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	enrichment := NewEnrichmentService(t.TempDir())
+	scans := NewScanService(repo, jobs, NewRiskScorer(DefaultRiskConfig()), enrichment, NewTriageService(repo, jobs, enrichment), NewSuppressionService(repo, jobs))
+	s := NewScheduleService(repo, jobs, scans)
+
+	_, err = s.Create("alice", models.ScheduleRequest{Cron: "@hourly", Target: "10.0.0.1", Tools: []string{"unknown"}})
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+
+	schedule, err := s.Create("alice", models.ScheduleRequest{Cron: "0 3 * * 1", Target: "10.0.0.1", Tools: []string{models.ToolNmap}})
+	require.NoError(t, err)
+	_, err = s.Get("bob", schedule.ID)
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+
+	// First firing queues the job, the second one finds it still running
+	first := schedule.NextRunAt.Add(time.Second)
+	s.tick(first)
+	s.tick(first.Add(7 * 24 * time.Hour))
+
+	runs, err := s.Runs("alice", schedule.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, models.ScheduleRunSkipped, runs[0].Status)
+	assert.Equal(t, models.ScheduleRunRunning, runs[1].Status)
+	require.Len(t, runs[1].JobIDs, 1)
+	// The run is a scan of its own, which later runs can be diffed against
+	scan, err := scans.Get(runs[1].ScanID)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", scan.Target)
+	require.Len(t, scan.Jobs, 1)
+	assert.Equal(t, runs[1].JobIDs[0], scan.Jobs[0].ID)
+	assert.Empty(t, runs[0].ScanID)
+
+	close(release)
+	waitForStatus(t, jobs, runs[1].JobIDs[0], models.JobStatusCompleted)
+	s.tick(time.Now())
+
+	runs, err = s.Runs("alice", schedule.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleRunCompleted, runs[1].Status)
+	scan, err = scans.Get(runs[1].ScanID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanStatusCompleted, scan.Status)
+
+	stored, err := s.Get("alice", schedule.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.NextRunAt.After(first.Add(7*24*time.Hour)))
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"napscan-be/internal/models"
 )
 
 type SslyzeService struct{}
@@ -16,15 +18,26 @@ func NewSslyzeService() *SslyzeService {
 	return &SslyzeService{}
 }
 
-func (s *SslyzeService) ExecuteScan(ctx context.Context, target string) (interface{}, error) {
-	// Create temporary file for JSON output
-	tmpFile := filepath.Join(os.TempDir(), "sslyze_"+time.Now().Format("20060102150405")+".json")
+func (s *SslyzeService) ExecuteScan(ctx context.Context, target *Target) (interface{}, error) {
+	address, err := target.Address("443")
+	if err != nil {
+		return nil, err
+	}
+
+	// Create temporary file for JSON output, one per job since several sslyze jobs run at once
+	f, err := os.CreateTemp("", "sslyze_*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sslyze output file: %w", err)
+	}
+	tmpFile := f.Name()
+	f.Close()
 	defer os.Remove(tmpFile)
 
-	cmd := exec.CommandContext(ctx,
+	cmd := newToolCommand(ctx,
 		"sslyze",
 		"--json_out", tmpFile,
-		target,
+		"--",
+		address,
 	)
 
 	output, err := cmd.CombinedOutput()
@@ -36,7 +49,12 @@ func (s *SslyzeService) ExecuteScan(ctx context.Context, target string) (interfa
 	if err != nil {
 		return nil, fmt.Errorf("failed to read sslyze output: %w", err)
 	}
+	recordOutput(ctx, "sslyze.json", "application/json", jsonData)
+	return parseSslyzeJSON(jsonData)
+}
 
+// parseSslyzeJSON reads the report sslyze writes with --json_out
+func parseSslyzeJSON(jsonData []byte) (interface{}, error) {
 	var result interface{}
 	// SSLyze output might be large, but we parse it to ensure it's valid JSON before sending
 	if err := json.Unmarshal(jsonData, &result); err != nil {
@@ -45,3 +63,110 @@ func (s *SslyzeService) ExecuteScan(ctx context.Context, target string) (interfa
 
 	return result, nil
 }
+
+// RunJob runs sslyze as a background job
+func (s *SslyzeService) RunJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+
+	target, err := ParseTarget(run.Target())
+	if err != nil {
+		return nil, err
+	}
+	return s.ExecuteScan(ctx, target)
+}
+
+// sslyzeCheck is a known-vulnerable TLS configuration looked for in sslyze results
+type sslyzeCheck struct {
+	command  string
+	ruleID   string
+	title    string
+	severity models.Severity
+	cves     []string
+	// vulnerable reports whether the result of command shows the issue
+	vulnerable func(result interface{}) bool
+}
+
+func acceptsCipherSuites(result interface{}) bool {
+	return len(jsonList(jsonField(result, "accepted_cipher_suites"))) > 0
+}
+
+var sslyzeChecks = []sslyzeCheck{
+	{"heartbleed", "heartbleed", "Vulnerable to Heartbleed", models.SeverityCritical, []string{"CVE-2014-0160"},
+		func(r interface{}) bool { return jsonBool(jsonField(r, "is_vulnerable_to_heartbleed")) }},
+	{"openssl_ccs_injection", "ccs-injection", "Vulnerable to OpenSSL CCS injection", models.SeverityHigh, []string{"CVE-2014-0224"},
+		func(r interface{}) bool { return jsonBool(jsonField(r, "is_vulnerable_to_ccs_injection")) }},
+	{"robot", "robot", "Vulnerable to the ROBOT attack", models.SeverityHigh, []string{"CVE-2017-13099"},
+		func(r interface{}) bool {
+			return strings.HasPrefix(jsonString(jsonField(r, "robot_result")), "VULNERABLE")
+		}},
+	{"ssl_2_0_cipher_suites", "sslv2-enabled", "SSL 2.0 is enabled", models.SeverityHigh, nil, acceptsCipherSuites},
+	{"ssl_3_0_cipher_suites", "sslv3-enabled", "SSL 3.0 is enabled", models.SeverityHigh, []string{"CVE-2014-3566"}, acceptsCipherSuites},
+	{"tls_1_0_cipher_suites", "tls10-enabled", "TLS 1.0 is enabled", models.SeverityLow, nil, acceptsCipherSuites},
+	{"tls_1_1_cipher_suites", "tls11-enabled", "TLS 1.1 is enabled", models.SeverityLow, nil, acceptsCipherSuites},
+}
+
+// normalizeSslyzeResult turns the TLS issues found by an sslyze job into findings.
+// Both the sslyze 5 layout (scan_result.<command>.result) and the older
+// scan_commands_results layout are read.
+func normalizeSslyzeResult(result interface{}) ([]models.Finding, error) {
+	var doc interface{}
+	if err := decodeJobResult(result, &doc); err != nil {
+		return nil, err
+	}
+
+	findings := []models.Finding{}
+	for i, server := range jsonList(jsonField(doc, "server_scan_results")) {
+		host := jsonString(jsonField(server, "server_location", "hostname"))
+		port := ""
+		if p, ok := jsonField(server, "server_location", "port").(float64); ok {
+			port = strconv.Itoa(int(p))
+		}
+
+		key := "scan_result"
+		commands := jsonField(server, key)
+		if commands == nil {
+			key = "scan_commands_results"
+			commands = jsonField(server, key)
+		}
+		command := func(name string) interface{} {
+			c := jsonField(commands, name)
+			if r := jsonField(c, "result"); r != nil {
+				return r
+			}
+			return c
+		}
+		add := func(ruleID, title string, severity models.Severity, cves []string, ref string) {
+			findings = append(findings, models.Finding{
+				RuleID:   ruleID,
+				Title:    title,
+				Severity: severity,
+				CVEs:     cves,
+				Host:     host,
+				Port:     port,
+				RawRef:   fmt.Sprintf("server_scan_results[%d].%s.%s", i, key, ref),
+			})
+		}
+
+		for _, check := range sslyzeChecks {
+			if check.vulnerable(command(check.command)) {
+				add(check.ruleID, check.title, check.severity, check.cves, check.command)
+			}
+		}
+
+		for _, deployment := range jsonList(jsonField(command("certificate_info"), "certificate_deployments")) {
+			if matches, ok := jsonField(deployment, "leaf_certificate_subject_matches_hostname").(bool); ok && !matches {
+				add("certificate-hostname-mismatch", "Certificate does not match the hostname", models.SeverityMedium, nil, "certificate_info")
+			}
+			validations := jsonList(jsonField(deployment, "path_validation_results"))
+			trusted := false
+			for _, v := range validations {
+				trusted = trusted || jsonBool(jsonField(v, "was_validation_successful"))
+			}
+			if len(validations) > 0 && !trusted {
+				add("certificate-untrusted", "Certificate is not trusted", models.SeverityMedium, nil, "certificate_info")
+			}
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSuppressionNotFound = errors.New("suppression rule not found")
+	ErrInvalidSuppression  = errors.New("invalid suppression rule")
+)
+
+// compiledRule is a suppression rule ready to be matched
+type compiledRule struct {
+	rule *models.SuppressionRule
+	path *regexp.Regexp
+}
+
+// SuppressionService hides findings matching admin-defined rules. The rules are applied
+// when a job of a scan finishes and the outcome is stored with the job, so changing the
+// rules later leaves the scans ingested before as they were. Suppressed findings are
+// kept and record the rule that hid them.
+type SuppressionService struct {
+	repo repository.SuppressionRepository
+	jobs *JobService
+	// mu guards rules, the compiled copy of the stored rules
+	mu    sync.RWMutex
+	rules []compiledRule
+}
+
+func NewSuppressionService(repo repository.SuppressionRepository, jobs *JobService) *SuppressionService {
+	return &SuppressionService{repo: repo, jobs: jobs}
+}
+
+// Load compiles the stored rules. It is called on start; changes made through the
+// service reload them.
+func (s *SuppressionService) Load() error {
+	stored, err := s.repo.ListSuppressionRules(context.Background())
+	if err != nil {
+		return err
+	}
+	rules := make([]compiledRule, 0, len(stored))
+	for _, rule := range stored {
+		c, err := compileRule(rule)
+		if err != nil {
+			return fmt.Errorf("suppression rule %s: %w", rule.ID, err)
+		}
+		rules = append(rules, c)
+	}
+
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+	return nil
+}
+
+// List returns every rule, oldest first
+func (s *SuppressionService) List() ([]*models.SuppressionRule, error) {
+	return s.repo.ListSuppressionRules(context.Background())
+}
+
+// Get returns a rule
+func (s *SuppressionService) Get(id string) (*models.SuppressionRule, error) {
+	rule, err := s.repo.GetSuppressionRule(context.Background(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrSuppressionNotFound
+	}
+	return rule, err
+}
+
+// Create validates req and stores a new rule for userID
+func (s *SuppressionService) Create(userID string, req models.SuppressionRuleRequest) (*models.SuppressionRule, error) {
+	now := time.Now()
+	rule := &models.SuppressionRule{
+		ID:        uuid.NewString(),
+		CreatedBy: userID,
+		CreatedAt: now,
+	}
+	if err := s.save(rule, req, now); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Update replaces the matchers of a rule
+func (s *SuppressionService) Update(id string, req models.SuppressionRuleRequest) (*models.SuppressionRule, error) {
+	rule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(rule, req, time.Now()); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Delete removes a rule. The findings it hid in scans ingested before stay hidden.
+func (s *SuppressionService) Delete(id string) error {
+	err := s.repo.DeleteSuppressionRule(context.Background(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSuppressionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.Load()
+}
+
+// Apply marks the findings matched by a rule that has not expired with the ID of
+// the first such rule
+func (s *SuppressionService) Apply(findings []models.Finding) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for i := range findings {
+		findings[i].SuppressedBy = ""
+		for _, c := range s.rules {
+			if c.matches(findings[i], now) {
+				findings[i].SuppressedBy = c.rule.ID
+				break
+			}
+		}
+	}
+}
+
+// Stamp applies the rules to the findings of a finished job and stores which rule hid
+// which of them with the job
+func (s *SuppressionService) Stamp(job *models.Job, findings []models.Finding) error {
+	s.Apply(findings)
+	var suppressed map[string]string
+	for _, f := range findings {
+		if f.SuppressedBy == "" {
+			continue
+		}
+		if suppressed == nil {
+			suppressed = make(map[string]string)
+		}
+		suppressed[suppressionKey(f)] = f.SuppressedBy
+	}
+	job.Suppressed = suppressed
+	return s.repo.SaveJobSuppressions(context.Background(), job.ID, suppressed)
+}
+
+// applyStamped marks the findings of a job with the rules stamped on it when it finished
+func applyStamped(job *models.Job, findings []models.Finding) {
+	for i := range findings {
+		findings[i].SuppressedBy = job.Suppressed[suppressionKey(findings[i])]
+	}
+}
+
+// suppressionKey tells the findings of a job apart: the open port and the CVEs of the
+// product nmap found on it share a raw reference, zap alerts of one rule a fingerprint
+func suppressionKey(f models.Finding) string {
+	return f.Fingerprint + " " + f.RawRef
+}
+
+// save validates req, copies it onto rule, stores it and reloads the rules
+func (s *SuppressionService) save(rule *models.SuppressionRule, req models.SuppressionRuleRequest, now time.Time) error {
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Tool = strings.TrimSpace(req.Tool)
+	rule.RuleID = strings.TrimSpace(req.RuleID)
+	rule.HostGlob = strings.ToLower(strings.TrimSpace(req.HostGlob))
+	rule.Port = strings.TrimSpace(req.Port)
+	rule.PathRegex = strings.TrimSpace(req.PathRegex)
+	rule.Severity = models.Severity(strings.ToLower(string(req.Severity)))
+	rule.Reason = strings.TrimSpace(req.Reason)
+	rule.ExpiresAt = req.ExpiresAt
+	rule.UpdatedAt = now
+
+	if rule.Tool != "" && !s.jobs.HasTool(rule.Tool) {
+		return fmt.Errorf("%w: unknown tool %q", ErrInvalidSuppression, rule.Tool)
+	}
+	if rule.Severity != "" && severityRank(rule.Severity) == len(models.Severities) {
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidSuppression, rule.Severity)
+	}
+	if rule.Tool == "" && rule.RuleID == "" && rule.HostGlob == "" && rule.Port == "" && rule.PathRegex == "" && rule.Severity == "" {
+		return fmt.Errorf("%w: at least one matcher is required", ErrInvalidSuppression)
+	}
+	if rule.ExpiresAt != nil && !rule.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidSuppression)
+	}
+	if _, err := compileRule(rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSuppression, err)
+	}
+	if rule.Name == "" {
+		rule.Name = describeRule(rule)
+	}
+
+	if err := s.repo.SaveSuppressionRule(context.Background(), rule); err != nil {
+		return fmt.Errorf("failed to save suppression rule: %w", err)
+	}
+	return s.Load()
+}
+
+func compileRule(rule *models.SuppressionRule) (compiledRule, error) {
+	c := compiledRule{rule: rule}
+	if rule.HostGlob != "" {
+		if _, err := path.Match(rule.HostGlob, ""); err != nil {
+			return c, fmt.Errorf("host_glob: %v", err)
+		}
+	}
+	if rule.PathRegex != "" {
+		re, err := regexp.Compile(rule.PathRegex)
+		if err != nil {
+			return c, fmt.Errorf("path_regex: %v", err)
+		}
+		c.path = re
+	}
+	return c, nil
+}
+
+func (c compiledRule) matches(f models.Finding, now time.Time) bool {
+	r := c.rule
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(now) {
+		return false
+	}
+	if r.Tool != "" && r.Tool != f.Tool {
+		return false
+	}
+	if r.RuleID != "" && f.RuleID != r.RuleID && !strings.HasPrefix(f.RuleID, r.RuleID+":") {
+		return false
+	}
+	if r.HostGlob != "" {
+		host := strings.TrimSuffix(strings.Trim(strings.ToLower(f.Host), "[]"), ".")
+		if ok, _ := path.Match(r.HostGlob, host); !ok {
+			return false
+		}
+	}
+	if r.Port != "" && r.Port != f.Port {
+		return false
+	}
+	if c.path != nil && (f.URL == "" || !c.path.MatchString(urlPath(f.URL))) {
+		return false
+	}
+	if r.Severity != "" && r.Severity != f.Severity {
+		return false
+	}
+	return true
+}
+
+// describeRule names a rule after its matchers, e.g. "zap 10096 on *.staging.example.com"
+func describeRule(r *models.SuppressionRule) string {
+	var parts []string
+	if r.Tool != "" {
+		parts = append(parts, r.Tool)
+	}
+	if r.RuleID != "" {
+		parts = append(parts, r.RuleID)
+	}
+	if r.Severity != "" {
+		parts = append(parts, string(r.Severity))
+	}
+	if r.HostGlob != "" {
+		parts = append(parts, "on "+r.HostGlob)
+	}
+	if r.Port != "" {
+		parts = append(parts, "port "+r.Port)
+	}
+	if r.PathRegex != "" {
+		parts = append(parts, "path ~ "+r.PathRegex)
+	}
+	return strings.Join(parts, " ")
+}
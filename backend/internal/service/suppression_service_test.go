@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuppressionRules(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	for _, tool := range []string{models.ToolZap, models.ToolNuclei, models.ToolOpenVAS} {
+		jobs.Register(tool, func(ctx context.Context, run *JobRun) (interface{}, error) { return nil, nil })
+	}
+	s := NewSuppressionService(repo, jobs)
+
+	_, err = s.Create("alice", models.SuppressionRuleRequest{Name: "everything"})
+	assert.ErrorIs(t, err, ErrInvalidSuppression, "a rule needs a matcher")
+	_, err = s.Create("alice", models.SuppressionRuleRequest{PathRegex: "("})
+	assert.ErrorIs(t, err, ErrInvalidSuppression)
+	_, err = s.Create("alice", models.SuppressionRuleRequest{Tool: "burp"})
+	assert.ErrorIs(t, err, ErrInvalidSuppression)
+
+	zap, err := s.Create("alice", models.SuppressionRuleRequest{Tool: models.ToolZap, RuleID: "10096", HostGlob: "*.staging.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "zap 10096 on *.staging.example.com", zap.Name)
+	tech, err := s.Create("alice", models.SuppressionRuleRequest{Tool: models.ToolNuclei, RuleID: "tech-detect"})
+	require.NoError(t, err)
+	ssh, err := s.Create("alice", models.SuppressionRuleRequest{Tool: models.ToolOpenVAS, RuleID: "1.3.6.1.4.1.25623.1.0.105611", Port: "22"})
+	require.NoError(t, err)
+
+	findings := []models.Finding{
+		{Tool: models.ToolZap, RuleID: "10096", Host: "App.Staging.Example.com"},
+		{Tool: models.ToolZap, RuleID: "10096", Host: "app.example.com"},
+		{Tool: models.ToolNuclei, RuleID: "tech-detect:nginx", Host: "app.example.com"},
+		{Tool: models.ToolNuclei, RuleID: "tech-detect-extra", Host: "app.example.com"},
+		{Tool: models.ToolOpenVAS, RuleID: "1.3.6.1.4.1.25623.1.0.105611", Host: "10.0.0.5", Port: "22", Fingerprint: "ssh-22"},
+		{Tool: models.ToolOpenVAS, RuleID: "1.3.6.1.4.1.25623.1.0.105611", Host: "10.0.0.5", Port: "2222", Fingerprint: "ssh-2222"},
+	}
+	s.Apply(findings)
+	var suppressedBy []string
+	for _, f := range findings {
+		suppressedBy = append(suppressedBy, f.SuppressedBy)
+	}
+	assert.Equal(t, []string{zap.ID, "", tech.ID, "", ssh.ID, ""}, suppressedBy)
+
+	// A report nothing suppresses keeps the merged finding visible
+	merged := dedupFindings([]models.Finding{
+		{Fingerprint: "fp", Tool: models.ToolNuclei, Severity: models.SeverityHigh, SuppressedBy: tech.ID},
+		{Fingerprint: "fp", Tool: models.ToolZap, Severity: models.SeverityLow},
+	})
+	require.Len(t, merged, 1)
+	assert.Empty(t, merged[0].SuppressedBy)
+	assert.Equal(t, models.ToolZap, merged[0].Tool)
+	assert.Equal(t, tech.ID, merged[0].Sources[0].SuppressedBy)
+
+	// Rules stop applying once expired, and are read back on start
+	expires := time.Now().Add(50 * time.Millisecond)
+	_, err = s.Update(tech.ID, models.SuppressionRuleRequest{Tool: models.ToolNuclei, RuleID: "tech-detect", ExpiresAt: &expires})
+	require.NoError(t, err)
+	s = NewSuppressionService(repo, jobs)
+	require.NoError(t, s.Load())
+	time.Sleep(time.Until(expires))
+	s.Apply(findings)
+	assert.Empty(t, findings[2].SuppressedBy)
+
+	require.NoError(t, s.Delete(zap.ID))
+	assert.ErrorIs(t, s.Delete(zap.ID), ErrSuppressionNotFound)
+	s.Apply(findings)
+	assert.Empty(t, findings[0].SuppressedBy)
+
+	// Jobs keep what the rules hid when they finished
+	job := &models.Job{ID: "job-1", Tool: models.ToolOpenVAS, Target: "10.0.0.5", Status: models.JobStatusCompleted}
+	require.NoError(t, repo.SaveJob(context.Background(), job))
+	require.NoError(t, s.Stamp(job, findings[4:]))
+	require.NoError(t, s.Delete(ssh.ID))
+	_, err = s.Create("alice", models.SuppressionRuleRequest{Tool: models.ToolOpenVAS, Port: "2222"})
+	require.NoError(t, err)
+	stored, err := repo.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	later := []models.Finding{findings[4], findings[5]}
+	applyStamped(stored, later)
+	assert.Equal(t, ssh.ID, later[0].SuppressedBy)
+	assert.Empty(t, later[1].SuppressedBy)
+}
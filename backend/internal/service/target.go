@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidTarget is returned for targets that are not a hostname, IP address,
+// network, URL or host:port
+var ErrInvalidTarget = errors.New("invalid target")
+
+// TargetKind is the form a target was given in
+type TargetKind string
+
+const (
+	TargetHostname TargetKind = "hostname"
+	TargetIPv4     TargetKind = "ipv4"
+	TargetIPv6     TargetKind = "ipv6"
+	// TargetCIDR is a network such as 10.0.0.0/24
+	TargetCIDR     TargetKind = "cidr"
+	TargetURL      TargetKind = "url"
+	TargetHostPort TargetKind = "host_port"
+)
+
+// Target is a parsed scan target. The tools receive targets only through its
+// methods, so nothing the user typed reaches a command line as an option.
+type Target struct {
+	Kind TargetKind
+	// Host is the lowercased hostname or the IP address, without brackets. For a
+	// network it is the network address.
+	Host string
+	// Port is set for host:port targets and URLs with an explicit port
+	Port string
+	// IPv6 is set for IPv6 addresses and networks and for hostnames given as [::1]:443
+	IPv6 bool
+
+	prefix netip.Prefix
+	url    *url.URL
+}
+
+// Hostnames are letters, digits, hyphens and underscores in dot separated labels
+// that do not start or end with a hyphen
+var hostnameLabelRe = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?$`)
+
+// ParseTarget validates a hostname, IPv4 or IPv6 address, CIDR network, http(s)
+// URL or host:port
+func ParseTarget(raw string) (*Target, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return nil, fmt.Errorf("%w: target is required", ErrInvalidTarget)
+	}
+	if strings.HasPrefix(s, "-") {
+		return nil, fmt.Errorf("%w: %q must not start with -", ErrInvalidTarget, s)
+	}
+	if strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return nil, fmt.Errorf("%w: %q contains whitespace", ErrInvalidTarget, s)
+	}
+
+	switch {
+	case strings.Contains(s, "://"):
+		return parseTargetURL(s)
+	case strings.Contains(s, "/"):
+		prefix, err := netip.ParsePrefix(s)
+		if err == nil && prefix.Addr().Zone() == "" {
+			prefix = prefix.Masked()
+			return &Target{Kind: TargetCIDR, Host: prefix.Addr().String(), IPv6: prefix.Addr().Is6(), prefix: prefix}, nil
+		}
+		// example.com/app is a URL without its scheme
+		if _, ok := parseTargetIP(s[:strings.Index(s, "/")]); !ok {
+			return parseTargetURL("https://" + s)
+		}
+		return nil, fmt.Errorf("%w: %q is not a network such as 10.0.0.0/24", ErrInvalidTarget, s)
+	}
+
+	if addr, ok := parseTargetIP(s); ok {
+		if addr.Is4() {
+			return &Target{Kind: TargetIPv4, Host: addr.String()}, nil
+		}
+		return &Target{Kind: TargetIPv6, Host: addr.String(), IPv6: true}, nil
+	}
+	if strings.Contains(s, ":") {
+		host, port, err := net.SplitHostPort(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a host:port", ErrInvalidTarget, s)
+		}
+		t, err := parseTargetHost(host)
+		if err != nil {
+			return nil, err
+		}
+		if t.Port, err = parseTargetPort(port); err != nil {
+			return nil, err
+		}
+		t.Kind = TargetHostPort
+		return t, nil
+	}
+	return parseTargetHost(s)
+}
+
+func parseTargetURL(s string) (*Target, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: only http and https URLs are supported", ErrInvalidTarget)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: URLs must not carry credentials", ErrInvalidTarget)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: %q has no host", ErrInvalidTarget, s)
+	}
+	t, err := parseTargetHost(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if port := u.Port(); port != "" {
+		if t.Port, err = parseTargetPort(port); err != nil {
+			return nil, err
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return nil, fmt.Errorf("%w: %q has an empty port", ErrInvalidTarget, s)
+	}
+	u.Host = strings.ToLower(u.Host)
+	t.Kind = TargetURL
+	t.url = u
+	return t, nil
+}
+
+// parseTargetHost validates a hostname or an IP address, which may be in brackets
+func parseTargetHost(host string) (*Target, error) {
+	if addr, ok := parseTargetIP(host); ok {
+		return &Target{Kind: TargetHostname, Host: addr.String(), IPv6: addr.Is6()}, nil
+	}
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	if name == "" || len(name) > 253 {
+		return nil, fmt.Errorf("%w: %q is not a hostname", ErrInvalidTarget, host)
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if !hostnameLabelRe.MatchString(label) {
+			return nil, fmt.Errorf("%w: %q is not a hostname", ErrInvalidTarget, host)
+		}
+	}
+	// A numeric top level label means a mistyped IP address such as 10.0.0.256
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return nil, fmt.Errorf("%w: %q is not an IP address", ErrInvalidTarget, host)
+	}
+	return &Target{Kind: TargetHostname, Host: name}, nil
+}
+
+func parseTargetIP(s string) (netip.Addr, bool) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func parseTargetPort(port string) (string, error) {
+	p, err := parsePort(port)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+	return strconv.Itoa(p), nil
+}
+
+// String returns the target in canonical form
+func (t *Target) String() string {
+	switch t.Kind {
+	case TargetCIDR:
+		return t.prefix.String()
+	case TargetURL:
+		return t.url.String()
+	case TargetHostPort:
+		return net.JoinHostPort(t.Host, t.Port)
+	}
+	return t.Host
+}
+
+// Hosts returns the host or network to scan, dropping the port and path of
+// host:port and URL targets
+func (t *Target) Hosts() string {
+	if t.Kind == TargetCIDR {
+		return t.prefix.String()
+	}
+	return t.Host
+}
+
+// Address returns host:port for tools that connect to a single service, with
+// defaultPort when the target has none. Networks are refused.
+func (t *Target) Address(defaultPort string) (string, error) {
+	if t.Kind == TargetCIDR {
+		return "", fmt.Errorf("%w: %s is a network, a single host is needed", ErrInvalidTarget, t)
+	}
+	port := t.Port
+	if port == "" && t.Kind == TargetURL {
+		port = map[string]string{"http": "80", "https": "443"}[t.url.Scheme]
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(t.Host, port), nil
+}
+
+// URL returns the target as a URL for web tools. Targets that are not URLs get
+// defaultScheme. Networks are refused.
+func (t *Target) URL(defaultScheme string) (string, error) {
+	switch t.Kind {
+	case TargetCIDR:
+		return "", fmt.Errorf("%w: %s is a network, a single host is needed", ErrInvalidTarget, t)
+	case TargetURL:
+		return t.url.String(), nil
+	}
+	host := t.Host
+	if t.Port != "" {
+		host = net.JoinHostPort(t.Host, t.Port)
+	} else if t.IPv6 {
+		host = "[" + t.Host + "]"
+	}
+	return (&url.URL{Scheme: defaultScheme, Host: host}).String(), nil
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidTriage = errors.New("invalid triage")
+
+// triageTick is how often expired risk acceptances are reopened
+const triageTick = 10 * time.Minute
+
+// triageSystemUser is recorded as the author of automatic transitions
+const triageSystemUser = "system"
+
+// triageTransitions lists the statuses each status can move to
+var triageTransitions = map[models.TriageStatus][]models.TriageStatus{
+	models.TriageOpen:          {models.TriageConfirmed, models.TriageFalsePositive, models.TriageAcceptedRisk, models.TriageFixed},
+	models.TriageConfirmed:     {models.TriageFalsePositive, models.TriageAcceptedRisk, models.TriageFixed},
+	models.TriageFalsePositive: {models.TriageConfirmed, models.TriageReopened},
+	models.TriageAcceptedRisk:  {models.TriageConfirmed, models.TriageFixed, models.TriageReopened},
+	models.TriageFixed:         {models.TriageReopened},
+	models.TriageReopened:      {models.TriageConfirmed, models.TriageFalsePositive, models.TriageAcceptedRisk, models.TriageFixed},
+}
+
+// TriageService tracks the lifecycle of findings across scans. The state is keyed by
+// the finding fingerprint, so a new scan that reports the same issue inherits it.
+// Fixed findings that a later job finds again are reopened, and so are risk
+// acceptances that expired.
+type TriageService struct {
+	// mu serializes changes to the same triage coming from users, finished jobs and the expiry loop
+	mu         sync.Mutex
+	repo       repository.TriageRepository
+	enrichment *EnrichmentService
+}
+
+func NewTriageService(repo repository.TriageRepository, jobs *JobService, enrichment *EnrichmentService) *TriageService {
+	s := &TriageService{repo: repo, enrichment: enrichment}
+	jobs.OnFinish(s.jobFinished)
+	return s
+}
+
+// Start reopens expired risk acceptances until ctx is cancelled
+func (s *TriageService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(triageTick)
+		defer ticker.Stop()
+
+		s.expire(time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.expire(now)
+			}
+		}
+	}()
+}
+
+// Get returns the triage of a fingerprint with its transitions and threaded comments.
+// Fingerprints nobody triaged yet are open.
+func (s *TriageService) Get(fp string) (*models.FindingTriage, error) {
+	if err := validFingerprint(fp); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	triage, err := s.repo.GetTriage(ctx, fp)
+	if errors.Is(err, repository.ErrNotFound) {
+		triage, err = &models.FindingTriage{Fingerprint: fp, Status: models.TriageOpen}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if triage.Transitions, err = s.repo.ListTransitions(ctx, fp); err != nil {
+		return nil, err
+	}
+	comments, err := s.repo.ListComments(ctx, fp)
+	if err != nil {
+		return nil, err
+	}
+	triage.Comments = threadComments(comments)
+	return triage, nil
+}
+
+// List returns the triaged findings, most recently updated first
+func (s *TriageService) List(filter repository.TriageFilter) ([]*models.FindingTriage, error) {
+	return s.repo.ListTriage(context.Background(), filter)
+}
+
+// Update validates req and applies it to the triage of a fingerprint on behalf of
+// userID. Status changes are recorded as transitions.
+func (s *TriageService) Update(userID, fp string, req models.TriageUpdateRequest) (*models.FindingTriage, error) {
+	if err := validFingerprint(fp); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := context.Background()
+	now := time.Now()
+	triage, err := s.repo.GetTriage(ctx, fp)
+	if errors.Is(err, repository.ErrNotFound) {
+		triage, err = &models.FindingTriage{Fingerprint: fp, Status: models.TriageOpen, CreatedAt: now}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	from := triage.Status
+	to := req.Status
+	if to == "" {
+		to = from
+	}
+	if to != from && !canTransition(from, to) {
+		return nil, fmt.Errorf("%w: cannot move a finding from %s to %s", ErrInvalidTriage, from, to)
+	}
+
+	req.Justification = strings.TrimSpace(req.Justification)
+	if to != from || req.Justification != "" {
+		triage.Justification = req.Justification
+	}
+	switch to {
+	case models.TriageFalsePositive, models.TriageAcceptedRisk:
+		if triage.Justification == "" {
+			return nil, fmt.Errorf("%w: a justification is required for %s", ErrInvalidTriage, to)
+		}
+	}
+	if to == models.TriageAcceptedRisk {
+		if req.AcceptedUntil != nil {
+			triage.AcceptedUntil = req.AcceptedUntil
+		}
+		if triage.AcceptedUntil == nil || !triage.AcceptedUntil.After(now) {
+			return nil, fmt.Errorf("%w: accepted_until must be in the future", ErrInvalidTriage)
+		}
+	} else {
+		if req.AcceptedUntil != nil {
+			return nil, fmt.Errorf("%w: accepted_until only applies to accepted_risk", ErrInvalidTriage)
+		}
+		triage.AcceptedUntil = nil
+	}
+	if req.Assignee != nil {
+		triage.Assignee = strings.TrimSpace(*req.Assignee)
+	}
+
+	if err := s.save(triage, from, to, userID, now); err != nil {
+		return nil, err
+	}
+	return triage, nil
+}
+
+// Comment adds a comment to a fingerprint, or a reply to one of its comments
+func (s *TriageService) Comment(userID, fp string, req models.CommentRequest) (*models.FindingComment, error) {
+	if err := validFingerprint(fp); err != nil {
+		return nil, err
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		return nil, fmt.Errorf("%w: comment body is required", ErrInvalidTriage)
+	}
+
+	ctx := context.Background()
+	if req.ParentID != "" {
+		comments, err := s.repo.ListComments(ctx, fp)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, c := range comments {
+			found = found || c.ID == req.ParentID
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: parent comment %s not found", ErrInvalidTriage, req.ParentID)
+		}
+	}
+
+	comment := &models.FindingComment{
+		ID:          uuid.NewString(),
+		Fingerprint: fp,
+		ParentID:    req.ParentID,
+		UserID:      userID,
+		Body:        req.Body,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.SaveComment(ctx, comment); err != nil {
+		return nil, fmt.Errorf("failed to save comment: %w", err)
+	}
+	return comment, nil
+}
+
+// Attach fills in the triage of every finding that has one
+func (s *TriageService) Attach(findings []models.Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	fps := make([]string, 0, len(findings))
+	for _, f := range findings {
+		fps = append(fps, f.Fingerprint)
+	}
+	triages, err := s.repo.GetTriages(context.Background(), fps)
+	if err != nil {
+		return err
+	}
+	for i := range findings {
+		findings[i].Triage = triages[findings[i].Fingerprint]
+	}
+	return nil
+}
+
+// FilterByTriage keeps the findings in one of statuses. Untriaged findings are open.
+func FilterByTriage(findings []models.Finding, statuses []models.TriageStatus) []models.Finding {
+	if len(statuses) == 0 {
+		return findings
+	}
+	keep := make(map[models.TriageStatus]bool, len(statuses))
+	for _, status := range statuses {
+		keep[status] = true
+	}
+	filtered := []models.Finding{}
+	for _, f := range findings {
+		status := models.TriageOpen
+		if f.Triage != nil {
+			status = f.Triage.Status
+		}
+		if keep[status] {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// jobFinished reopens the fixed findings a completed job reports again. A job that
+// finished before the finding was marked fixed, replayed on resume, is ignored.
+func (s *TriageService) jobFinished(job *models.Job) {
+	if job.Status != models.JobStatusCompleted || job.FinishedAt == nil {
+		return
+	}
+	findings, err := NormalizeFindings(job)
+	if err != nil {
+		return
+	}
+	findings = append(findings, s.enrichment.VersionFindings(job)...)
+	if len(findings) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fps := make([]string, 0, len(findings))
+	for _, f := range findings {
+		fps = append(fps, f.Fingerprint)
+	}
+	triages, err := s.repo.GetTriages(context.Background(), fps)
+	if err != nil {
+		log.Printf("Failed to load triage for job %s: %v", job.ID, err)
+		return
+	}
+	for _, triage := range triages {
+		if triage.Status != models.TriageFixed || !job.FinishedAt.After(triage.UpdatedAt) {
+			continue
+		}
+		triage.Justification = fmt.Sprintf("found again by %s job %s", job.Tool, job.ID)
+		if err := s.save(triage, models.TriageFixed, models.TriageReopened, triageSystemUser, time.Now()); err != nil {
+			log.Printf("Failed to reopen finding %s: %v", triage.Fingerprint, err)
+		}
+	}
+}
+
+// expire reopens the risk acceptances that ended before now
+func (s *TriageService) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	triages, err := s.repo.ListTriage(context.Background(), repository.TriageFilter{
+		Status:         models.TriageAcceptedRisk,
+		AcceptedBefore: now,
+	})
+	if err != nil {
+		log.Printf("Failed to list expired risk acceptances: %v", err)
+		return
+	}
+	for _, triage := range triages {
+		triage.Justification = "risk acceptance expired on " + triage.AcceptedUntil.UTC().Format(time.RFC3339)
+		triage.AcceptedUntil = nil
+		if err := s.save(triage, models.TriageAcceptedRisk, models.TriageReopened, triageSystemUser, now); err != nil {
+			log.Printf("Failed to reopen finding %s: %v", triage.Fingerprint, err)
+		}
+	}
+}
+
+// save stores the triage and records the transition when the status changed.
+// The caller must hold s.mu.
+func (s *TriageService) save(triage *models.FindingTriage, from, to models.TriageStatus, userID string, now time.Time) error {
+	ctx := context.Background()
+	triage.Status = to
+	triage.UpdatedBy = userID
+	triage.UpdatedAt = now
+	if err := s.repo.SaveTriage(ctx, triage); err != nil {
+		return fmt.Errorf("failed to save triage: %w", err)
+	}
+	if from == to {
+		return nil
+	}
+	err := s.repo.SaveTransition(ctx, &models.TriageTransition{
+		ID:            uuid.NewString(),
+		Fingerprint:   triage.Fingerprint,
+		From:          from,
+		To:            to,
+		UserID:        userID,
+		Justification: triage.Justification,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save transition: %w", err)
+	}
+	return nil
+}
+
+func canTransition(from, to models.TriageStatus) bool {
+	for _, next := range triageTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// validFingerprint accepts the hex fingerprints findings carry
+func validFingerprint(fp string) error {
+	if _, err := hex.DecodeString(fp); err != nil || len(fp) != 32 {
+		return fmt.Errorf("%w: malformed fingerprint %q", ErrInvalidTriage, fp)
+	}
+	return nil
+}
+
+// threadComments nests replies under the comment they answer, keeping the order
+func threadComments(comments []*models.FindingComment) []*models.FindingComment {
+	byID := make(map[string]*models.FindingComment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+	roots := []*models.FindingComment{}
+	for _, c := range comments {
+		if parent, ok := byID[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+	return roots
+}
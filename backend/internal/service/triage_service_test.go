@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+	"napscan-be/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTriageFollowsFingerprint(t *testing.T) {
+	repo, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	jobs := NewJobService(repo, DefaultPoolConfig())
+	s := NewTriageService(repo, jobs, NewEnrichmentService(t.TempDir()))
+
+	nuclei := `{"results":[{"template-id":"CVE-2021-44228","matched-at":"https://app.example.com/login",
+		"info":{"name":"Log4Shell","severity":"critical","classification":{"cve-id":"cve-2021-44228","cvss-score":10}}}]}`
+	job := func(id string, finishedAt time.Time) *models.Job {
+		return &models.Job{ID: id, Tool: models.ToolNuclei, Status: models.JobStatusCompleted,
+			FinishedAt: &finishedAt, Result: json.RawMessage(nuclei)}
+	}
+	findings, err := NormalizeFindings(job("job-1", time.Now()))
+	require.NoError(t, err)
+	fp := findings[0].Fingerprint
+
+	_, err = s.Update("alice", fp, models.TriageUpdateRequest{Status: models.TriageFalsePositive})
+	assert.ErrorIs(t, err, ErrInvalidTriage, "false positives need a justification")
+	_, err = s.Update("alice", fp, models.TriageUpdateRequest{Status: models.TriageAcceptedRisk, Justification: "WAF rule"})
+	assert.ErrorIs(t, err, ErrInvalidTriage, "risk acceptances need an expiry")
+	_, err = s.Update("alice", fp, models.TriageUpdateRequest{Status: models.TriageReopened})
+	assert.ErrorIs(t, err, ErrInvalidTriage, "open findings cannot be reopened")
+	_, err = s.Update("alice", "not-a-fingerprint", models.TriageUpdateRequest{Status: models.TriageConfirmed})
+	assert.ErrorIs(t, err, ErrInvalidTriage)
+
+	bob := "bob"
+	until := time.Now().Add(100 * time.Millisecond)
+	triage, err := s.Update("alice", fp, models.TriageUpdateRequest{
+		Status: models.TriageAcceptedRisk, Assignee: &bob, Justification: "WAF rule", AcceptedUntil: &until})
+	require.NoError(t, err)
+	assert.Equal(t, "bob", triage.Assignee)
+
+	// The triage of the fingerprint applies to the findings of a later scan
+	findings, err = NormalizeFindings(job("job-2", time.Now()))
+	require.NoError(t, err)
+	require.NoError(t, s.Attach(findings))
+	require.NotNil(t, findings[0].Triage)
+	risk := NewRiskScorer(DefaultRiskConfig()).ScoreFinding(findings[0], "")
+	assert.Zero(t, risk.Score)
+	assert.Empty(t, FilterByTriage(findings, []models.TriageStatus{models.TriageOpen}))
+
+	// Expired acceptances are reopened
+	time.Sleep(time.Until(until))
+	s.expire(time.Now())
+	triage, err = s.Get(fp)
+	require.NoError(t, err)
+	assert.Equal(t, models.TriageReopened, triage.Status)
+	assert.Nil(t, triage.AcceptedUntil)
+
+	// Fixed findings reported again by a job that finished later are reopened;
+	// a replayed job that finished before the fix is not
+	_, err = s.Update("bob", fp, models.TriageUpdateRequest{Status: models.TriageFixed, Justification: "upgraded log4j"})
+	require.NoError(t, err)
+	s.jobFinished(job("job-1", time.Now().Add(-time.Hour)))
+	triage, err = s.Get(fp)
+	require.NoError(t, err)
+	assert.Equal(t, models.TriageFixed, triage.Status)
+
+	s.jobFinished(job("job-3", time.Now().Add(time.Second)))
+	triage, err = s.Get(fp)
+	require.NoError(t, err)
+	assert.Equal(t, models.TriageReopened, triage.Status)
+	assert.Equal(t, "system", triage.UpdatedBy)
+
+	var moves []models.TriageStatus
+	for _, tr := range triage.Transitions {
+		moves = append(moves, tr.To)
+	}
+	assert.Equal(t, []models.TriageStatus{models.TriageAcceptedRisk, models.TriageReopened, models.TriageFixed, models.TriageReopened}, moves)
+	assert.Equal(t, "found again by nuclei job job-3", triage.Transitions[3].Justification)
+
+	comment, err := s.Comment("alice", fp, models.CommentRequest{Body: "Still reachable from the VPN"})
+	require.NoError(t, err)
+	_, err = s.Comment("bob", fp, models.CommentRequest{Body: "Looking into it", ParentID: comment.ID})
+	require.NoError(t, err)
+	_, err = s.Comment("bob", fp, models.CommentRequest{Body: "?", ParentID: "missing"})
+	assert.ErrorIs(t, err, ErrInvalidTriage)
+
+	triage, err = s.Get(fp)
+	require.NoError(t, err)
+	require.Len(t, triage.Comments, 1)
+	require.Len(t, triage.Comments[0].Replies, 1)
+	assert.Equal(t, "bob", triage.Comments[0].Replies[0].UserID)
+}
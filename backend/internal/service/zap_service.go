@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"napscan-be/internal/models"
 )
 
 type ZapService struct{}
@@ -64,7 +67,16 @@ func (s *ZapService) zapGetJSON(ctx context.Context, baseURL string, path string
 	return out, nil
 }
 
-func (s *ZapService) zapPollStatus(ctx context.Context, baseURL string, apiKey string, component string, scanID string) error {
+func (s *ZapService) zapPollStatus(ctx context.Context, baseURL string, apiKey string, component string, scanID string) (err error) {
+	// The job context usually ends in the middle of a status request, which then fails
+	// with its own error; stop the scan whichever way polling ends because of it
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			s.zapStop(baseURL, apiKey, component, scanID)
+			err = ctx.Err()
+		}
+	}()
+
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -101,6 +113,7 @@ func (s *ZapService) zapPollStatus(ctx context.Context, baseURL string, apiKey s
 			if err != nil {
 				return fmt.Errorf("unexpected zap status value: %v", rawStatus)
 			}
+			reportProgress(ctx, zapProgress(component, statusInt), fmt.Sprintf("ZAP %s %d%%", zapComponentName(component), statusInt))
 			if statusInt >= 100 {
 				return nil
 			}
@@ -108,46 +121,96 @@ func (s *ZapService) zapPollStatus(ctx context.Context, baseURL string, apiKey s
 	}
 }
 
-func (s *ZapService) ExecuteFullScan(ctx context.Context, target string) (map[string]interface{}, error) {
-	baseURL := s.zapBaseURL()
-	apiKey := s.zapAPIKey()
-
-	// 1) Spider scan
-	spiderQ := url.Values{}
-	spiderQ.Set("url", target)
-	spiderQ.Set("recurse", "true")
-	if apiKey != "" {
-		spiderQ.Set("apikey", apiKey)
-	}
-
-	spiderRes, err := s.zapGetJSON(ctx, baseURL, "/JSON/spider/action/scan/", spiderQ)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start spider: %w", err)
+// zapProgress maps the spider (first 20%) and active scan (rest) status onto one
+// percentage for the whole run
+func zapProgress(component string, status int) int {
+	if status > 100 {
+		status = 100
 	}
-	spiderID := fmt.Sprint(spiderRes["scan"])
-	if spiderID == "" || spiderID == "<nil>" {
-		return nil, fmt.Errorf("spider scan failed, no ID: %v", spiderRes)
+	if component == "spider" {
+		return status / 5
 	}
+	return 20 + status*4/5
+}
 
-	if err := s.zapPollStatus(ctx, baseURL, apiKey, "spider", spiderID); err != nil {
-		return nil, fmt.Errorf("spider scan polling failed: %w", err)
+func zapComponentName(component string) string {
+	if component == "ascan" {
+		return "active scan"
 	}
+	return component
+}
+
+// zapStop asks ZAP to stop a spider or active scan. It uses its own context
+// because it runs after the job context has already been cancelled.
+func (s *ZapService) zapStop(baseURL string, apiKey string, component string, scanID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// 2) Active scan
-	ascanQ := url.Values{}
-	ascanQ.Set("url", target)
-	ascanQ.Set("recurse", "true")
+	q := url.Values{}
+	q.Set("scanId", scanID)
 	if apiKey != "" {
-		ascanQ.Set("apikey", apiKey)
+		q.Set("apikey", apiKey)
 	}
+	if _, err := s.zapGetJSON(ctx, baseURL, "/JSON/"+component+"/action/stop/", q); err != nil {
+		log.Printf("Failed to stop ZAP %s scan %s: %v", component, scanID, err)
+	}
+}
 
-	ascanRes, err := s.zapGetJSON(ctx, baseURL, "/JSON/ascan/action/scan/", ascanQ)
+func (s *ZapService) ExecuteFullScan(ctx context.Context, t *Target) (map[string]interface{}, error) {
+	target, err := t.URL("https")
 	if err != nil {
-		return nil, fmt.Errorf("failed to start active scan: %w", err)
+		return nil, err
 	}
-	ascanID := fmt.Sprint(ascanRes["scan"])
-	if ascanID == "" || ascanID == "<nil>" {
-		return nil, fmt.Errorf("active scan failed, no ID: %v", ascanRes)
+	baseURL := s.zapBaseURL()
+	apiKey := s.zapAPIKey()
+
+	// Scan IDs stored by an earlier run of the job mean the scans are already running
+	// inside ZAP; re-attach to them instead of starting new ones
+	spiderID := jobRef(ctx, "spider_id")
+	ascanID := jobRef(ctx, "ascan_id")
+
+	// 1) Spider scan
+	if spiderID == "" && ascanID == "" {
+		spiderQ := url.Values{}
+		spiderQ.Set("url", target)
+		spiderQ.Set("recurse", "true")
+		if apiKey != "" {
+			spiderQ.Set("apikey", apiKey)
+		}
+
+		spiderRes, err := s.zapGetJSON(ctx, baseURL, "/JSON/spider/action/scan/", spiderQ)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start spider: %w", err)
+		}
+		spiderID = fmt.Sprint(spiderRes["scan"])
+		if spiderID == "" || spiderID == "<nil>" {
+			return nil, fmt.Errorf("spider scan failed, no ID: %v", spiderRes)
+		}
+		recordRef(ctx, "spider_id", spiderID)
+	}
+
+	if ascanID == "" {
+		if err := s.zapPollStatus(ctx, baseURL, apiKey, "spider", spiderID); err != nil {
+			return nil, fmt.Errorf("spider scan polling failed: %w", err)
+		}
+
+		// 2) Active scan
+		ascanQ := url.Values{}
+		ascanQ.Set("url", target)
+		ascanQ.Set("recurse", "true")
+		if apiKey != "" {
+			ascanQ.Set("apikey", apiKey)
+		}
+
+		ascanRes, err := s.zapGetJSON(ctx, baseURL, "/JSON/ascan/action/scan/", ascanQ)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start active scan: %w", err)
+		}
+		ascanID = fmt.Sprint(ascanRes["scan"])
+		if ascanID == "" || ascanID == "<nil>" {
+			return nil, fmt.Errorf("active scan failed, no ID: %v", ascanRes)
+		}
+		recordRef(ctx, "ascan_id", ascanID)
 	}
 
 	if err := s.zapPollStatus(ctx, baseURL, apiKey, "ascan", ascanID); err != nil {
@@ -167,12 +230,151 @@ func (s *ZapService) ExecuteFullScan(ctx context.Context, target string) (map[st
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch alerts: %w", err)
 	}
+	if raw, err := json.Marshal(alertsRes); err == nil {
+		recordOutput(ctx, "alerts.json", "application/json", raw)
+	}
 
 	return map[string]interface{}{
-		"target":   target,
-		"zapBase":  baseURL,
-		"spider":   map[string]interface{}{"scanId": spiderID},
-		"active":   map[string]interface{}{"scanId": ascanID},
+		"target":    target,
+		"zapBase":   baseURL,
+		"spider":    map[string]interface{}{"scanId": spiderID},
+		"active":    map[string]interface{}{"scanId": ascanID},
 		"alertsRaw": alertsRes,
 	}, nil
 }
+
+// RunJob runs the spider and active scan as a background job
+func (s *ZapService) RunJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Second)
+	defer cancel()
+
+	target, err := ParseTarget(run.Target())
+	if err != nil {
+		return nil, err
+	}
+	return s.ExecuteFullScan(ctx, target)
+}
+
+// zapAlert is an alert of /JSON/core/view/alerts/, where every value is a string
+type zapAlert struct {
+	PluginID    string `json:"pluginId"`
+	Alert       string `json:"alert"`
+	Name        string `json:"name"`
+	Risk        string `json:"risk"`
+	Description string `json:"description"`
+	CWEID       string `json:"cweid"`
+	URL         string `json:"url"`
+	Param       string `json:"param"`
+	Attack      string `json:"attack"`
+	Evidence    string `json:"evidence"`
+}
+
+// zapReport is the Traditional JSON Report of ZAP, which groups the instances of an
+// alert under it
+type zapReport struct {
+	Site []struct {
+		Alerts []struct {
+			PluginID string `json:"pluginid"`
+			Alert    string `json:"alert"`
+			Name     string `json:"name"`
+			// RiskDesc is the risk followed by the confidence, e.g. "Medium (High)"
+			RiskDesc  string `json:"riskdesc"`
+			Desc      string `json:"desc"`
+			CWEID     string `json:"cweid"`
+			Instances []struct {
+				URI      string `json:"uri"`
+				Param    string `json:"param"`
+				Attack   string `json:"attack"`
+				Evidence string `json:"evidence"`
+			} `json:"instances"`
+		} `json:"alerts"`
+	} `json:"site"`
+}
+
+var zapReportMarkup = strings.NewReplacer("<p>", "", "</p>", "\n")
+
+// parseZapAlerts reads the alerts of a /JSON/core/view/alerts/ response, or of a
+// Traditional JSON Report turned into the alerts of that response, one per instance
+func parseZapAlerts(data []byte) (interface{}, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse zap json: %w", err)
+	}
+	if _, ok := doc["alerts"]; ok {
+		var alerts map[string]interface{}
+		if err := json.Unmarshal(data, &alerts); err != nil {
+			return nil, fmt.Errorf("failed to parse zap json: %w", err)
+		}
+		return alerts, nil
+	}
+
+	var report zapReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse zap report: %w", err)
+	}
+	alerts := []zapAlert{}
+	for _, site := range report.Site {
+		for _, a := range site.Alerts {
+			risk, _, _ := strings.Cut(a.RiskDesc, " ")
+			for _, inst := range a.Instances {
+				alerts = append(alerts, zapAlert{
+					PluginID:    a.PluginID,
+					Alert:       a.Alert,
+					Name:        a.Name,
+					Risk:        risk,
+					Description: strings.TrimSpace(zapReportMarkup.Replace(a.Desc)),
+					CWEID:       a.CWEID,
+					URL:         inst.URI,
+					Param:       inst.Param,
+					Attack:      inst.Attack,
+					Evidence:    inst.Evidence,
+				})
+			}
+		}
+	}
+	return map[string]interface{}{"alerts": alerts}, nil
+}
+
+// normalizeZapResult turns the alerts of a ZAP job into findings
+func normalizeZapResult(result interface{}) ([]models.Finding, error) {
+	var res struct {
+		AlertsRaw struct {
+			Alerts []zapAlert `json:"alerts"`
+		} `json:"alertsRaw"`
+	}
+	if err := decodeJobResult(result, &res); err != nil {
+		return nil, err
+	}
+
+	findings := make([]models.Finding, 0, len(res.AlertsRaw.Alerts))
+	for i, a := range res.AlertsRaw.Alerts {
+		f := models.Finding{
+			RuleID:      a.PluginID,
+			Title:       a.Alert,
+			Description: strings.TrimSpace(a.Description),
+			Severity:    parseSeverity(a.Risk),
+			URL:         a.URL,
+			RawRef:      fmt.Sprintf("alertsRaw.alerts[%d]", i),
+		}
+		if f.Title == "" {
+			f.Title = a.Name
+		}
+		if cwe := normalizeCWE(a.CWEID); cwe != "" {
+			f.CWEs = []string{cwe}
+		}
+		f.Host, f.Port = urlHostPort(a.URL)
+
+		var evidence []string
+		for _, part := range []struct{ label, value string }{
+			{"param", a.Param}, {"attack", a.Attack}, {"evidence", a.Evidence},
+		} {
+			if part.value != "" {
+				evidence = append(evidence, part.label+": "+part.value)
+			}
+		}
+		f.Evidence = strings.Join(evidence, "\n")
+
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
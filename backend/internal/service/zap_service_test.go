@@ -0,0 +1,60 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"napscan-be/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelStopsZapScanInTheMiddleOfAStatusRequest(t *testing.T) {
+	polling := make(chan struct{}, 1)
+	stopped := make(chan string, 1)
+	zap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/JSON/spider/action/scan/":
+			json.NewEncoder(w).Encode(map[string]string{"scan": "3"})
+		case "/JSON/spider/view/status/":
+			// A slow ZAP: the job is cancelled while it waits for the answer
+			select {
+			case polling <- struct{}{}:
+			default:
+			}
+			<-r.Context().Done()
+		case "/JSON/spider/action/stop/":
+			stopped <- r.URL.Query().Get("scanId")
+			json.NewEncoder(w).Encode(map[string]string{"Result": "OK"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(zap.Close)
+	t.Setenv("ZAP_BASE_URL", zap.URL)
+
+	s := newTestJobService(t, DefaultPoolConfig())
+	s.RegisterResumable(models.ToolZap, NewZapService().RunJob)
+	job, err := s.Submit(models.JobRequest{Tool: models.ToolZap, Target: "app.example.com"})
+	require.NoError(t, err)
+
+	select {
+	case <-polling:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job never asked ZAP for the spider status")
+	}
+	_, err = s.Cancel(job.ID)
+	require.NoError(t, err)
+
+	select {
+	case id := <-stopped:
+		assert.Equal(t, "3", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the spider was not stopped")
+	}
+	waitForStatus(t, s, job.ID, models.JobStatusCancelled)
+}
@@ -48,3 +48,26 @@ return Error(c, fiber.StatusInternalServerError, message, errMsg)
 func Unauthorized(c *fiber.Ctx, message string) error {
 return Error(c, fiber.StatusUnauthorized, message, nil)
 }
+
+// Accepted is a shortcut for 202 responses of work started in the background
+func Accepted(c *fiber.Ctx, message string, data interface{}) error {
+return c.Status(fiber.StatusAccepted).JSON(Response{
+Success: true,
+Message: message,
+Data:    data,
+})
+}
+
+// NotFound shortcut
+func NotFound(c *fiber.Ctx, message string) error {
+return Error(c, fiber.StatusNotFound, message, nil)
+}
+
+// Created is a shortcut for 201 responses
+func Created(c *fiber.Ctx, message string, data interface{}) error {
+return c.Status(fiber.StatusCreated).JSON(Response{
+Success: true,
+Message: message,
+Data:    data,
+})
+}
@@ -203,6 +203,11 @@ function HostCard({
                                 {host.hostname}
                             </div>
                         )}
+                        {host.os && (
+                            <div className="text-xs text-slate-500 dark:text-slate-400">
+                                {host.os}
+                            </div>
+                        )}
                     </div>
                 </div>
                 <div className="flex items-center gap-3">
@@ -280,17 +285,22 @@ function PortRow({ port }: { port: ParsedNmapPort }) {
                 </span>
             </td>
             <td className="px-4 py-3">
-                <span className={`font-medium ${stateStyles[port.state] || "text-slate-600"}`}>
+                <span className={`font-medium ${stateStyles[port.state] || "text-slate-600"}`} title={port.reason}>
                     {port.state}
                 </span>
             </td>
             <td className="px-4 py-3 text-slate-700 dark:text-slate-300">
-                {port.service}
+                {port.tunnel ? `${port.tunnel}/${port.service}` : port.service}
                 {port.product && (
                     <span className="text-xs text-slate-500 dark:text-slate-400 ml-1">
                         ({port.product}{port.version ? ` ${port.version}` : ""})
                     </span>
                 )}
+                {port.scripts.map((script) => (
+                    <div key={script.id} className="text-xs font-mono text-slate-500 dark:text-slate-400 truncate max-w-md" title={script.output}>
+                        {script.id}: {script.output.trim()}
+                    </div>
+                ))}
             </td>
             <td className="px-4 py-3">
                 <span className={`px-2 py-1 text-xs font-bold rounded ${severityStyles[port.severity]}`}>
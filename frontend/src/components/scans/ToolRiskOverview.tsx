@@ -18,6 +18,12 @@ export function ToolRiskOverview({ vulnerabilities }: ToolRiskOverviewProps) {
 
     const total = vulnerabilities.length;
 
+    // The backend scores every finding; the riskiest one sets the tone for the tool
+    const top = vulnerabilities.reduce<ScanVulnerability | undefined>(
+        (best, v) => (v.risk !== undefined && (best?.risk === undefined || v.risk > best.risk) ? v : best),
+        undefined
+    );
+
     // Calculate percentages for bar (prevent div by zero)
     const getPercent = (count: number) => total > 0 ? (count / total) * 100 : 0;
 
@@ -25,9 +31,19 @@ export function ToolRiskOverview({ vulnerabilities }: ToolRiskOverviewProps) {
         <div className="bg-white dark:bg-slate-900 rounded-xl border border-slate-200 dark:border-slate-800 p-6 shadow-sm">
             <div className="flex items-center justify-between mb-6">
                 <h4 className="font-bold text-slate-900 dark:text-white">Risks Overview</h4>
-                <span className="text-xs font-semibold text-slate-500 uppercase">
-                    {total} Total Issues
-                </span>
+                <div className="flex items-center gap-4">
+                    {top?.risk !== undefined && (
+                        <span
+                            className="text-xs font-semibold text-slate-700 dark:text-slate-300 uppercase"
+                            title={`${top.name}: ${(top.riskExplanation ?? []).join(", ")}`}
+                        >
+                            Top Risk {top.risk.toFixed(1)}/100
+                        </span>
+                    )}
+                    <span className="text-xs font-semibold text-slate-500 uppercase">
+                        {total} Total Issues
+                    </span>
+                </div>
             </div>
 
             <div className="flex flex-wrap gap-8 justify-between">
@@ -1,8 +1,7 @@
 "use client";
 
-import React, { createContext, useContext, useEffect, useState, useCallback } from "react";
-import { scannersApi, ToolKey } from "@/services/api";
-import { parseToolResults } from "@/utils/toolParsers";
+import React, { createContext, useContext, useEffect, useState, useCallback, useRef } from "react";
+import { scannersApi, ToolKey, Job, Scan, Finding, BackendScanStatus } from "@/services/api";
 
 // --- Types ---
 
@@ -14,6 +13,9 @@ export interface ScanVulnerability {
     severity: "Critical" | "High" | "Medium" | "Low" | "Info";
     description: string;
     tool: ToolKey;
+    // Risk score from the backend, 0 to 100, with how it was derived
+    risk?: number;
+    riskExplanation?: string[];
 }
 
 export interface ToolExecution {
@@ -48,8 +50,48 @@ interface ScanContextType {
 
 // --- Helper Functions ---
 
-const generateId = () => Math.random().toString(36).substring(2, 9);
 const STORAGE_KEY = "napscan_jobs";
+const SCAN_POLL_INTERVAL_MS = 3000;
+
+// toToolExecution maps a backend job onto the per-tool state shown in the UI
+const toToolExecution = (job: Job): ToolExecution => ({
+    tool: job.tool,
+    status:
+        job.status === "queued"
+            ? "pending"
+            : job.status === "running" || job.status === "completed"
+              ? job.status
+              : "failed",
+    progress: job.progress,
+    result: job.result,
+    error: job.error || (job.status === "completed" ? undefined : job.message),
+    startTime: job.started_at,
+    endTime: job.finished_at,
+});
+
+// toVulnerability maps a finding normalized by the backend onto the shape shown in the UI
+const toVulnerability = (finding: Finding, id: string): ScanVulnerability => ({
+    id,
+    name: finding.title,
+    severity: (finding.severity.charAt(0).toUpperCase() + finding.severity.slice(1)) as ScanVulnerability["severity"],
+    description: finding.description || finding.evidence || finding.url || "No description available",
+    tool: finding.tool,
+    risk: finding.risk?.score,
+    riskExplanation: finding.risk?.explanation,
+});
+
+const toScanStatus = (status: BackendScanStatus): ScanStatus => {
+    switch (status) {
+        case "queued":
+        case "running":
+            return "running";
+        case "completed":
+        case "partial":
+            return "completed";
+        default:
+            return "failed";
+    }
+};
 
 // --- Context ---
 
@@ -58,6 +100,7 @@ const ScanContext = createContext<ScanContextType | undefined>(undefined);
 export function ScanProvider({ children }: { children: React.ReactNode }) {
     const [scans, setScans] = useState<ScanJob[]>([]);
     const [isLoading, setIsLoading] = useState(true);
+    const following = useRef(new Set<string>());
 
     // Load from LocalStorage on mount
     useEffect(() => {
@@ -93,190 +136,82 @@ export function ScanProvider({ children }: { children: React.ReactNode }) {
         );
     };
 
-    const updateToolStatus = (
-        scanId: string,
-        tool: ToolKey,
-        updates: Partial<ToolExecution>
-    ) => {
+    // --- Server-side scan follower ---
+    // The backend runs the tools as child jobs of one scan; the browser only mirrors their state
+    const applyScan = (scanId: string, scan: Scan) => {
         setScans((prev) =>
-            prev.map((scan) => {
-                if (scan.id !== scanId) return scan;
-                const currentToolState = scan.tools[tool];
+            prev.map((s) => {
+                if (s.id !== scanId) return s;
+
+                const tools = { ...s.tools };
+                const seen = new Set<ToolKey>();
+                for (const job of scan.jobs ?? []) {
+                    // Pipeline scans run a tool once per discovered service; show the one still working
+                    if (!seen.has(job.tool) || tools[job.tool].status !== "running") {
+                        tools[job.tool] = toToolExecution(job);
+                        seen.add(job.tool);
+                    }
+                }
+
                 return {
-                    ...scan,
+                    ...s,
+                    tools,
+                    status: toScanStatus(scan.status),
                     updatedAt: new Date().toISOString(),
-                    tools: {
-                        ...scan.tools,
-                        [tool]: { ...currentToolState, ...updates },
-                    },
                 };
             })
         );
     };
 
-    const addVulnerabilities = (scanId: string, vulns: ScanVulnerability[]) => {
-        setScans((prev) =>
-            prev.map((scan) => {
-                if (scan.id !== scanId) return scan;
-                return {
-                    ...scan,
-                    vulnerabilities: [...scan.vulnerabilities, ...vulns],
-                };
-            })
-        );
+    // Findings are normalized by the backend; refetch them whenever another job completes
+    const applyFindings = async (scanId: string) => {
+        const res = await scannersApi.scans.findings(scanId);
+        if (!res.ok) return;
+        const vulnerabilities = res.data.map((f) => toVulnerability(f, f.fingerprint));
+        setScans((prev) => prev.map((s) => (s.id === scanId ? { ...s, vulnerabilities } : s)));
     };
 
-    // --- OpenVAS Dedicated Handler (3-step async flow) ---
-    const executeOpenVAS = async (scanId: string, target: string) => {
-        const tool: ToolKey = "openvas";
-        updateToolStatus(scanId, tool, {
-            status: "running",
-            progress: 0,
-            startTime: new Date().toISOString(),
-        });
+    const followScan = async (scanId: string) => {
+        if (following.current.has(scanId)) return;
+        following.current.add(scanId);
 
+        let completedJobs = -1;
         try {
-            // Step 1: Start Scan
-            const startRes = await scannersApi.openvas.scan(target);
-            if (!startRes.ok || !startRes.data?.taskID) {
-                throw new Error((startRes as any).message || "Failed to start OpenVAS scan");
-            }
-            const taskId = startRes.data.taskID;
-
-            // Step 2: Poll for status
-            let reportId: string | undefined;
-            let progress = 0;
-            const POLL_INTERVAL = 5000; // 5 seconds
-            const MAX_POLLS = 120; // Max 10 minutes
-
-            for (let i = 0; i < MAX_POLLS; i++) {
-                const statusRes = await scannersApi.openvas.taskStatus(taskId);
-                if (!statusRes.ok) {
-                    throw new Error((statusRes as any).message || "Failed to get OpenVAS status");
-                }
-
-                progress = statusRes.data?.progress ?? 0;
-                updateToolStatus(scanId, tool, { progress });
-
-                const status = statusRes.data?.status?.toLowerCase();
-                if (status === "done" || status === "completed") {
-                    reportId = statusRes.data?.reportID;
-                    break;
-                }
-                if (status === "stopped" || status === "failed") {
-                    throw new Error(`OpenVAS task ${status}`);
-                }
-
-                await new Promise((resolve) => setTimeout(resolve, POLL_INTERVAL));
-            }
-
-            if (!reportId) {
-                throw new Error("OpenVAS scan timed out or no report ID");
-            }
-
-            // Step 3: Fetch Report
-            const reportRes = await scannersApi.openvas.report(reportId);
-            if (!reportRes.ok) {
-                throw new Error((reportRes as any).message || "Failed to fetch OpenVAS report");
-            }
-
-            updateToolStatus(scanId, tool, {
-                status: "completed",
-                progress: 100,
-                endTime: new Date().toISOString(),
-                result: reportRes.data,
-            });
-        } catch (err: any) {
-            console.error("OpenVAS failed", err);
-            updateToolStatus(scanId, tool, {
-                status: "failed",
-                progress: 100,
-                endTime: new Date().toISOString(),
-                error: err.message,
-            });
-        }
-    };
-
-    // --- Generic Tool Executor ---
-    const executeTool = async (scanId: string, tool: ToolKey, target: string) => {
-        // OpenVAS has its own dedicated async handler
-        if (tool === "openvas") {
-            return executeOpenVAS(scanId, target);
-        }
-
-        // Mark tool as running
-        updateToolStatus(scanId, tool, {
-            status: "running",
-            progress: 0,
-            startTime: new Date().toISOString(),
-        });
-
-        try {
-            let result;
-            switch (tool) {
-                case "nmap":
-                    result = await scannersApi.nmap.scan(target);
-                    break;
-                case "zap":
-                    result = await scannersApi.zap.scan(target);
-                    break;
-                case "nuclei":
-                    result = await scannersApi.nuclei.scan(target);
-                    break;
-                case "sslyze":
-                    result = await scannersApi.sslyze.scan(target);
-                    break;
-                case "ffuf":
-                    result = await scannersApi.ffuf.scan(target);
-                    break;
-                default:
-                    throw new Error(`Unknown tool: ${tool}`);
-            }
-
-            const res = result as any;
-            if (!res.ok) {
-                throw new Error(res.error || res.message || "Unknown error");
-            }
-
-            updateToolStatus(scanId, tool, {
-                status: "completed",
-                progress: 100,
-                endTime: new Date().toISOString(),
-                result: res.data,
-            });
-
-            // Parse tool results and extract vulnerabilities
-            if (res.data) {
-                console.log(`[ScanContext] Parsing ${tool} results:`, res.data);
-                try {
-                    const toolVulns = parseToolResults(tool, res.data);
-                    console.log(`[ScanContext] Parsed ${toolVulns.length} vulnerabilities:`, toolVulns);
-                    if (toolVulns.length > 0) {
-                        const scanVulns: ScanVulnerability[] = toolVulns.map((v, idx) => ({
-                            ...v,
-                            id: `${scanId}-${tool}-${idx}`,
-                        }));
-                        console.log(`[ScanContext] Adding vulnerabilities to scan:`, scanVulns);
-                        addVulnerabilities(scanId, scanVulns);
+            for (;;) {
+                const res = await scannersApi.scans.get(scanId);
+                if (res.ok) {
+                    applyScan(scanId, res.data);
+                    const completed = (res.data.jobs ?? []).filter((j) => j.status === "completed").length;
+                    if (completed !== completedJobs) {
+                        completedJobs = completed;
+                        await applyFindings(scanId);
                     }
-                } catch (parseError) {
-                    console.error(`Failed to parse ${tool} results:`, parseError);
+                    if (!["queued", "running"].includes(res.data.status)) return;
+                } else if (res.status === 404) {
+                    updateScan(scanId, { status: "failed" });
+                    return;
                 }
+                // Other errors are usually a backend restart; the scan keeps running there
+                await new Promise((resolve) => setTimeout(resolve, SCAN_POLL_INTERVAL_MS));
             }
-        } catch (err: any) {
-            console.error(`Tool ${tool} failed`, err);
-            updateToolStatus(scanId, tool, {
-                status: "failed",
-                progress: 100,
-                endTime: new Date().toISOString(),
-                error: err.message,
-            });
+        } finally {
+            following.current.delete(scanId);
         }
     };
 
+    // Pick up scans that were still running when the page was closed
+    useEffect(() => {
+        if (isLoading) return;
+        scans.filter((s) => s.status === "running").forEach((s) => followScan(s.id));
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, [isLoading]);
+
     const startScan = async (target: string, selectedTools: ToolKey[], name?: string) => {
-        const newScanId = generateId();
-        const timestamp = new Date().toISOString();
+        const res = await scannersApi.scans.create(target, selectedTools, name);
+        if (!res.ok) {
+            throw new Error(res.message);
+        }
+        const scan = res.data;
 
         const toolsInit: Record<ToolKey, ToolExecution> = {} as any;
         selectedTools.forEach((tool) => {
@@ -288,43 +223,20 @@ export function ScanProvider({ children }: { children: React.ReactNode }) {
         });
 
         const newScan: ScanJob = {
-            id: newScanId,
-            name: name || `Scan ${target}`,
-            target,
+            id: scan.id,
+            name: scan.name,
+            target: scan.target,
             status: "running",
-            createdAt: timestamp,
-            updatedAt: timestamp,
+            createdAt: scan.created_at,
+            updatedAt: scan.updated_at,
             tools: toolsInit,
             vulnerabilities: [],
         };
 
         setScans((prev) => [newScan, ...prev]);
+        followScan(scan.id);
 
-        // Start tools in "background" (no await here)
-        // We execute them individually so they update independently
-        selectedTools.forEach((tool) => {
-            executeTool(newScanId, tool, target).then(() => {
-                // Check if all tools finished
-                setScans((currentScans) => {
-                    const s = currentScans.find(scan => scan.id === newScanId);
-                    if (!s) return currentScans;
-
-                    const allTools = Object.values(s.tools);
-                    const allFinished = allTools.every(t => t.status === 'completed' || t.status === 'failed');
-
-                    if (allFinished) {
-                        return currentScans.map(scan =>
-                            scan.id === newScanId
-                                ? { ...scan, status: 'completed', updatedAt: new Date().toISOString() }
-                                : scan
-                        );
-                    }
-                    return currentScans;
-                });
-            });
-        });
-
-        return newScanId;
+        return scan.id;
     };
 
     const deleteScan = (id: string) => {
@@ -41,7 +41,7 @@ function normalizeBaseURL(raw: string): string {
   return v.endsWith("/") ? v.slice(0, -1) : v;
 }
 
-const API_BASE_URL = normalizeBaseURL(
+export const API_BASE_URL = normalizeBaseURL(
   process.env.NEXT_PUBLIC_API_URL || "http://localhost:5000"
 );
 const API_TIMEOUT_MS = Number(
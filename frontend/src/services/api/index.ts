@@ -1,4 +1,4 @@
 export { api, request } from "./http";
 export type { ApiResult, ApiErr, ApiOk } from "./http";
 export { scannersApi } from "./scanners";
-export type { ToolKey } from "./scanners";
+export type { ToolKey, Job, JobStatus, JobProgressHandler, JobQueue, JobNode, Finding, FindingSource, FindingTriage, FindingComment, TriageStatus, TriageTransition, TriageUpdate, SuppressionRule, SuppressionRuleInput, CVEDetail, Severity, AssetCriticality, RiskScore, ScanRisk, Scan, ScanDiff, PortChange, ScanStatus as BackendScanStatus } from "./scanners";
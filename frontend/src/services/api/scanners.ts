@@ -1,10 +1,44 @@
-import { ApiResult, request } from "./http";
+import { API_BASE_URL, ApiResult, request } from "./http";
 
 export type ToolKey = "nmap" | "zap" | "openvas" | "nuclei" | "sslyze" | "ffuf";
 
 export type NmapScanResponse = {
   tcp: unknown;
+  // null when the profile skips UDP, or nmap runs without raw sockets
   udp: unknown;
+  // What the scan skipped or changed, e.g. TCP connect instead of SYN scans without raw sockets
+  warnings?: string[];
+};
+
+export type NmapTCPTechnique = "syn" | "connect" | "ack" | "window" | "maimon" | "fin" | "null" | "xmas";
+
+// Named nmap settings; scans select one with options.nmap.profile
+export type NmapProfileSettings = {
+  ports?: string;
+  top_ports?: number;
+  // Empty leaves the choice to nmap
+  tcp_technique?: NmapTCPTechnique;
+  udp: boolean;
+  udp_ports?: string;
+  timing?: number;
+  service_detection: boolean;
+  version_intensity?: number;
+  os_detection: boolean;
+  script_categories?: string[];
+};
+
+export type NmapProfile = NmapProfileSettings & {
+  name: string;
+  description?: string;
+  builtin: boolean;
+  created_by?: string;
+  created_at: string;
+  updated_at: string;
+};
+
+export type NmapProfileInput = NmapProfileSettings & {
+  name: string;
+  description?: string;
 };
 
 export type NucleiScanResponse = {
@@ -41,56 +75,581 @@ export type OpenVASStartScanResponse = {
 
 export type OpenVASReportResponse = unknown;
 
+export type JobStatus =
+  | "queued"
+  | "running"
+  | "completed"
+  | "failed"
+  | "cancelled"
+  | "interrupted";
+
+// One group of live hosts of an nmap network scan
+export type JobShard = {
+  index: number;
+  hosts: number;
+  from: string;
+  to: string;
+  status: JobStatus;
+  progress: number;
+  message?: string;
+  error?: string;
+};
+
+export type Job<T = unknown> = {
+  id: string;
+  tool: ToolKey;
+  target: string;
+  status: JobStatus;
+  progress: number;
+  message?: string;
+  refs?: Record<string, string>;
+  shards?: JobShard[];
+  scan_id?: string;
+  parent_id?: string;
+  result?: T;
+  error?: string;
+  created_at: string;
+  started_at?: string;
+  finished_at?: string;
+};
+
+export type ScanStatus =
+  | "queued"
+  | "running"
+  | "completed"
+  | "partial"
+  | "failed"
+  | "cancelled";
+
+// Job summary in Scan.tree; pipeline follow-ups are children of the nmap job
+export type JobNode = {
+  id: string;
+  tool: ToolKey;
+  target: string;
+  status: JobStatus;
+  progress: number;
+  children?: JobNode[];
+};
+
+// Parent of one job per tool, run by POST /api/scans
+export type Scan = {
+  id: string;
+  name: string;
+  target: string;
+  tools: ToolKey[];
+  pipeline: boolean;
+  asset_criticality: AssetCriticality;
+  status: ScanStatus;
+  progress: number;
+  created_at: string;
+  updated_at: string;
+  finished_at?: string;
+  jobs?: Job[];
+  tree?: JobNode[];
+};
+
+export type Severity = "critical" | "high" | "medium" | "low" | "info";
+
+export type AssetCriticality = "low" | "medium" | "high" | "critical";
+
+// Score from 0 to 100 with the steps the backend took to derive it
+export type RiskScore = {
+  score: number;
+  explanation: string[];
+};
+
+export type ScanRisk = {
+  scan_id?: string;
+  asset_criticality: AssetCriticality;
+  risk: RiskScore;
+  hosts: Array<{ host: string; findings: number; risk: RiskScore }>;
+};
+
+// What the local NVD, KEV and EPSS feeds know about a CVE
+export type CVEDetail = {
+  id: string;
+  description?: string;
+  cvss?: number;
+  cvss_vector?: string;
+  cwes?: string[];
+  published?: string;
+  kev: boolean;
+  kev_date_added?: string;
+  epss?: number;
+  epss_percentile?: number;
+};
+
+export type TriageStatus = "open" | "confirmed" | "false_positive" | "accepted_risk" | "fixed" | "reopened";
+
+export type TriageTransition = {
+  id: string;
+  fingerprint: string;
+  from: TriageStatus;
+  to: TriageStatus;
+  user_id: string;
+  justification?: string;
+  created_at: string;
+};
+
+export type FindingComment = {
+  id: string;
+  fingerprint: string;
+  parent_id?: string;
+  user_id: string;
+  body: string;
+  created_at: string;
+  replies?: FindingComment[];
+};
+
+// Triage state of a finding fingerprint, carried over to every scan that reports it again
+export type FindingTriage = {
+  fingerprint: string;
+  status: TriageStatus;
+  assignee?: string;
+  justification?: string;
+  accepted_until?: string;
+  updated_by?: string;
+  created_at: string;
+  updated_at: string;
+  transitions?: TriageTransition[];
+  comments?: FindingComment[];
+};
+
+export type TriageUpdate = {
+  status?: TriageStatus;
+  assignee?: string;
+  justification?: string;
+  accepted_until?: string;
+};
+
+// Report of a tool merged into a deduplicated finding
+export type FindingSource = {
+  tool: ToolKey;
+  job_id?: string;
+  rule_id: string;
+  raw_ref?: string;
+  suppressed_by?: string;
+};
+
+// Finding normalized by the backend, whatever tool reported it
+export type Finding = {
+  fingerprint: string;
+  tool: ToolKey;
+  job_id?: string;
+  rule_id: string;
+  title: string;
+  description?: string;
+  severity: Severity;
+  cvss?: number;
+  cvss_vector?: string;
+  qod?: number;
+  known_exploit?: boolean;
+  cves?: string[];
+  cwes?: string[];
+  host?: string;
+  port?: string;
+  url?: string;
+  evidence?: string;
+  raw_ref?: string;
+  cve_details?: CVEDetail[];
+  sources?: FindingSource[];
+  risk?: RiskScore;
+  triage?: FindingTriage;
+  // ID of the suppression rule hiding the finding
+  suppressed_by?: string;
+};
+
+// Hides the findings matching every matcher it sets
+export type SuppressionRule = {
+  id: string;
+  name: string;
+  tool?: ToolKey;
+  rule_id?: string;
+  host_glob?: string;
+  port?: string;
+  path_regex?: string;
+  severity?: Severity;
+  reason?: string;
+  expires_at?: string;
+  created_by: string;
+  created_at: string;
+  updated_at: string;
+};
+
+export type SuppressionRuleInput = Omit<SuppressionRule, "id" | "created_by" | "created_at" | "updated_at">;
+
+export type PortChange = {
+  host: string;
+  port: string;
+  protocol: string;
+  change: "opened" | "closed" | "service_changed";
+  old_state?: string;
+  new_state?: string;
+  old_service?: string;
+  new_service?: string;
+};
+
+// Changes between a scan and an earlier scan of the same target
+export type ScanDiff = {
+  scan_id: string;
+  against_id: string;
+  new: Finding[];
+  resolved: Finding[];
+  persisting: Finding[];
+  not_rescanned?: Finding[];
+  nmap: {
+    hosts_up?: string[];
+    hosts_down?: string[];
+    ports?: PortChange[];
+  };
+};
+
+export type JobQueue = {
+  running: Job[];
+  queued: Array<{ position: number; job: Job }>;
+  max_concurrent: number;
+  tool_limits: Record<string, number>;
+  running_by_tool: Record<string, number>;
+};
+
+// Backend JSON envelope (pkg/response.Response)
+type Envelope<T> = {
+  success: boolean;
+  message?: string;
+  data: T;
+};
+
+export type JobProgressHandler = (job: Job) => void;
+
+const JOB_POLL_INTERVAL_MS = 3000;
+
 function ensureNonEmptyTarget(target: string): string {
   const t = target.trim();
   if (!t) throw new Error("Target is required");
   return t;
 }
 
+function unwrap<T>(res: ApiResult<Envelope<T>>): ApiResult<T> {
+  if (!res.ok) return res;
+  return { ...res, data: res.data.data };
+}
+
+async function getJob<T>(id: string): Promise<ApiResult<Job<T>>> {
+  return unwrap(
+    await request<Envelope<Job<T>>>({
+      method: "GET",
+      url: `/api/jobs/${encodeURIComponent(id)}`,
+    })
+  );
+}
+
+function isActive(job: Job): boolean {
+  return job.status === "queued" || job.status === "running";
+}
+
+// streamJob follows GET /api/jobs/:id/events until the job finishes. It resolves with
+// the last job state it saw, which is still active if the stream broke off early.
+function streamJob<T>(job: Job<T>, onProgress?: JobProgressHandler): Promise<Job<T>> {
+  if (typeof EventSource === "undefined") return Promise.resolve(job);
+
+  return new Promise((resolve) => {
+    let latest = job;
+    const source = new EventSource(
+      `${API_BASE_URL}/api/jobs/${encodeURIComponent(job.id)}/events`
+    );
+    const handle = (ev: MessageEvent) => {
+      latest = JSON.parse(ev.data) as Job<T>;
+      onProgress?.(latest);
+      if (!isActive(latest)) {
+        source.close();
+        resolve(latest);
+      }
+    };
+    source.addEventListener("status", handle as EventListener);
+    source.addEventListener("progress", handle as EventListener);
+    source.onerror = () => {
+      source.close();
+      resolve(latest);
+    };
+  });
+}
+
+// runJob queues a scan job and follows it until it finishes, resolving with the tool result.
+// Progress comes from the event stream, polling takes over when the stream is unavailable.
+async function runJob<T>(
+  url: string,
+  target: string,
+  onProgress?: JobProgressHandler,
+  params?: Record<string, unknown>
+): Promise<ApiResult<T>> {
+  const started = unwrap(
+    await request<Envelope<Job<T>>>({
+      method: "POST",
+      url,
+      data: { ...params, target: ensureNonEmptyTarget(target) },
+    })
+  );
+  if (!started.ok) return started;
+
+  let job = await streamJob(started.data, onProgress);
+  while (isActive(job)) {
+    onProgress?.(job);
+    await new Promise((resolve) => setTimeout(resolve, JOB_POLL_INTERVAL_MS));
+
+    const polled = await getJob<T>(job.id);
+    if (!polled.ok) return polled;
+    job = polled.data;
+  }
+  onProgress?.(job);
+
+  if (job.status !== "completed") {
+    return { ok: false, message: job.error || job.message || `Scan ${job.status}`, data: job };
+  }
+  return { ok: true, status: 200, data: job.result as T };
+}
+
 export const scannersApi = {
+  jobs: {
+    get: getJob,
+
+    cancel: async (id: string): Promise<ApiResult<Job>> =>
+      unwrap(
+        await request<Envelope<Job>>({
+          method: "DELETE",
+          url: `/api/jobs/${encodeURIComponent(id)}`,
+        })
+      ),
+
+    // Runs a job interrupted by a backend restart again
+    requeue: async (id: string): Promise<ApiResult<Job>> =>
+      unwrap(
+        await request<Envelope<Job>>({
+          method: "POST",
+          url: `/api/jobs/${encodeURIComponent(id)}/requeue`,
+        })
+      ),
+  },
+
+  scans: {
+    create: async (
+      target: string,
+      tools: ToolKey[],
+      name?: string,
+      options?: Partial<Record<ToolKey, Record<string, string>>>,
+      pipeline = false,
+      assetCriticality?: AssetCriticality
+    ): Promise<ApiResult<Scan>> =>
+      unwrap(
+        await request<Envelope<Scan>>({
+          method: "POST",
+          url: "/api/scans",
+          data: {
+            target: ensureNonEmptyTarget(target),
+            tools,
+            name,
+            options,
+            pipeline,
+            asset_criticality: assetCriticality,
+          },
+        })
+      ),
+
+    // Stores nmap, OpenVAS, nuclei, ZAP, sslyze or ffuf outputs produced elsewhere as a
+    // completed scan. target is needed when the files are about several hosts.
+    import: async (
+      files: File[],
+      name?: string,
+      target?: string,
+      assetCriticality?: AssetCriticality
+    ): Promise<ApiResult<Scan>> => {
+      const form = new FormData();
+      files.forEach((file) => form.append("file", file));
+      if (name) form.append("name", name);
+      if (target) form.append("target", target.trim());
+      if (assetCriticality) form.append("asset_criticality", assetCriticality);
+      return unwrap(
+        await request<Envelope<Scan>>({
+          method: "POST",
+          url: "/api/imports",
+          data: form,
+        })
+      );
+    },
+
+    get: async (id: string): Promise<ApiResult<Scan>> =>
+      unwrap(
+        await request<Envelope<Scan>>({
+          method: "GET",
+          url: `/api/scans/${encodeURIComponent(id)}`,
+        })
+      ),
+
+    findings: async (id: string, statuses?: TriageStatus[], suppressed = false): Promise<ApiResult<Finding[]>> =>
+      unwrap(
+        await request<Envelope<Finding[]>>({
+          method: "GET",
+          url: `/api/scans/${encodeURIComponent(id)}/findings`,
+          params: { status: statuses?.length ? statuses.join(",") : undefined, suppressed: suppressed || undefined },
+        })
+      ),
+
+    risk: async (id: string): Promise<ApiResult<ScanRisk>> =>
+      unwrap(
+        await request<Envelope<ScanRisk>>({
+          method: "GET",
+          url: `/api/scans/${encodeURIComponent(id)}/risk`,
+        })
+      ),
+
+    diff: async (id: string, against: string): Promise<ApiResult<ScanDiff>> =>
+      unwrap(
+        await request<Envelope<ScanDiff>>({
+          method: "GET",
+          url: `/api/scans/${encodeURIComponent(id)}/diff`,
+          params: { against },
+        })
+      ),
+
+    cancel: async (id: string): Promise<ApiResult<Scan>> =>
+      unwrap(
+        await request<Envelope<Scan>>({
+          method: "DELETE",
+          url: `/api/scans/${encodeURIComponent(id)}`,
+        })
+      ),
+  },
+
+  findings: {
+    triage: async (fingerprint: string): Promise<ApiResult<FindingTriage>> =>
+      unwrap(
+        await request<Envelope<FindingTriage>>({
+          method: "GET",
+          url: `/api/findings/${encodeURIComponent(fingerprint)}/triage`,
+        })
+      ),
+
+    updateTriage: async (fingerprint: string, update: TriageUpdate): Promise<ApiResult<FindingTriage>> =>
+      unwrap(
+        await request<Envelope<FindingTriage>>({
+          method: "PUT",
+          url: `/api/findings/${encodeURIComponent(fingerprint)}/triage`,
+          data: update,
+        })
+      ),
+
+    comment: async (fingerprint: string, body: string, parentId?: string): Promise<ApiResult<FindingComment>> =>
+      unwrap(
+        await request<Envelope<FindingComment>>({
+          method: "POST",
+          url: `/api/findings/${encodeURIComponent(fingerprint)}/comments`,
+          data: { body, parent_id: parentId },
+        })
+      ),
+
+    assigned: async (assignee = "me", status?: TriageStatus): Promise<ApiResult<FindingTriage[]>> =>
+      unwrap(
+        await request<Envelope<FindingTriage[]>>({
+          method: "GET",
+          url: "/api/findings/triage",
+          params: { assignee, status },
+        })
+      ),
+  },
+
+  suppressions: {
+    list: async (): Promise<ApiResult<SuppressionRule[]>> =>
+      unwrap(await request<Envelope<SuppressionRule[]>>({ method: "GET", url: "/api/suppressions" })),
+
+    create: async (rule: SuppressionRuleInput): Promise<ApiResult<SuppressionRule>> =>
+      unwrap(await request<Envelope<SuppressionRule>>({ method: "POST", url: "/api/suppressions", data: rule })),
+
+    update: async (id: string, rule: SuppressionRuleInput): Promise<ApiResult<SuppressionRule>> =>
+      unwrap(
+        await request<Envelope<SuppressionRule>>({
+          method: "PUT",
+          url: `/api/suppressions/${encodeURIComponent(id)}`,
+          data: rule,
+        })
+      ),
+
+    remove: async (id: string): Promise<ApiResult<null>> =>
+      unwrap(
+        await request<Envelope<null>>({
+          method: "DELETE",
+          url: `/api/suppressions/${encodeURIComponent(id)}`,
+        })
+      ),
+  },
+
+  queue: {
+    get: async (): Promise<ApiResult<JobQueue>> =>
+      unwrap(await request<Envelope<JobQueue>>({ method: "GET", url: "/api/queue" })),
+  },
+
   nmap: {
-    scan: async (target: string): Promise<ApiResult<NmapScanResponse>> =>
-      request<NmapScanResponse>({
-        method: "POST",
-        url: "/api/nmap/scan",
-        data: { target: ensureNonEmptyTarget(target) },
-      }),
+    scan: async (
+      target: string,
+      onProgress?: JobProgressHandler,
+      profile?: string
+    ): Promise<ApiResult<NmapScanResponse>> =>
+      runJob<NmapScanResponse>("/api/nmap/scan", target, onProgress, profile ? { profile } : undefined),
+
+    profiles: {
+      list: async (): Promise<ApiResult<NmapProfile[]>> =>
+        unwrap(await request<Envelope<NmapProfile[]>>({ method: "GET", url: "/api/nmap/profiles" })),
+
+      create: async (profile: NmapProfileInput): Promise<ApiResult<NmapProfile>> =>
+        unwrap(await request<Envelope<NmapProfile>>({ method: "POST", url: "/api/nmap/profiles", data: profile })),
+
+      update: async (name: string, profile: NmapProfileInput): Promise<ApiResult<NmapProfile>> =>
+        unwrap(
+          await request<Envelope<NmapProfile>>({
+            method: "PUT",
+            url: `/api/nmap/profiles/${encodeURIComponent(name)}`,
+            data: profile,
+          })
+        ),
+
+      remove: async (name: string): Promise<ApiResult<null>> =>
+        unwrap(
+          await request<Envelope<null>>({
+            method: "DELETE",
+            url: `/api/nmap/profiles/${encodeURIComponent(name)}`,
+          })
+        ),
+    },
   },
 
   ffuf: {
-    scan: async (target: string): Promise<ApiResult<unknown>> =>
-      request<unknown>({
-        method: "POST",
-        url: "/api/ffuf/scan",
-        data: { target: ensureNonEmptyTarget(target) },
-      }),
+    scan: async (
+      target: string,
+      onProgress?: JobProgressHandler
+    ): Promise<ApiResult<unknown>> =>
+      runJob<unknown>("/api/ffuf/scan", target, onProgress),
   },
 
   nuclei: {
-    scan: async (target: string): Promise<ApiResult<NucleiScanResponse>> =>
-      request<NucleiScanResponse>({
-        method: "POST",
-        url: "/api/nuclei/scan",
-        data: { target: ensureNonEmptyTarget(target) },
-      }),
+    scan: async (
+      target: string,
+      onProgress?: JobProgressHandler
+    ): Promise<ApiResult<NucleiScanResponse>> =>
+      runJob<NucleiScanResponse>("/api/nuclei/scan", target, onProgress),
   },
 
   sslyze: {
-    scan: async (target: string): Promise<ApiResult<unknown>> =>
-      request<unknown>({
-        method: "POST",
-        url: "/api/sslyze/scan",
-        data: { target: ensureNonEmptyTarget(target) },
-      }),
+    scan: async (
+      target: string,
+      onProgress?: JobProgressHandler
+    ): Promise<ApiResult<unknown>> =>
+      runJob<unknown>("/api/sslyze/scan", target, onProgress),
   },
 
   zap: {
-    scan: async (target: string): Promise<ApiResult<ZapScanResponse>> =>
-      request<ZapScanResponse>({
-        method: "POST",
-        url: "/api/zap/scan",
-        data: { target: ensureNonEmptyTarget(target) },
-      }),
+    scan: async (
+      target: string,
+      onProgress?: JobProgressHandler
+    ): Promise<ApiResult<ZapScanResponse>> =>
+      runJob<ZapScanResponse>("/api/zap/scan", target, onProgress),
   },
 
   openvas: {
@@ -106,16 +665,9 @@ export const scannersApi = {
 
     scan: async (
       target: string,
-      name?: string
-    ): Promise<ApiResult<OpenVASStartScanResponse>> =>
-      request<OpenVASStartScanResponse>({
-        method: "POST",
-        url: "/api/openvas/scan",
-        data: {
-          target: ensureNonEmptyTarget(target),
-          ...(name && name.trim() ? { name: name.trim() } : {}),
-        },
-      }),
+      onProgress?: JobProgressHandler
+    ): Promise<ApiResult<OpenVASReportResponse>> =>
+      runJob<OpenVASReportResponse>("/api/openvas/scan", target, onProgress),
 
     taskStatus: async (
       taskId: string
@@ -13,6 +13,8 @@ import { ScanVulnerability } from "@/context/ScanContext";
 
 export interface RawNmapAddress {
     addr: string;
+    addrtype?: "ipv4" | "ipv6" | "mac";
+    vendor?: string;
 }
 
 export interface RawNmapService {
@@ -20,11 +22,39 @@ export interface RawNmapService {
     product?: string;
     version?: string;
     extrainfo?: string;
+    ostype?: string;
+    hostname?: string;
+    devicetype?: string;
+    method?: "probed" | "table";
+    conf?: number;
+    tunnel?: string;
+    cpes?: string[];
 }
 
 export interface RawNmapState {
     state: "open" | "closed" | "filtered" | "open|filtered" | "unfiltered";
     reason?: string;
+    reason_ttl?: number;
+    reason_ip?: string;
+}
+
+// Structured NSE output: keyed elements and nested tables
+export interface RawNmapScriptElem {
+    key?: string;
+    value: string;
+}
+
+export interface RawNmapScriptTable {
+    key?: string;
+    elements?: RawNmapScriptElem[];
+    tables?: RawNmapScriptTable[];
+}
+
+export interface RawNmapScript {
+    id: string;
+    output: string;
+    elements?: RawNmapScriptElem[];
+    tables?: RawNmapScriptTable[];
 }
 
 export interface RawNmapPort {
@@ -32,21 +62,59 @@ export interface RawNmapPort {
     protocol: "tcp" | "udp";
     State: RawNmapState;
     Service: RawNmapService;
+    scripts?: RawNmapScript[];
 }
 
 export interface RawNmapPorts {
+    extraports?: { state: string; count: number; reasons?: { reason: string; count: number }[] }[];
     ports: RawNmapPort[];
 }
 
+export interface RawNmapOSClass {
+    type?: string;
+    vendor: string;
+    osfamily: string;
+    osgen?: string;
+    accuracy: number;
+    cpes?: string[];
+}
+
+export interface RawNmapOS {
+    portsused?: { state: string; proto: string; port: string }[];
+    osmatches?: { name: string; accuracy: number; line?: number; osclasses?: RawNmapOSClass[] }[];
+    osfingerprints?: { fingerprint: string }[];
+}
+
 export interface RawNmapHost {
+    starttime?: number;
+    endtime?: number;
+    status?: { state: "up" | "down" | "unknown" | "skipped"; reason?: string; reason_ttl?: number };
     addresses: RawNmapAddress[];
+    hostnames?: { name: string; type?: string }[];
     ports: RawNmapPorts;
-    hostnames?: { name: string }[];
-    status?: { state: string };
+    os?: RawNmapOS;
+    uptime?: { seconds: number; lastboot?: string };
+    distance?: { value: number };
+    hostscripts?: RawNmapScript[];
+    trace?: { proto?: string; port?: string; hops?: { ttl: number; ipaddr?: string; rtt?: string; host?: string }[] };
+    // Timing estimates in microseconds
+    times?: { srtt: number; rttvar: number; to: number };
 }
 
 export interface RawNmapProtocol {
+    scanner?: string;
+    args?: string;
+    version?: string;
+    start?: number;
+    startstr?: string;
+    scaninfo?: { type: string; protocol: string; numservices: number; services?: string }[];
+    prescripts?: RawNmapScript[];
     hosts: RawNmapHost[];
+    postscripts?: RawNmapScript[];
+    runstats?: {
+        finished: { time?: number; timestr?: string; elapsed?: number; summary?: string; exit?: string; errormsg?: string };
+        hosts: { up: number; down: number; total: number };
+    };
 }
 
 export interface RawNmapScanResult {
@@ -370,7 +438,12 @@ export function analyzeRisk(rawResult: any): RiskSummary {
 export interface ParsedNmapHost {
     ip: string;
     hostname?: string;
+    mac?: string;
+    vendor?: string;
+    // Best OS detection match, e.g. "Linux 4.15 - 5.8 (96%)"
+    os?: string;
     ports: ParsedNmapPort[];
+    scripts: RawNmapScript[];
 }
 
 export interface ParsedNmapPort {
@@ -380,6 +453,12 @@ export interface ParsedNmapPort {
     service: string;
     product?: string;
     version?: string;
+    extrainfo?: string;
+    tunnel?: string;
+    cpes?: string[];
+    // Why nmap considers the port in this state, e.g. "syn-ack"
+    reason?: string;
+    scripts: RawNmapScript[];
     severity: "Critical" | "High" | "Medium" | "Low" | "Info";
     impact: string;
     recommendation: string;
@@ -391,6 +470,8 @@ export interface ParsedNmapScanSummary {
     totalFilteredPorts: number;
     tcpPorts: number;
     udpPorts: number;
+    // Seconds the scan took, as reported by nmap
+    elapsed?: number;
     hosts: ParsedNmapHost[];
     severityCounts: {
         critical: number;
@@ -490,7 +571,7 @@ export function parseRawNmapScan(rawResult: RawNmapScanResult): ParsedNmapScanSu
         rawResult.udp.hosts.forEach(host => {
             // Check if we already have this host from TCP
             const existingHost = summary.hosts.find(
-                h => h.ip === host.addresses[0]?.addr
+                h => h.ip === hostIP(host)
             );
 
             if (existingHost) {
@@ -507,6 +588,11 @@ export function parseRawNmapScan(rawResult: RawNmapScanResult): ParsedNmapScanSu
     }
 
     summary.totalHosts = summary.hosts.length;
+    // The TCP and UDP runs are started together
+    const elapsed = Math.max(rawResult.tcp?.runstats?.finished.elapsed ?? 0, rawResult.udp?.runstats?.finished.elapsed ?? 0);
+    if (elapsed > 0) {
+        summary.elapsed = elapsed;
+    }
 
     // Convert service map to array
     summary.services = Array.from(serviceMap.entries())
@@ -582,6 +668,11 @@ function parseHostPorts(
             service: serviceName,
             product: port.Service?.product,
             version: port.Service?.version,
+            extrainfo: port.Service?.extrainfo,
+            tunnel: port.Service?.tunnel,
+            cpes: port.Service?.cpes,
+            reason: port.State?.reason,
+            scripts: port.scripts ?? [],
             severity,
             impact: getImpact(portNum),
             recommendation: getRecommendation(portNum),
@@ -600,18 +691,31 @@ function parseHost(
     serviceMap: Map<string, { count: number; ports: number[] }>,
     summary: ParsedNmapScanSummary
 ): ParsedNmapHost | null {
-    const ip = host.addresses[0]?.addr;
-    if (!ip) return null;
+    const ip = hostIP(host);
+    if (!ip || host.status?.state === "down") return null;
 
     const ports = parseHostPorts(host, defaultProtocol, serviceMap, summary);
+    const mac = host.addresses.find(a => a.addrtype === "mac");
+    const os = host.os?.osmatches?.[0];
 
     return {
         ip,
         hostname: host.hostnames?.[0]?.name,
+        mac: mac?.addr,
+        vendor: mac?.vendor,
+        os: os ? `${os.name} (${os.accuracy}%)` : undefined,
         ports,
+        scripts: host.hostscripts ?? [],
     };
 }
 
+/**
+ * IP address of a host; the MAC address comes after it when nmap ran on the local network
+ */
+function hostIP(host: RawNmapHost): string | undefined {
+    return (host.addresses.find(a => a.addrtype !== "mac") ?? host.addresses[0])?.addr;
+}
+
 /**
  * Convert raw Nmap scan to ScanVulnerability array for display
  */